@@ -0,0 +1,172 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// timeLayouts formats of the dates found on AMI fields
+var timeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	time.RFC1123Z,
+	time.ANSIC,
+}
+
+// Get return the param key ignoring case, empty if not present
+func (ev *AMIEvent) Get(key string) string {
+	return lookup(ev.Params, key)
+}
+
+// GetInt return the param key as int, 0 if not present or not a number
+func (ev *AMIEvent) GetInt(key string) int {
+	return parseInt(lookup(ev.Params, key))
+}
+
+// GetBool return the param key as bool like Asterisk does, true for yes, y,
+// true, t, on and 1
+func (ev *AMIEvent) GetBool(key string) bool {
+	return parseBool(lookup(ev.Params, key))
+}
+
+// GetTime return the param key as time, parsed from an epoch with optional
+// fraction (Timestamp) or a date, zero time if it can't be parsed or the
+// epoch is 0 (never, as LastCall)
+func (ev *AMIEvent) GetTime(key string) time.Time {
+	return parseTime(lookup(ev.Params, key))
+}
+
+// GetDuration return the param key as duration, parsed from seconds with
+// optional fraction, HH:MM:SS or a Go duration, 0 if it can't be parsed
+func (ev *AMIEvent) GetDuration(key string) time.Duration {
+	return parseDuration(lookup(ev.Params, key))
+}
+
+// ResponseError it's the error of an action answered with Response: Error
+type ResponseError struct {
+	ActionID string
+	Message  string
+}
+
+func (e *ResponseError) Error() string {
+	if e.Message == "" {
+		return "Action failed"
+	}
+	return e.Message
+}
+
+// OK check if the action succeeded, anything but Response: Error
+func (resp *AMIResponse) OK() bool {
+	return !strings.EqualFold(resp.Status, "Error")
+}
+
+// Err return a *ResponseError with the Message of the response when the
+// action failed, nil otherwise
+func (resp *AMIResponse) Err() error {
+	if resp.OK() {
+		return nil
+	}
+	return &ResponseError{ActionID: resp.ID, Message: lookup(resp.Params, "Message")}
+}
+
+// Get return the param key ignoring case, empty if not present
+func (resp *AMIResponse) Get(key string) string {
+	return lookup(resp.Params, key)
+}
+
+// lookup key on params trying it as given, canonicalized and ignoring case
+func lookup(params map[string]string, key string) string {
+	if v, ok := params[key]; ok {
+		return v
+	}
+	if v, ok := params[textproto.CanonicalMIMEHeaderKey(key)]; ok {
+		return v
+	}
+	for k, v := range params {
+		if strings.EqualFold(k, key) {
+			return v
+		}
+	}
+	return ""
+}
+
+func parseInt(v string) int {
+	i, err := strconv.Atoi(strings.TrimSpace(v))
+	if err != nil {
+		return 0
+	}
+	return i
+}
+
+func parseFloat(v string) float64 {
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return 0
+	}
+	return f
+}
+
+func parseBool(v string) bool {
+	switch strings.ToLower(strings.TrimSpace(v)) {
+	case "yes", "y", "true", "t", "on", "1":
+		return true
+	}
+	return false
+}
+
+func parseTime(v string) time.Time {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return time.Time{}
+	}
+
+	if epoch, err := strconv.ParseFloat(v, 64); err == nil {
+		if epoch == 0 {
+			return time.Time{}
+		}
+		sec := int64(epoch)
+		return time.Unix(sec, int64((epoch-float64(sec))*1e9))
+	}
+
+	for _, layout := range timeLayouts {
+		if t, err := time.ParseInLocation(layout, v, time.Local); err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+func parseDuration(v string) time.Duration {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0
+	}
+
+	if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second))
+	}
+
+	if parts := strings.Split(v, ":"); len(parts) == 3 {
+		var d time.Duration
+		for i, unit := range []time.Duration{time.Hour, time.Minute, time.Second} {
+			n, err := strconv.Atoi(parts[i])
+			if err != nil {
+				return 0
+			}
+			d += time.Duration(n) * unit
+		}
+		return d
+	}
+
+	if d, err := time.ParseDuration(v); err == nil {
+		return d
+	}
+	return 0
+}
@@ -0,0 +1,75 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"testing"
+	"time"
+)
+
+func TestEventAccessors(t *testing.T) {
+	ev := &AMIEvent{ID: "CoreShowChannel", Params: map[string]string{
+		"Channel":      "SIP/100-01",
+		"Calleridnum":  "100",
+		"Priority":     "3",
+		"Cause-Txt":    "Normal Clearing",
+		"Duration":     "01:02:03",
+		"Holdtime":     "12",
+		"Rtt":          "0.25",
+		"Timestamp":    "1600000000.500000",
+		"Datereceived": "2020-09-13 12:26:40",
+		"Dynamic":      "yes",
+		"Muted":        "no",
+	}}
+
+	if ev.Get("CallerIDNum") != "100" || ev.Get("calleridnum") != "100" || ev.Get("CAUSE-TXT") != "Normal Clearing" {
+		t.Fatal("case-insensitive lookup failed")
+	}
+	if ev.Get("Missing") != "" || ev.GetInt("Missing") != 0 || ev.GetBool("Missing") ||
+		!ev.GetTime("Missing").IsZero() || ev.GetDuration("Missing") != 0 {
+		t.Fatal("missing key without zero-value")
+	}
+	if ev.GetInt("priority") != 3 || ev.GetInt("Channel") != 0 {
+		t.Fatal("unexpected GetInt")
+	}
+	if !ev.GetBool("Dynamic") || ev.GetBool("Muted") {
+		t.Fatal("unexpected GetBool")
+	}
+	if d := ev.GetDuration("Duration"); d != time.Hour+2*time.Minute+3*time.Second {
+		t.Fatal("unexpected HH:MM:SS duration", d)
+	}
+	if d := ev.GetDuration("HoldTime"); d != 12*time.Second {
+		t.Fatal("unexpected seconds duration", d)
+	}
+	if d := ev.GetDuration("RTT"); d != 250*time.Millisecond {
+		t.Fatal("unexpected fractional duration", d)
+	}
+	if ts := ev.GetTime("Timestamp"); ts.Unix() != 1600000000 || ts.Nanosecond() != 500000000 {
+		t.Fatal("unexpected epoch time", ts)
+	}
+	if ts := ev.GetTime("DateReceived"); ts.Year() != 2020 || ts.Minute() != 26 {
+		t.Fatal("unexpected date", ts)
+	}
+}
+
+func TestResponseHelpers(t *testing.T) {
+	ok := &AMIResponse{ID: "1", Status: "Success", Params: map[string]string{"Message": "Authentication accepted"}}
+	if !ok.OK() || ok.Err() != nil || ok.Get("message") != "Authentication accepted" {
+		t.Fatal("unexpected success helpers")
+	}
+
+	failed := &AMIResponse{ID: "2", Status: "Error", Params: map[string]string{"Message": "Permission denied"}}
+	if failed.OK() {
+		t.Fatal("expected not OK")
+	}
+	err, isResponseError := failed.Err().(*ResponseError)
+	if !isResponseError || err.ActionID != "2" || err.Error() != "Permission denied" {
+		t.Fatal("unexpected error", failed.Err())
+	}
+
+	if (&AMIResponse{Status: "Error"}).Err().Error() != "Action failed" {
+		t.Fatal("expected default message")
+	}
+}
@@ -0,0 +1,260 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var errAsyncAGIStopped = errors.New("AsyncAGI Stopped")
+var errAGISessionEnded = errors.New("AGI Session Ended")
+
+// AGIResult result of an AGI command, as 200 result=1 (timeout)
+type AGIResult struct {
+	Code   int
+	Result string
+	// Data the text after the result, without parenthesis
+	Data string
+	Raw  string
+}
+
+// AsyncAGI accepts the channels entering AGI(agi:async) on the dialplan
+type AsyncAGI struct {
+	client  *AMIClient
+	watcher *eventListener
+	mutex   *sync.Mutex
+	once    *sync.Once
+
+	// active sessions by channel
+	active   map[string]*AsyncAGISession
+	sessions chan *AsyncAGISession
+	done     chan struct{}
+}
+
+// AsyncAGISession a channel driven by AGI commands sent over AMI, it ends
+// when the channel leaves AGI or hangs up
+type AsyncAGISession struct {
+	Channel string
+	// Env the agi_ variables given by Asterisk at start (agi_callerid...)
+	Env map[string]string
+
+	client *AMIClient
+	mutex  *sync.Mutex
+	once   *sync.Once
+	// pending commands by CommandID
+	pending map[string]chan *AGIResult
+	done    chan struct{}
+}
+
+// AsyncAGI start accepting AsyncAGI sessions until Stop
+func (client *AMIClient) AsyncAGI() *AsyncAGI {
+	agi := &AsyncAGI{
+		client:   client,
+		watcher:  client.watch(),
+		mutex:    new(sync.Mutex),
+		once:     new(sync.Once),
+		active:   make(map[string]*AsyncAGISession),
+		sessions: make(chan *AsyncAGISession, 100),
+		done:     make(chan struct{}),
+	}
+
+	go agi.run()
+	return agi
+}
+
+// Accept wait for the next channel entering AsyncAGI, up to 100 channels
+// wait to be accepted, the next ones are hung up
+func (agi *AsyncAGI) Accept() (*AsyncAGISession, error) {
+	select {
+	case session := <-agi.sessions:
+		return session, nil
+	case <-agi.done:
+		return nil, errAsyncAGIStopped
+	}
+}
+
+// Stop accepting sessions, the active sessions are ended
+func (agi *AsyncAGI) Stop() {
+	agi.once.Do(func() {
+		agi.client.unwatch(agi.watcher)
+		close(agi.done)
+
+		agi.mutex.Lock()
+		for channel, session := range agi.active {
+			session.end()
+			delete(agi.active, channel)
+		}
+		agi.mutex.Unlock()
+	})
+}
+
+func (agi *AsyncAGI) run() {
+	for {
+		select {
+		case <-agi.done:
+			return
+		case ev := <-agi.watcher.events:
+			agi.handle(ev)
+		}
+	}
+}
+
+func (agi *AsyncAGI) handle(ev *AMIEvent) {
+	kind := ev.ID
+	// Asterisk < 12 sends AsyncAGI with SubEvent Start, Exec or End
+	if kind == "AsyncAGI" {
+		kind += ev.Get("SubEvent")
+	}
+
+	channel := ev.Get("Channel")
+	agi.mutex.Lock()
+	defer agi.mutex.Unlock()
+
+	switch kind {
+	case "AsyncAGIStart":
+		session := &AsyncAGISession{
+			Channel: channel,
+			Env:     parseAGIEnv(ev.Get("Env")),
+			client:  agi.client,
+			mutex:   new(sync.Mutex),
+			once:    new(sync.Once),
+			pending: make(map[string]chan *AGIResult),
+			done:    make(chan struct{}),
+		}
+		if previous, ok := agi.active[channel]; ok {
+			previous.end()
+		}
+		agi.active[channel] = session
+
+		// the events of the client must not wait for Accept, the channel
+		// is hung up when too many are waiting
+		select {
+		case agi.sessions <- session:
+		default:
+			session.end()
+			delete(agi.active, channel)
+			agi.client.logf("gami: AsyncAGI %d sessions waiting for Accept, hanging up %s", cap(agi.sessions), channel)
+			go agi.client.syncAction(Params{"Action": "Hangup", "Channel": channel})
+		}
+	case "AsyncAGIExec":
+		if session, ok := agi.active[channel]; ok {
+			session.deliver(ev.Get("CommandID"), parseAGIResult(ev.Get("Result")))
+		}
+	case "AsyncAGIEnd", "Hangup":
+		if session, ok := agi.active[channel]; ok {
+			session.end()
+			delete(agi.active, channel)
+		}
+	}
+}
+
+// Exec send the AGI command (STREAM FILE hello-world "", GET DATA...) and
+// wait for its result, a result with code other than 200 is returned along
+// with an error
+func (session *AsyncAGISession) Exec(command string) (*AGIResult, error) {
+	commandID := newActionID()
+	result := make(chan *AGIResult, 1)
+
+	session.mutex.Lock()
+	session.pending[commandID] = result
+	session.mutex.Unlock()
+	defer func() {
+		session.mutex.Lock()
+		delete(session.pending, commandID)
+		session.mutex.Unlock()
+	}()
+
+	_, err := session.client.syncAction(Params{
+		"Action":    "AGI",
+		"Channel":   session.Channel,
+		"Command":   command,
+		"CommandID": commandID,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case res := <-result:
+		if res.Code != 200 {
+			return res, errors.New(res.Raw)
+		}
+		return res, nil
+	case <-session.done:
+		return nil, errAGISessionEnded
+	}
+}
+
+// Done closed when the session ends
+func (session *AsyncAGISession) Done() <-chan struct{} {
+	return session.done
+}
+
+func (session *AsyncAGISession) deliver(commandID string, result *AGIResult) {
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+
+	if pending, ok := session.pending[commandID]; ok {
+		pending <- result
+		delete(session.pending, commandID)
+	}
+}
+
+func (session *AsyncAGISession) end() {
+	session.once.Do(func() {
+		close(session.done)
+	})
+}
+
+// parseAGIEnv decode the Env of AsyncAGIStart, url encoded lines agi_xxx: value
+func parseAGIEnv(raw string) map[string]string {
+	env := make(map[string]string)
+	decoded, err := url.PathUnescape(raw)
+	if err != nil {
+		decoded = raw
+	}
+
+	for _, line := range strings.Split(decoded, "\n") {
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		env[strings.TrimSpace(line[:i])] = strings.TrimSpace(line[i+1:])
+	}
+	return env
+}
+
+// parseAGIResult decode the Result of AsyncAGIExec, url encoded as
+// 200 result=1 (timeout)
+func parseAGIResult(raw string) *AGIResult {
+	decoded, err := url.PathUnescape(raw)
+	if err != nil {
+		decoded = raw
+	}
+	decoded = strings.TrimSpace(decoded)
+
+	res := &AGIResult{Raw: decoded}
+	fields := strings.SplitN(decoded, " ", 2)
+	res.Code, _ = strconv.Atoi(fields[0])
+	if len(fields) < 2 {
+		return res
+	}
+
+	rest := fields[1]
+	if strings.HasPrefix(rest, "result=") {
+		rest = rest[len("result="):]
+		if i := strings.Index(rest, " "); i >= 0 {
+			res.Result, rest = rest[:i], strings.TrimSpace(rest[i+1:])
+		} else {
+			res.Result, rest = rest, ""
+		}
+	}
+	res.Data = strings.TrimSuffix(strings.TrimPrefix(rest, "("), ")")
+	return res
+}
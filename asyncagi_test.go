@@ -0,0 +1,146 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"fmt"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestAsyncAGISession(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	channel := "SIP/100-00000001"
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "AsyncAGIStart", "Channel": channel,
+				"Env": "agi_request%3A%20async%0Aagi_channel%3A%20SIP%2F100-00000001%0Aagi_callerid%3A%20100%0A%0A"},
+		}
+	})
+	srv.MockList("AGI", func(params textproto.MIMEHeader) []map[string]string {
+		result := "200%20result%3D1%20(timeout)%0A"
+		if params.Get("Command") == "HANGUP" {
+			return []map[string]string{
+				{"Response": "Success", "ActionID": params.Get("Actionid")},
+				{"Event": "AsyncAGIEnd", "Channel": params.Get("Channel")},
+			}
+		}
+		if params.Get("Command") == "NOOP BAD" {
+			result = "510%20Invalid%20or%20unknown%20command%0A"
+		}
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid"), "Message": "Added AGI command to queue"},
+			{"Event": "AsyncAGIExec", "Channel": params.Get("Channel"),
+				"CommandID": params.Get("Commandid"), "Result": result},
+		}
+	})
+
+	agi := ami.AsyncAGI()
+	defer agi.Stop()
+	if _, _, err := ami.Action(Params{"Action": "UserEvent", "UserEvent": "start"}); err != nil {
+		t.Fatal(err)
+	}
+
+	session, err := agi.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if session.Channel != channel || session.Env["agi_callerid"] != "100" || session.Env["agi_request"] != "async" {
+		t.Fatalf("unexpected session %+v", session)
+	}
+
+	res, err := session.Exec(`GET DATA beep 3000 1`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Code != 200 || res.Result != "1" || res.Data != "timeout" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+
+	if _, err := session.Exec("NOOP BAD"); err == nil || err.Error() != "510 Invalid or unknown command" {
+		t.Fatal("expected error of invalid command", err)
+	}
+
+	if _, err := session.Exec("HANGUP"); err != errAGISessionEnded {
+		t.Fatal("expected session ended", err)
+	}
+	select {
+	case <-session.Done():
+	case <-time.After(time.Second):
+		t.Fatal("session not ended")
+	}
+
+	agi.Stop()
+	if _, err := agi.Accept(); err != errAsyncAGIStopped {
+		t.Fatal("expected stopped", err)
+	}
+}
+
+func TestParseAGIResult(t *testing.T) {
+	cases := map[string]AGIResult{
+		"200%20result%3D0%0A":                  {Code: 200, Result: "0", Raw: "200 result=0"},
+		"200%20result%3D-1%20endpos%3D1234%0A": {Code: 200, Result: "-1", Data: "endpos=1234", Raw: "200 result=-1 endpos=1234"},
+		"200%20result%3D1%20(hello%20world)":   {Code: 200, Result: "1", Data: "hello world", Raw: "200 result=1 (hello world)"},
+		"520%20Invalid%20command%20syntax.":    {Code: 520, Data: "Invalid command syntax.", Raw: "520 Invalid command syntax."},
+	}
+	for raw, expected := range cases {
+		if res := parseAGIResult(raw); *res != expected {
+			t.Errorf("%s: expected %+v, got %+v", raw, expected, *res)
+		}
+	}
+}
+
+func TestAsyncAGIBacklogFull(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	go func() {
+		for range ami.Events {
+		}
+	}()
+
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		frames := []map[string]string{{"Response": "Success", "ActionID": params.Get("Actionid")}}
+		for i := 0; i <= 100; i++ {
+			frames = append(frames, map[string]string{"Event": "AsyncAGIStart", "Channel": fmt.Sprintf("SIP/100-%08d", i)})
+		}
+		return frames
+	})
+	hangups := make(chan string, 10)
+	srv.Mock("Hangup", func(params textproto.MIMEHeader) map[string]string {
+		hangups <- params.Get("Channel")
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid")}
+	})
+
+	agi := ami.AsyncAGI()
+	defer agi.Stop()
+	if _, err := ami.syncAction(Params{"Action": "UserEvent", "UserEvent": "start"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case channel := <-hangups:
+		if channel != "SIP/100-00000100" {
+			t.Fatal("unexpected channel hung up", channel)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("session beyond the backlog not hung up")
+	}
+
+	// the client still reads while nothing is accepted
+	if _, err := ami.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if session, err := agi.Accept(); err != nil || session.Channel != "SIP/100-00000000" {
+		t.Fatal("unexpected first session", session, err)
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"os"
+)
+
+var errNoAuthenticator = errors.New("No Authenticator")
+
+// Authenticator log in the session of the client, it's called on every
+// Authenticate so the credentials can be fetched on each connection
+type Authenticator interface {
+	Authenticate(client *AMIClient) error
+}
+
+// Credentials return the username and secret to log in
+type Credentials func() (username, secret string, err error)
+
+// StaticCredentials always return username and secret
+func StaticCredentials(username, secret string) Credentials {
+	return func() (string, string, error) {
+		return username, secret, nil
+	}
+}
+
+// EnvCredentials read the username and secret from the environment
+// variables when called
+func EnvCredentials(usernameVar, secretVar string) Credentials {
+	return func() (string, string, error) {
+		username, ok := os.LookupEnv(usernameVar)
+		if !ok {
+			return "", "", errors.New("Environment variable " + usernameVar + " not set")
+		}
+		secret, ok := os.LookupEnv(secretVar)
+		if !ok {
+			return "", "", errors.New("Environment variable " + secretVar + " not set")
+		}
+		return username, secret, nil
+	}
+}
+
+// plainAuth log in sending the secret
+type plainAuth struct {
+	credentials Credentials
+}
+
+// PlainAuth log in with the secret in plain text
+func PlainAuth(credentials Credentials) Authenticator {
+	return &plainAuth{credentials}
+}
+
+func (auth *plainAuth) Authenticate(client *AMIClient) error {
+	username, secret, err := auth.credentials()
+	if err != nil {
+		return err
+	}
+
+	_, err = client.syncAction(Params{"Action": "Login", "Username": username, "Secret": secret})
+	return err
+}
+
+// md5Auth log in answering a MD5 challenge
+type md5Auth struct {
+	credentials Credentials
+}
+
+// MD5Auth log in with the MD5 challenge, the secret is not sent
+func MD5Auth(credentials Credentials) Authenticator {
+	return &md5Auth{credentials}
+}
+
+func (auth *md5Auth) Authenticate(client *AMIClient) error {
+	username, secret, err := auth.credentials()
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.syncAction(Params{"Action": "Challenge", "AuthType": "MD5"})
+	if err != nil {
+		return err
+	}
+
+	key := md5.Sum([]byte(resp.Params["Challenge"] + secret))
+	_, err = client.syncAction(Params{"Action": "Login", "AuthType": "MD5",
+		"Username": username, "Key": hex.EncodeToString(key[:])})
+	return err
+}
+
+// UseAuthenticator log in with auth on Authenticate and Reconnect
+func UseAuthenticator(auth Authenticator) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.authenticator = auth
+	}
+}
+
+// Authenticate log in with the authenticator of the client
+func (client *AMIClient) Authenticate() error {
+	if client.authenticator == nil {
+		return errNoAuthenticator
+	}
+	return client.authenticator.Authenticate(client)
+}
@@ -0,0 +1,87 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"net/textproto"
+	"os"
+	"testing"
+)
+
+func TestMD5Auth(t *testing.T) {
+	srv := newAmiServer()
+	defer srv.Close()
+	ami, err := Dial(srv.Addr, UseAuthenticator(MD5Auth(StaticCredentials("admin", "secret"))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	srv.Mock("Challenge", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid"), "Challenge": "840415273"}
+	})
+	srv.Mock("Login", func(params textproto.MIMEHeader) map[string]string {
+		key := md5.Sum([]byte("840415273secret"))
+		if params.Get("Secret") != "" || params.Get("Key") != hex.EncodeToString(key[:]) {
+			return map[string]string{"Response": "Error", "ActionID": params.Get("Actionid"),
+				"Message": "Authentication failed"}
+		}
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid")}
+	})
+
+	if err := ami.Authenticate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnvCredentials(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	logins := make(chan string, 2)
+	srv.Mock("Login", func(params textproto.MIMEHeader) map[string]string {
+		logins <- params.Get("Username") + ":" + params.Get("Secret")
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid")}
+	})
+
+	defer os.Unsetenv("GAMI_TEST_USER")
+	defer os.Unsetenv("GAMI_TEST_SECRET")
+	auth := PlainAuth(EnvCredentials("GAMI_TEST_USER", "GAMI_TEST_SECRET"))
+
+	if err := auth.Authenticate(ami); err == nil {
+		t.Fatal("expected error without environment")
+	}
+
+	os.Setenv("GAMI_TEST_USER", "admin")
+	os.Setenv("GAMI_TEST_SECRET", "first")
+	if err := auth.Authenticate(ami); err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("GAMI_TEST_SECRET", "rotated")
+	if err := auth.Authenticate(ami); err != nil {
+		t.Fatal(err)
+	}
+
+	if login := <-logins; login != "admin:first" {
+		t.Fatal("unexpected login", login)
+	}
+	if login := <-logins; login != "admin:rotated" {
+		t.Fatal("credentials not fetched again", login)
+	}
+}
+
+func TestAuthenticateWithoutAuthenticator(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	if err := ami.Authenticate(); err != errNoAuthenticator {
+		t.Fatal("expected errNoAuthenticator, got", err)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"io"
+	"net/textproto"
+	"strings"
+	"time"
+)
+
+// defaultBannerTimeout to read the banner when BannerTimeout is not used
+const defaultBannerTimeout = 10 * time.Second
+
+// BannerTimeout wait timeout for the banner on connect, 0 wait forever
+func BannerTimeout(timeout time.Duration) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.bannerTimeout = timeout
+	}
+}
+
+// ValidateBanner accept the servers whose banner check returns true, by
+// default the banner must contain "Asterisk Call Manager"
+func ValidateBanner(check func(banner string) bool) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.bannerCheck = check
+	}
+}
+
+// BannerPrefixes accept also the banners starting with one of prefixes, for
+// distributions and proxies presenting a modified greeting, whatever the
+// order of the options
+func BannerPrefixes(prefixes ...string) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.bannerPrefixes = append(c.bannerPrefixes, prefixes...)
+	}
+}
+
+// SkipBannerCheck accept any banner, for AMI compatible proxies
+func SkipBannerCheck(c *AMIClient) {
+	c.bannerCheck = nil
+}
+
+// isAsteriskBanner default banner check
+func isAsteriskBanner(banner string) bool {
+	return strings.Contains(banner, "Asterisk Call Manager")
+}
+
+// readBanner read and validate the first line sent by the server
+func (client *AMIClient) readBanner(connRaw io.ReadWriteCloser, conn *textproto.Conn) error {
+	if deadliner, ok := connRaw.(interface{ SetReadDeadline(time.Time) error }); ok && client.bannerTimeout > 0 {
+		deadliner.SetReadDeadline(time.Now().Add(client.bannerTimeout))
+		defer deadliner.SetReadDeadline(time.Time{})
+	}
+
+	banner, err := conn.ReadLine()
+	if err != nil {
+		return err
+	}
+
+	if client.bannerCheck == nil || client.bannerCheck(banner) {
+		return nil
+	}
+	for _, prefix := range client.bannerPrefixes {
+		if strings.HasPrefix(banner, prefix) {
+			return nil
+		}
+	}
+	return errNoAMI
+}
@@ -0,0 +1,114 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// bannerServer accept connections sending banner, nothing if empty
+func bannerServer(t *testing.T, banner string) net.Listener {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			if banner != "" {
+				fmt.Fprintf(conn, "%s\r\n", banner)
+			}
+			//keep the connection open until the listener is closed
+			defer conn.Close()
+		}
+	}()
+	return listener
+}
+
+func TestBannerTimeout(t *testing.T) {
+	listener := bannerServer(t, "")
+	defer listener.Close()
+
+	start := time.Now()
+	_, err := Dial(listener.Addr().String(), BannerTimeout(time.Millisecond*100))
+	if err == nil {
+		t.Fatal("expected timeout error")
+	}
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Fatal("expected timeout error, got", err)
+	}
+	if time.Since(start) > time.Second*5 {
+		t.Fatal("banner timeout not applied")
+	}
+}
+
+func TestValidateBanner(t *testing.T) {
+	listener := bannerServer(t, "Issabel Call Manager/1.3")
+	defer listener.Close()
+
+	if _, err := Dial(listener.Addr().String()); err != errNoAMI {
+		t.Fatal("expected errNoAMI, got", err)
+	}
+
+	isIssabel := func(banner string) bool {
+		return strings.HasPrefix(banner, "Issabel Call Manager")
+	}
+	ami, err := Dial(listener.Addr().String(), ValidateBanner(isIssabel))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ami.connRaw.Close()
+
+	ami, err = Dial(listener.Addr().String(), SkipBannerCheck)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ami.connRaw.Close()
+}
+
+func TestBannerPrefixes(t *testing.T) {
+	issabel := bannerServer(t, "Issabel Call Manager/1.3")
+	defer issabel.Close()
+	asterisk := bannerServer(t, "Asterisk Call Manager/5.0.1")
+	defer asterisk.Close()
+
+	prefixes := BannerPrefixes("FreePBX Manager", "Issabel Call Manager")
+	for _, listener := range []net.Listener{issabel, asterisk} {
+		ami, err := Dial(listener.Addr().String(), prefixes)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ami.connRaw.Close()
+	}
+
+	if _, err := Dial(issabel.Addr().String(), BannerPrefixes("FreePBX Manager")); err != errNoAMI {
+		t.Fatal("expected errNoAMI, got", err)
+	}
+}
+
+func TestBannerPrefixesOrder(t *testing.T) {
+	issabel := bannerServer(t, "Issabel Call Manager/1.3")
+	defer issabel.Close()
+
+	// the prefixes hold whatever check is set after them
+	onlyAsterisk := ValidateBanner(isAsteriskBanner)
+	for _, options := range [][]func(*AMIClient){
+		{BannerPrefixes("Issabel"), onlyAsterisk},
+		{onlyAsterisk, BannerPrefixes("Issabel")},
+	} {
+		ami, err := Dial(issabel.Addr().String(), options...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ami.connRaw.Close()
+	}
+}
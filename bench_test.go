@@ -0,0 +1,129 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"bufio"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// benchEventFrame a typical Newchannel of Asterisk 13
+const benchEventFrame = "Event: Newchannel\r\nPrivilege: call,all\r\nChannel: PJSIP/100-00000001\r\n" +
+	"ChannelState: 0\r\nChannelStateDesc: Down\r\nCallerIDNum: 100\r\nCallerIDName: Alice\r\n" +
+	"ConnectedLineNum: <unknown>\r\nConnectedLineName: <unknown>\r\nLanguage: en\r\nAccountCode: \r\n" +
+	"Context: from-internal\r\nExten: 200\r\nPriority: 1\r\nUniqueid: 1600000000.1\r\n" +
+	"Linkedid: 1600000000.1\r\n\r\n"
+
+func BenchmarkReadFrame(b *testing.B) {
+	stream := strings.Repeat(benchEventFrame, 1000)
+	b.SetBytes(int64(len(benchEventFrame)))
+	b.ReportAllocs()
+
+	var r *textproto.Reader
+	for i := 0; i < b.N; i++ {
+		if i%1000 == 0 {
+			r = textproto.NewReader(bufio.NewReader(strings.NewReader(stream)))
+		}
+		data, err := ReadFrame(r)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if _, err := newEvent(&data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkAction(b *testing.B) {
+	srv := newAmiServer()
+	srv.maxDelay = 0
+	defer srv.Close()
+	ami, err := Dial(srv.Addr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			response, _, err := ami.Action(Params{"Action": "Ping"})
+			if err != nil {
+				b.Fatal(err)
+			}
+			<-response
+		}
+	})
+}
+
+func BenchmarkEventFanout(b *testing.B) {
+	srv := newAmiServer()
+	srv.maxDelay = 0
+	defer srv.Close()
+	ami, err := Dial(srv.Addr)
+	if err != nil {
+		b.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	const burst = 1000
+	srv.MockList("Burst", func(params textproto.MIMEHeader) []map[string]string {
+		n, _ := strconv.Atoi(params.Get("Count"))
+		frames := []map[string]string{{"Response": "Success", "ActionID": params.Get("Actionid")}}
+		for i := 0; i < n; i++ {
+			frames = append(frames, map[string]string{"Event": "Newchannel", "Privilege": "call,all",
+				"Channel": "PJSIP/100-00000001", "ChannelStateDesc": "Down", "CallerIDNum": "100",
+				"Context": "from-internal", "Exten": "200", "Uniqueid": "1600000000.1"})
+		}
+		return frames
+	})
+
+	// a tracker and a trace watch every event like an application would
+	tracker := ami.TrackChannels()
+	defer tracker.Stop()
+	trace := ami.TraceCall("none")
+	defer trace.Stop()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for sent := 0; sent < b.N; sent += burst {
+		n := burst
+		if b.N-sent < n {
+			n = b.N - sent
+		}
+		if _, _, err := ami.Action(Params{"Action": "Burst", "Count": strconv.Itoa(n)}); err != nil {
+			b.Fatal(err)
+		}
+		for received := 0; received < n; {
+			if ev := <-ami.Events; ev.ID == "Newchannel" {
+				received++
+			}
+		}
+	}
+}
+
+func BenchmarkPendingActions(b *testing.B) {
+	pending := newPendingMap()
+	var seq int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			actionID := strconv.FormatInt(atomic.AddInt64(&seq, 1), 10)
+			pending.register(actionID, func() *pendingAction {
+				return &pendingAction{}
+			})
+			pending.take(actionID)
+		}
+	})
+}
@@ -0,0 +1,104 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"strconv"
+)
+
+// Bridge a bridge of Asterisk >= 12
+type Bridge struct {
+	ID          string
+	Type        string
+	Technology  string
+	Creator     string
+	Name        string
+	NumChannels int
+	// Channels in the bridge, only filled by BridgeInfo
+	Channels []string
+}
+
+// BridgeList return the bridges on the server, bridgeType filter them when not empty
+func (client *AMIClient) BridgeList(bridgeType string) ([]Bridge, error) {
+	p := Params{"Action": "BridgeList"}
+	if bridgeType != "" {
+		p["BridgeType"] = bridgeType
+	}
+
+	events, err := client.listAction(p, "BridgeListComplete")
+	if err != nil {
+		return nil, err
+	}
+
+	bridges := make([]Bridge, 0, len(events))
+	for _, ev := range events {
+		if ev.ID != "BridgeListItem" {
+			continue
+		}
+		bridges = append(bridges, newBridge(ev))
+	}
+
+	return bridges, nil
+}
+
+// BridgeInfo return the bridge with its channels
+func (client *AMIClient) BridgeInfo(bridgeID string) (*Bridge, error) {
+	events, err := client.listAction(Params{"Action": "BridgeInfo", "BridgeUniqueid": bridgeID}, "BridgeInfoComplete")
+	if err != nil {
+		return nil, err
+	}
+
+	bridge := newBridge(events[len(events)-1])
+	for _, ev := range events {
+		if ev.ID == "BridgeInfoChannel" {
+			bridge.Channels = append(bridge.Channels, ev.Params["Channel"])
+		}
+	}
+
+	return &bridge, nil
+}
+
+// BridgeCreate bridge two channels together, it uses the action Bridge
+// because AMI can't create an empty bridge
+func (client *AMIClient) BridgeCreate(channel1, channel2 string, tone bool) error {
+	p := Params{"Action": "Bridge", "Channel1": channel1, "Channel2": channel2, "Tone": "no"}
+	if tone {
+		p["Tone"] = "yes"
+	}
+
+	_, err := client.syncAction(p)
+	return err
+}
+
+// BridgeDestroy destroy the bridge, the channels on it are hung up
+func (client *AMIClient) BridgeDestroy(bridgeID string) error {
+	_, err := client.syncAction(Params{"Action": "BridgeDestroy", "BridgeUniqueid": bridgeID})
+	return err
+}
+
+// BridgeKick kick the channel from the bridge, bridgeID can be empty to
+// kick the channel from any bridge
+func (client *AMIClient) BridgeKick(bridgeID, channel string) error {
+	p := Params{"Action": "BridgeKick", "Channel": channel}
+	if bridgeID != "" {
+		p["BridgeUniqueid"] = bridgeID
+	}
+
+	_, err := client.syncAction(p)
+	return err
+}
+
+// newBridge build a bridge from the fields of a bridge event
+func newBridge(ev *AMIEvent) Bridge {
+	numChannels, _ := strconv.Atoi(ev.Params["Bridgenumchannels"])
+	return Bridge{
+		ID:          ev.Params["Bridgeuniqueid"],
+		Type:        ev.Params["Bridgetype"],
+		Technology:  ev.Params["Bridgetechnology"],
+		Creator:     ev.Params["Bridgecreator"],
+		Name:        ev.Params["Bridgename"],
+		NumChannels: numChannels,
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+)
+
+func TestBridgeList(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.MockList("BridgeList", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "BridgeListItem", "ActionID": id, "BridgeUniqueid": "b1", "BridgeType": "basic",
+				"BridgeTechnology": "simple_bridge", "BridgeNumChannels": "2"},
+			{"Event": "BridgeListComplete", "ActionID": id, "EventList": "Complete", "ListItems": "1"},
+		}
+	})
+
+	bridges, err := ami.BridgeList("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bridges) != 1 {
+		t.Fatalf("expected 1 bridge, got %d", len(bridges))
+	}
+	if bridges[0].ID != "b1" || bridges[0].Technology != "simple_bridge" || bridges[0].NumChannels != 2 {
+		t.Fatalf("unexpected bridge %+v", bridges[0])
+	}
+}
+
+func TestBridgeInfo(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.MockList("BridgeInfo", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		bridgeID := params.Get("Bridgeuniqueid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "BridgeInfoChannel", "ActionID": id, "Channel": "SIP/100-00000001"},
+			{"Event": "BridgeInfoChannel", "ActionID": id, "Channel": "SIP/200-00000002"},
+			{"Event": "BridgeInfoComplete", "ActionID": id, "BridgeUniqueid": bridgeID, "BridgeType": "basic",
+				"BridgeTechnology": "native_rtp", "BridgeNumChannels": "2"},
+		}
+	})
+
+	bridge, err := ami.BridgeInfo("b1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bridge.ID != "b1" || bridge.Technology != "native_rtp" {
+		t.Fatalf("unexpected bridge %+v", bridge)
+	}
+	if len(bridge.Channels) != 2 || bridge.Channels[0] != "SIP/100-00000001" {
+		t.Fatalf("unexpected channels %v", bridge.Channels)
+	}
+}
+
+func TestBridgeKick(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.Mock("BridgeKick", func(params textproto.MIMEHeader) map[string]string {
+		if params.Get("Channel") == "" {
+			return map[string]string{"Response": "Error", "ActionID": params.Get("Actionid"),
+				"Message": "Channel must be provided"}
+		}
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid")}
+	})
+
+	if err := ami.BridgeKick("b1", "SIP/100-00000001"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ami.BridgeKick("b1", ""); err == nil {
+		t.Fatal("expected error without channel")
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"strconv"
+	"time"
+)
+
+// CallState progress of a click-to-dial call
+type CallState int
+
+const (
+	// CallRinging a leg is ringing, first the caller then the callee
+	CallRinging CallState = iota
+	// CallAnswered the caller answered and the callee is dialed
+	CallAnswered
+	// CallBridged the callee answered, both legs are talking
+	CallBridged
+	// CallFailed the call couldn't be connected, the progress ends
+	CallFailed
+	// CallHungup the bridged call ended, the progress ends
+	CallHungup
+)
+
+var callStateNames = map[CallState]string{
+	CallRinging:  "Ringing",
+	CallAnswered: "Answered",
+	CallBridged:  "Bridged",
+	CallFailed:   "Failed",
+	CallHungup:   "Hungup",
+}
+
+func (state CallState) String() string {
+	return callStateNames[state]
+}
+
+// CallOptions of a click-to-dial call
+type CallOptions struct {
+	// CallerID shown to the caller
+	CallerID string
+	// Timeout to answer the caller, the default of Asterisk when zero
+	Timeout time.Duration
+	// Context where the callee is dialed as extension, when empty the
+	// callee is dialed as a channel with the application Dial
+	Context   string
+	Variables map[string]string
+}
+
+// CallProgress a step of a click-to-dial call
+type CallProgress struct {
+	State CallState
+	// Channel of the leg reporting the step
+	Channel  string
+	UniqueID string
+	// Reason of the failure, the DialStatus or the originate reason
+	Reason string
+	Event  *AMIEvent
+	// Err on CallFailed when the progress is lost, the connection was lost,
+	// the client closed or the caller didn't answer in time, Event is nil
+	Err error
+}
+
+// Call the caller from (SIP/100) and once it answers connect it to the callee
+// to, reporting the progress on the chan returned, it's closed when the call
+// fails or hangs up. The call fails with Err when the connection is lost or
+// the caller doesn't answer within Timeout, or the default of 30 seconds,
+// and a margin. A step is dropped rather than blocking the client when the
+// chan is full
+func (client *AMIClient) Call(from, to string, opts *CallOptions) (<-chan CallProgress, error) {
+	if opts == nil {
+		opts = &CallOptions{}
+	}
+
+	req := OriginateRequest{
+		Channel:     from,
+		Application: "Dial",
+		Data:        to,
+		CallerID:    opts.CallerID,
+		Timeout:     opts.Timeout,
+		Variables:   opts.Variables,
+		ChannelID:   "gami-" + newActionID(),
+	}
+	if opts.Context != "" {
+		req.Application, req.Data = "", ""
+		req.Context, req.Exten = opts.Context, to
+	}
+
+	p := req.params()
+	actionID := client.newActionID()
+	p["ActionID"] = actionID
+
+	f, err := client.follow(p, actionID, req.responseTimeout())
+	if err != nil {
+		return nil, err
+	}
+
+	progress := make(chan CallProgress, 10)
+	go func() {
+		defer close(progress)
+		defer client.unfollow(f, actionID)
+		followCall(actionID, req.ChannelID, f, progress)
+	}()
+
+	return progress, nil
+}
+
+// followCall report the progress of the call originated by actionID whose
+// caller leg has uniqueID until it fails or hangs up
+func followCall(actionID, uniqueID string, f *follower, progress chan<- CallProgress) {
+	var callee string
+	answered, bridged := false, false
+
+	send := func(step CallProgress) {
+		// never blocks the watcher, and so the reader of the client
+		select {
+		case progress <- step:
+		default:
+		}
+	}
+	report := func(state CallState, ev *AMIEvent, reason string) {
+		if state == CallAnswered {
+			f.answered()
+		}
+		send(CallProgress{State: state, Channel: ev.Get("Channel"),
+			UniqueID: ev.Get("Uniqueid"), Reason: reason, Event: ev})
+	}
+
+	for {
+		ev, err := f.next()
+		if err != nil {
+			send(CallProgress{State: CallFailed, UniqueID: uniqueID, Reason: err.Error(), Err: err})
+			return
+		}
+
+		// the OriginateResponse is also routed to the listener of the
+		// action, it's taken from the watcher to keep the order of events
+		if ev.ID == "OriginateResponse" && ev.Get("ActionID") == actionID {
+			result := newOriginateResult(ev)
+			if !result.Success {
+				report(CallFailed, ev, strconv.Itoa(result.Reason))
+				return
+			}
+			if !answered {
+				answered = true
+				report(CallAnswered, ev, "")
+			}
+			continue
+		}
+
+		leg := ev.Get("Uniqueid")
+		if leg != uniqueID && (callee == "" || leg != callee) {
+			continue
+		}
+
+		switch ev.ID {
+		case "Newstate":
+			switch {
+			case ev.Get("ChannelStateDesc") == "Ringing":
+				report(CallRinging, ev, "")
+			case ev.Get("ChannelStateDesc") == "Up" && leg == uniqueID && !answered:
+				answered = true
+				report(CallAnswered, ev, "")
+			}
+		case "DialBegin":
+			if leg == uniqueID {
+				callee = ev.Get("DestUniqueid")
+			}
+		case "DialEnd":
+			if leg != uniqueID {
+				continue
+			}
+			if status := ev.Get("DialStatus"); status != "ANSWER" {
+				report(CallFailed, ev, status)
+				return
+			}
+			if !bridged {
+				bridged = true
+				report(CallBridged, ev, "")
+			}
+		case "BridgeEnter":
+			if leg == callee && !bridged {
+				bridged = true
+				report(CallBridged, ev, "")
+			}
+		case "Hangup":
+			if leg != uniqueID {
+				continue
+			}
+			if bridged {
+				report(CallHungup, ev, ev.Get("Cause-txt"))
+			} else {
+				report(CallFailed, ev, ev.Get("Cause-txt"))
+			}
+			return
+		}
+	}
+}
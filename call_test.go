@@ -0,0 +1,275 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func collectCall(t *testing.T, progress <-chan CallProgress) []CallProgress {
+	var steps []CallProgress
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case step, ok := <-progress:
+			if !ok {
+				return steps
+			}
+			steps = append(steps, step)
+		case <-timeout:
+			t.Fatal("call progress not closed")
+		}
+	}
+}
+
+func TestCall(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.MockList("Originate", func(params textproto.MIMEHeader) []map[string]string {
+		id, caller := params.Get("Actionid"), params.Get("Channelid")
+		if params.Get("Application") != "Dial" || params.Get("Data") != "SIP/200" || params.Get("Async") != "true" {
+			return []map[string]string{{"Response": "Error", "ActionID": id, "Message": "Unexpected originate"}}
+		}
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "Message": "Originate successfully queued"},
+			{"Event": "Newstate", "Channel": "SIP/100-01", "Uniqueid": caller, "ChannelStateDesc": "Ringing"},
+			{"Event": "Newstate", "Channel": "SIP/100-01", "Uniqueid": caller, "ChannelStateDesc": "Up"},
+			{"Event": "OriginateResponse", "ActionID": id, "Response": "Success", "Channel": "SIP/100-01",
+				"Uniqueid": caller, "Reason": "4"},
+			{"Event": "DialBegin", "Channel": "SIP/100-01", "Uniqueid": caller,
+				"DestChannel": "SIP/200-02", "DestUniqueid": "callee"},
+			{"Event": "Newstate", "Channel": "SIP/200-02", "Uniqueid": "callee", "ChannelStateDesc": "Ringing"},
+			{"Event": "Newstate", "Channel": "SIP/300-03", "Uniqueid": "other", "ChannelStateDesc": "Ringing"},
+			{"Event": "DialEnd", "Channel": "SIP/100-01", "Uniqueid": caller, "DialStatus": "ANSWER"},
+			{"Event": "BridgeEnter", "Channel": "SIP/200-02", "Uniqueid": "callee"},
+			{"Event": "Hangup", "Channel": "SIP/100-01", "Uniqueid": caller, "Cause-txt": "Normal Clearing"},
+		}
+	})
+
+	progress, err := ami.Call("SIP/100", "SIP/200", &CallOptions{CallerID: "Click <200>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := collectCall(t, progress)
+	expected := []CallState{CallRinging, CallAnswered, CallRinging, CallBridged, CallHungup}
+	if len(steps) != len(expected) {
+		t.Fatalf("expected %v, got %+v", expected, steps)
+	}
+	for i, step := range steps {
+		if step.State != expected[i] {
+			t.Fatalf("step %d: expected %s, got %s", i, expected[i], step.State)
+		}
+	}
+	if steps[2].Channel != "SIP/200-02" || steps[4].Reason != "Normal Clearing" {
+		t.Fatalf("unexpected steps %+v", steps)
+	}
+}
+
+func TestCallFailed(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.MockList("Originate", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		if params.Get("Context") != "sales" || params.Get("Exten") != "200" || params.Get("Priority") != "1" {
+			return []map[string]string{{"Response": "Error", "ActionID": id, "Message": "Unexpected originate"}}
+		}
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "Message": "Originate successfully queued"},
+			{"Event": "OriginateResponse", "ActionID": id, "Response": "Failure", "Channel": "SIP/100",
+				"Reason": "5"},
+		}
+	})
+
+	progress, err := ami.Call("SIP/100", "200", &CallOptions{Context: "sales"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := collectCall(t, progress)
+	if len(steps) != 1 || steps[0].State != CallFailed || steps[0].Reason != "5" {
+		t.Fatalf("unexpected steps %+v", steps)
+	}
+}
+
+func TestOriginate(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.MockList("Originate", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		if params.Get("Variable") != "A=1,B=2" || params.Get("Timeout") != "30000" {
+			return []map[string]string{{"Response": "Error", "ActionID": id, "Message": "Unexpected originate"}}
+		}
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "Message": "Originate successfully queued"},
+			{"Event": "OriginateResponse", "ActionID": id, "Response": "Success", "Channel": "SIP/100-01",
+				"Uniqueid": "1600000000.1", "Reason": "4"},
+		}
+	})
+
+	result, err := ami.Originate(OriginateRequest{Channel: "SIP/100", Context: "default", Exten: "200",
+		Timeout: 30 * time.Second, Variables: map[string]string{"B": "2", "A": "1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || result.Reason != 4 || result.UniqueID != "1600000000.1" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestOriginateProgress(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.MockList("Originate", func(params textproto.MIMEHeader) []map[string]string {
+		id, channel := params.Get("Actionid"), params.Get("Channelid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "Message": "Originate successfully queued"},
+			{"Event": "DialState", "DestChannel": "SIP/trunk-01", "DestUniqueid": channel, "DialStatus": "RINGING"},
+			{"Event": "Newstate", "Channel": "SIP/trunk-01", "Uniqueid": channel, "ChannelStateDesc": "Ringing"},
+			{"Event": "DialState", "DestChannel": "SIP/trunk-01", "DestUniqueid": channel, "DialStatus": "PROGRESS"},
+			{"Event": "Newstate", "Channel": "SIP/other-02", "Uniqueid": "other", "ChannelStateDesc": "Up"},
+			{"Event": "Newstate", "Channel": "SIP/trunk-01", "Uniqueid": channel, "ChannelStateDesc": "Up"},
+			{"Event": "OriginateResponse", "ActionID": id, "Response": "Success", "Channel": "SIP/trunk-01",
+				"Uniqueid": channel, "Reason": "4"},
+		}
+	})
+
+	steps, err := ami.OriginateProgress(OriginateRequest{Channel: "SIP/trunk/5551234", Context: "default", Exten: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var states []OriginateState
+	var result *OriginateResult
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case step, ok := <-steps:
+			if !ok {
+				done = true
+				break
+			}
+			states = append(states, step.State)
+			if step.State == OriginateDone {
+				result = step.Result
+			}
+		case <-timeout:
+			t.Fatal("originate progress not closed")
+		}
+	}
+
+	expected := []OriginateState{OriginateRinging, OriginateEarlyMedia, OriginateAnswered, OriginateDone}
+	if len(states) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, states)
+	}
+	for i := range states {
+		if states[i] != expected[i] {
+			t.Fatalf("expected %v, got %v", expected, states)
+		}
+	}
+	if result == nil || !result.Success || result.Channel != "SIP/trunk-01" {
+		t.Fatalf("unexpected result %+v", result)
+	}
+}
+
+func TestOriginateProgressConnectionLost(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	srv.MockList("Originate", func(params textproto.MIMEHeader) []map[string]string {
+		id, channel := params.Get("Actionid"), params.Get("Channelid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "Message": "Originate successfully queued"},
+			{"Event": "Newstate", "Channel": "SIP/trunk-01", "Uniqueid": channel, "ChannelStateDesc": "Ringing"},
+		}
+	})
+
+	steps, err := ami.OriginateProgress(OriginateRequest{Channel: "SIP/trunk/5551234", Context: "default", Exten: "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case step := <-steps:
+		if step.State != OriginateRinging {
+			t.Fatal("unexpected step", step.State)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ringing not reported")
+	}
+
+	srv.Drop()
+	var last OriginateStep
+	timeout := time.After(5 * time.Second)
+	for done := false; !done; {
+		select {
+		case step, ok := <-steps:
+			if !ok {
+				done = true
+				break
+			}
+			last = step
+		case <-timeout:
+			t.Fatal("originate progress not closed after the connection was lost")
+		}
+	}
+	if last.State != OriginateDone || last.Err != errConnectionLost || last.Result != nil {
+		t.Fatalf("expected done with the connection lost, got %+v", last)
+	}
+}
+
+func TestOriginateTimeout(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	margin := originateMargin
+	originateMargin = 0
+	defer func() { originateMargin = margin }()
+
+	// the OriginateResponse never comes
+	srv.MockList("Originate", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{{"Response": "Success", "ActionID": params.Get("Actionid")}}
+	})
+
+	if _, err := ami.Originate(OriginateRequest{Channel: "SIP/100", Application: "Wait", Data: "1",
+		Timeout: 200 * time.Millisecond}); err != errOriginateTimeout {
+		t.Fatal("expected originate timeout, got", err)
+	}
+}
+
+func TestCallConnectionLost(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	srv.MockList("Originate", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{{"Response": "Success", "ActionID": params.Get("Actionid")}}
+	})
+
+	progress, err := ami.Call("SIP/100", "SIP/200", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ami.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := collectCall(t, progress)
+	if len(steps) != 1 || steps[0].State != CallFailed || steps[0].Err != errConnectionLost {
+		t.Fatalf("expected the call failed by the connection lost, got %+v", steps)
+	}
+}
@@ -0,0 +1,397 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	defaultCampaignInterval    = time.Second
+	defaultCampaignAbandonRate = 0.03
+	defaultCampaignMaxRatio    = 3
+	defaultCampaignWindow      = 50
+	defaultCampaignCallTimeout = time.Hour
+	// campaignRatioStep change of the dialing ratio on each adjustment
+	campaignRatioStep = 0.1
+	// campaignMinAnswerRate lowest answer rate used to pace, so a bad
+	// start doesn't flood the trunks
+	campaignMinAnswerRate = 0.1
+)
+
+// CampaignOptions knobs of the pacing of a campaign
+type CampaignOptions struct {
+	// Queue whose available members take the answered calls, its
+	// abandoned callers count as abandoned calls. Without a queue MaxCalls
+	// calls are kept in progress
+	Queue string
+	// MaxAbandonRate abandoned of the answered calls tolerated, 3% when 0
+	MaxAbandonRate float64
+	// MaxRatio calls in progress by available agent, 3 when 0
+	MaxRatio float64
+	// MaxCalls in progress at once, from their launch to their Hangup, 0
+	// no limit but the agents
+	MaxCalls int
+	// MaxCallDuration an answered call counts in progress without its
+	// Hangup, as when the connection is lost, an hour when 0
+	MaxCallDuration time.Duration
+	// MaxServerCalls calls on the server by CoreStatus from which no call
+	// is launched, a guard of the load of Asterisk, 0 disable it
+	MaxServerCalls int
+	// Interval of the pacing, a second when 0
+	Interval time.Duration
+	// Window of the last calls giving the answer rate, 50 when 0
+	Window int
+	// OnResult called with the outcome of every call launched
+	OnResult func(req OriginateRequest, result *OriginateResult, err error)
+}
+
+// CampaignStats state of the pacing of a campaign
+type CampaignStats struct {
+	Launched  int
+	InFlight  int
+	Answered  int
+	Failed    int
+	Abandoned int
+	// AnswerRate of the last calls of the window
+	AnswerRate float64
+	// AbandonRate of the answered calls since the start
+	AbandonRate float64
+	// Ratio calls in progress by available agent
+	Ratio float64
+	// Agents available on the last pacing
+	Agents int
+}
+
+// Campaign launch the calls of its leads paced by the answer rate, the
+// agents available and the abandon rate, the loop of a predictive dialer
+type Campaign struct {
+	client *AMIClient
+	opts   CampaignOptions
+	leads  <-chan OriginateRequest
+	feed   *QueueFeed
+	mutex  *sync.Mutex
+	once   *sync.Once
+	calls  *sync.WaitGroup
+	done   chan struct{}
+	// finished closed once the leads are exhausted and the calls ended
+	finished chan struct{}
+
+	stats CampaignStats
+	// answered the outcome of the last calls of the window
+	answered []bool
+	// abandonedBase abandoned callers of the queue before the start
+	abandonedBase int
+}
+
+// RunCampaign originate the leads until the chan is closed or Stop, a
+// call is launched when the pacing allows it
+func (client *AMIClient) RunCampaign(leads <-chan OriginateRequest, opts CampaignOptions) (*Campaign, error) {
+	if leads == nil || (opts.Queue == "" && opts.MaxCalls <= 0) {
+		return nil, errInvalidParams
+	}
+	if opts.MaxAbandonRate <= 0 {
+		opts.MaxAbandonRate = defaultCampaignAbandonRate
+	}
+	if opts.MaxRatio < 1 {
+		opts.MaxRatio = defaultCampaignMaxRatio
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = defaultCampaignInterval
+	}
+	if opts.Window <= 0 {
+		opts.Window = defaultCampaignWindow
+	}
+	if opts.MaxCallDuration <= 0 {
+		opts.MaxCallDuration = defaultCampaignCallTimeout
+	}
+
+	campaign := &Campaign{
+		client:   client,
+		opts:     opts,
+		leads:    leads,
+		mutex:    new(sync.Mutex),
+		once:     new(sync.Once),
+		calls:    new(sync.WaitGroup),
+		done:     make(chan struct{}),
+		finished: make(chan struct{}),
+		stats:    CampaignStats{Ratio: 1},
+	}
+
+	if opts.Queue != "" {
+		feed, err := client.QueueFeed(opts.Interval)
+		if err != nil {
+			return nil, err
+		}
+		campaign.feed = feed
+		campaign.abandonedBase = campaign.queue().Abandoned
+	}
+
+	go campaign.run()
+	return campaign, nil
+}
+
+// Stats return the state of the pacing
+func (campaign *Campaign) Stats() CampaignStats {
+	campaign.mutex.Lock()
+	defer campaign.mutex.Unlock()
+	return campaign.stats
+}
+
+// Finished return a chan closed once the leads are exhausted, or the
+// campaign stopped, and its calls ended
+func (campaign *Campaign) Finished() <-chan struct{} {
+	return campaign.finished
+}
+
+// Stop launching calls, the calls in progress go on
+func (campaign *Campaign) Stop() {
+	campaign.once.Do(func() {
+		close(campaign.done)
+	})
+}
+
+func (campaign *Campaign) run() {
+	defer func() {
+		if campaign.feed != nil {
+			campaign.feed.Stop()
+		}
+		campaign.calls.Wait()
+		close(campaign.finished)
+	}()
+
+	ticker := time.NewTicker(campaign.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		if !campaign.pace() {
+			return
+		}
+		select {
+		case <-campaign.done:
+			return
+		case <-campaign.client.closed:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pace adjust the ratio and launch the calls it allows, false once the
+// leads are exhausted
+func (campaign *Campaign) pace() bool {
+	if campaign.serverBusy() {
+		return true
+	}
+
+	agents := campaign.opts.MaxCalls
+	if campaign.feed != nil {
+		queue := campaign.queue()
+		agents = availableMembers(queue)
+		campaign.mutex.Lock()
+		campaign.stats.Abandoned = queue.Abandoned - campaign.abandonedBase
+		campaign.mutex.Unlock()
+	}
+
+	campaign.mutex.Lock()
+	campaign.stats.Agents = agents
+	campaign.adjust()
+	launch := campaign.launches()
+	campaign.mutex.Unlock()
+
+	for i := 0; i < launch; i++ {
+		select {
+		case req, ok := <-campaign.leads:
+			if !ok {
+				return false
+			}
+			campaign.launch(req)
+		default:
+			// no lead ready, paced again on the next interval
+			return true
+		}
+	}
+	return true
+}
+
+// adjust the dialing ratio to keep the abandon rate under the maximum,
+// with the mutex held
+func (campaign *Campaign) adjust() {
+	stats := &campaign.stats
+	if stats.Answered > 0 {
+		stats.AbandonRate = float64(stats.Abandoned) / float64(stats.Answered)
+	}
+	if len(campaign.answered) > 0 {
+		answered := 0
+		for _, ok := range campaign.answered {
+			if ok {
+				answered++
+			}
+		}
+		stats.AnswerRate = float64(answered) / float64(len(campaign.answered))
+	}
+
+	switch {
+	case stats.AbandonRate > campaign.opts.MaxAbandonRate:
+		stats.Ratio -= campaignRatioStep
+	case stats.Answered > 0 && stats.AbandonRate < campaign.opts.MaxAbandonRate/2:
+		stats.Ratio += campaignRatioStep
+	}
+	if stats.Ratio < 1 {
+		stats.Ratio = 1
+	}
+	if stats.Ratio > campaign.opts.MaxRatio {
+		stats.Ratio = campaign.opts.MaxRatio
+	}
+}
+
+// launches calls to launch now so the expected answers meet the agents
+// available, with the mutex held
+func (campaign *Campaign) launches() int {
+	stats := campaign.stats
+	wanted := stats.Agents
+	if campaign.feed != nil {
+		answerRate := 1.0
+		if len(campaign.answered) > 0 {
+			answerRate = stats.AnswerRate
+			if answerRate < campaignMinAnswerRate {
+				answerRate = campaignMinAnswerRate
+			}
+		}
+		// the ratio grows up to MaxRatio calls by agent
+		expected := float64(stats.Agents) * stats.Ratio
+		if perAnswer := float64(stats.Agents) / answerRate; perAnswer < expected {
+			expected = perAnswer
+		}
+		wanted = int(expected)
+	}
+	if campaign.opts.MaxCalls > 0 && wanted > campaign.opts.MaxCalls {
+		wanted = campaign.opts.MaxCalls
+	}
+	if launch := wanted - stats.InFlight; launch > 0 {
+		return launch
+	}
+	return 0
+}
+
+// serverBusy check the calls on the server against MaxServerCalls
+func (campaign *Campaign) serverBusy() bool {
+	if campaign.opts.MaxServerCalls <= 0 {
+		return false
+	}
+	status, err := campaign.client.CoreStatus()
+	if err != nil {
+		campaign.client.logf("gami: campaign core status: %s", err)
+		return true
+	}
+	return status.CurrentCalls >= campaign.opts.MaxServerCalls
+}
+
+// queue return the queue of the campaign from its feed
+func (campaign *Campaign) queue() Queue {
+	for _, queue := range campaign.feed.Snapshot().Queues {
+		if queue.Name == campaign.opts.Queue {
+			return queue
+		}
+	}
+	return Queue{Name: campaign.opts.Queue}
+}
+
+// availableMembers members of the queue not in use and not paused
+func availableMembers(queue Queue) int {
+	available := 0
+	for _, member := range queue.Members {
+		// AST_DEVICE_NOT_INUSE
+		if member.Status == 1 && !member.Paused {
+			available++
+		}
+	}
+	return available
+}
+
+// launch originate the lead, recording its outcome, the call is in flight
+// until its Hangup
+func (campaign *Campaign) launch(req OriginateRequest) {
+	campaign.mutex.Lock()
+	campaign.stats.Launched++
+	campaign.stats.InFlight++
+	campaign.mutex.Unlock()
+
+	if req.ChannelID == "" {
+		req.ChannelID = "gami-" + newActionID()
+	}
+	// watched before the originate so a short call isn't missed
+	hungup, stop := campaign.client.hangupOf(req.ChannelID)
+
+	campaign.calls.Add(1)
+	go func() {
+		defer campaign.calls.Done()
+		defer stop()
+		// Originate is bounded by the timeout to answer of req
+		result, err := campaign.client.Originate(req)
+
+		answered := err == nil && result.Success
+		campaign.mutex.Lock()
+		if answered {
+			campaign.stats.Answered++
+		} else {
+			campaign.stats.Failed++
+		}
+		campaign.answered = append(campaign.answered, answered)
+		if len(campaign.answered) > campaign.opts.Window {
+			campaign.answered = campaign.answered[1:]
+		}
+		campaign.mutex.Unlock()
+
+		if campaign.opts.OnResult != nil {
+			campaign.opts.OnResult(req, result, err)
+		}
+
+		if answered {
+			timer := time.NewTimer(campaign.opts.MaxCallDuration)
+			select {
+			case <-hungup:
+			case <-timer.C:
+				campaign.client.logf("gami: campaign call %s without Hangup after %s", req.ChannelID, campaign.opts.MaxCallDuration)
+			}
+			timer.Stop()
+		}
+
+		campaign.mutex.Lock()
+		campaign.stats.InFlight--
+		campaign.mutex.Unlock()
+	}()
+}
+
+// hangupOf return a chan closed when the channel uniqueID hangs up, the
+// connection is replaced or the client closes, and the func to stop
+// waiting for it
+func (client *AMIClient) hangupOf(uniqueID string) (<-chan struct{}, func()) {
+	watcher := client.watch()
+	_, _, changed := client.connection()
+	hungup := make(chan struct{})
+
+	go func() {
+		defer close(hungup)
+		defer client.unwatch(watcher)
+		for {
+			select {
+			case ev := <-watcher.events:
+				if ev.ID == "Hangup" && ev.Params["Uniqueid"] == uniqueID {
+					return
+				}
+			case <-watcher.done:
+				return
+			case <-changed:
+				return
+			case <-client.closed:
+				return
+			}
+		}
+	}()
+
+	return hungup, func() { client.unwatch(watcher) }
+}
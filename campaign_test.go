@@ -0,0 +1,203 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCampaign(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	go func() {
+		for range ami.Events {
+		}
+	}()
+
+	var inFlight, maxInFlight int32
+	srv.MockList("Originate", func(params textproto.MIMEHeader) []map[string]string {
+		if n := atomic.AddInt32(&inFlight, 1); n > atomic.LoadInt32(&maxInFlight) {
+			atomic.StoreInt32(&maxInFlight, n)
+		}
+		time.Sleep(50 * time.Millisecond)
+		atomic.AddInt32(&inFlight, -1)
+
+		id := params.Get("Actionid")
+		response := "Success"
+		if params.Get("Channel") == "SIP/busy" {
+			response = "Failure"
+		}
+		channel := params.Get("Channelid")
+		frames := []map[string]string{
+			{"Response": "Success", "ActionID": id, "Message": "Originate successfully queued"},
+			{"Event": "OriginateResponse", "ActionID": id, "Response": response, "Channel": params.Get("Channel"),
+				"Uniqueid": channel},
+		}
+		if response == "Success" {
+			frames = append(frames, map[string]string{"Event": "Hangup", "Uniqueid": channel})
+		}
+		return frames
+	})
+
+	if _, err := ami.RunCampaign(make(chan OriginateRequest), CampaignOptions{}); err != errInvalidParams {
+		t.Fatal("expected errInvalidParams without queue nor MaxCalls, got", err)
+	}
+
+	leads := make(chan OriginateRequest, 5)
+	for _, channel := range []string{"SIP/100", "SIP/busy", "SIP/101", "SIP/102", "SIP/103"} {
+		leads <- OriginateRequest{Channel: channel, Context: "campaign", Exten: "s"}
+	}
+	close(leads)
+
+	mutex := &sync.Mutex{}
+	results := make(map[string]bool)
+	campaign, err := ami.RunCampaign(leads, CampaignOptions{
+		MaxCalls: 2,
+		Interval: 10 * time.Millisecond,
+		OnResult: func(req OriginateRequest, result *OriginateResult, err error) {
+			mutex.Lock()
+			results[req.Channel] = err == nil && result.Success
+			mutex.Unlock()
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-campaign.Finished():
+	case <-time.After(5 * time.Second):
+		t.Fatal("campaign not finished", campaign.Stats())
+	}
+
+	stats := campaign.Stats()
+	if stats.Launched != 5 || stats.Answered != 4 || stats.Failed != 1 || stats.InFlight != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if max := atomic.LoadInt32(&maxInFlight); max > 2 {
+		t.Fatal("more calls in progress than MaxCalls", max)
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(results) != 5 || results["SIP/busy"] || !results["SIP/103"] {
+		t.Fatal("unexpected results", results)
+	}
+}
+
+func TestCampaignPacing(t *testing.T) {
+	campaign := &Campaign{
+		opts:  CampaignOptions{Queue: "sales", MaxAbandonRate: 0.1, MaxRatio: 3, Window: 10},
+		feed:  &QueueFeed{},
+		stats: CampaignStats{Ratio: 1, Agents: 4},
+	}
+
+	// without results a call by agent
+	campaign.adjust()
+	if launch := campaign.launches(); launch != 4 {
+		t.Fatal("expected a call by agent, got", launch)
+	}
+
+	// half of the calls answered, no abandon: the ratio grows
+	campaign.answered = []bool{true, false, true, false}
+	campaign.stats.Answered = 2
+	campaign.stats.InFlight = 2
+	campaign.adjust()
+	if campaign.stats.Ratio <= 1 || campaign.stats.AnswerRate != 0.5 {
+		t.Fatalf("unexpected pacing %+v", campaign.stats)
+	}
+	// 4 agents at ratio 1.1 with 2 calls in progress
+	if launch := campaign.launches(); launch != 2 {
+		t.Fatal("unexpected launches", launch)
+	}
+
+	// too many abandoned: the ratio goes down, not under 1
+	campaign.stats.Abandoned = 1
+	for i := 0; i < 5; i++ {
+		campaign.adjust()
+	}
+	if campaign.stats.Ratio != 1 || campaign.stats.AbandonRate != 0.5 {
+		t.Fatalf("unexpected pacing %+v", campaign.stats)
+	}
+
+	// no agent, no call
+	campaign.stats.Agents = 0
+	if launch := campaign.launches(); launch != 0 {
+		t.Fatal("calls launched without agents", launch)
+	}
+}
+
+func TestCampaignCallsUntilHangup(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	go func() {
+		for range ami.Events {
+		}
+	}()
+
+	// the calls are answered and go on until hung up by the test
+	channels := make(chan string, 2)
+	srv.MockList("Originate", func(params textproto.MIMEHeader) []map[string]string {
+		id, channel := params.Get("Actionid"), params.Get("Channelid")
+		channels <- channel
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "Message": "Originate successfully queued"},
+			{"Event": "OriginateResponse", "ActionID": id, "Response": "Success", "Uniqueid": channel},
+		}
+	})
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "Hangup", "Uniqueid": params.Get("Uniqueid")},
+		}
+	})
+	hangup := func(channel string) {
+		if _, err := ami.syncAction(Params{"Action": "UserEvent", "UserEvent": "hangup", "Uniqueid": channel}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	leads := make(chan OriginateRequest, 2)
+	leads <- OriginateRequest{Channel: "SIP/100", Context: "campaign", Exten: "s"}
+	leads <- OriginateRequest{Channel: "SIP/101", Context: "campaign", Exten: "s"}
+	close(leads)
+
+	campaign, err := ami.RunCampaign(leads, CampaignOptions{MaxCalls: 1, Interval: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first := <-channels
+	// answered but not hung up, the call is still in progress
+	time.Sleep(200 * time.Millisecond)
+	if stats := campaign.Stats(); stats.Launched != 1 || stats.InFlight != 1 || stats.Answered != 1 {
+		t.Fatalf("expected the answered call in progress, got %+v", stats)
+	}
+
+	hangup(first)
+	var second string
+	select {
+	case second = <-channels:
+	case <-time.After(5 * time.Second):
+		t.Fatal("next call not launched after the hangup", campaign.Stats())
+	}
+	hangup(second)
+
+	select {
+	case <-campaign.Finished():
+	case <-time.After(5 * time.Second):
+		t.Fatal("campaign not finished", campaign.Stats())
+	}
+	if stats := campaign.Stats(); stats.Launched != 2 || stats.InFlight != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
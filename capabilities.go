@@ -0,0 +1,121 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrModuleMissing returned by the helpers when the server doesn't have
+// the action they need, because the module providing it isn't loaded
+var ErrModuleMissing = errors.New("Module providing the action not loaded")
+
+// helperActions actions needed by the helpers of the client
+var helperActions = map[string][]string{
+	"AsyncAGI":         {"AGI"},
+	"Call":             {"Originate"},
+	"Channels":         {"CoreShowChannels"},
+	"ChannelVariables": {"Status", "GetVar"},
+	"Conference":       {"ConfbridgeList", "ConfbridgeKick", "ConfbridgeMute"},
+	"CoreStatus":       {"CoreStatus"},
+	"ExtensionState":   {"ExtensionState"},
+	"MailboxCount":     {"MailboxCount"},
+	"Originate":        {"Originate"},
+	"PresenceState":    {"PresenceState"},
+	"QualifyPeers":     {"SIPqualifypeer", "PJSIPQualify"},
+	"QueueFeed":        {"QueueStatus"},
+	"QueueStatus":      {"QueueStatus"},
+	"ShowDialplan":     {"ShowDialPlan"},
+	"SIPPeers":         {"SIPpeers"},
+	"TrackChannels":    {"CoreShowChannels"},
+}
+
+// Capabilities actions available on the server, as listed by ListCommands
+type Capabilities struct {
+	// actions names in lower case
+	actions map[string]bool
+}
+
+// DetectCapabilities list the actions of the server with ListCommands,
+// from then the helpers needing an action the server doesn't have fail
+// with ErrModuleMissing without sending it. Call it again after loading
+// or unloading modules
+func (client *AMIClient) DetectCapabilities() (*Capabilities, error) {
+	client.mutexConfig.Lock()
+	client.capabilities = nil
+	client.mutexConfig.Unlock()
+
+	resp, err := client.syncAction(Params{"Action": "ListCommands"})
+	if err != nil {
+		return nil, err
+	}
+
+	caps := &Capabilities{actions: make(map[string]bool, len(resp.Params))}
+	for action := range resp.Params {
+		if action == "Actionid" || action == "Message" {
+			continue
+		}
+		caps.actions[strings.ToLower(action)] = true
+	}
+
+	client.mutexConfig.Lock()
+	client.capabilities = caps
+	client.mutexConfig.Unlock()
+	return caps, nil
+}
+
+// HasAction check if the server has the action, ignoring case
+func (caps *Capabilities) HasAction(action string) bool {
+	return caps.actions[strings.ToLower(action)]
+}
+
+// Usable check if the server has an action needed by the helper named as
+// its method, like SIPPeers or QueueFeed, true for helpers needing no
+// specific action
+func (caps *Capabilities) Usable(helper string) bool {
+	actions, ok := helperActions[helper]
+	if !ok {
+		return true
+	}
+	for _, action := range actions {
+		if caps.HasAction(action) {
+			return true
+		}
+	}
+	return false
+}
+
+// ModuleLoaded check with ModuleCheck if module, like chan_pjsip, is loaded
+func (client *AMIClient) ModuleLoaded(module string) (bool, error) {
+	_, err := client.syncAction(Params{"Action": "ModuleCheck", "Module": module})
+	if err != nil {
+		if _, ok := err.(*ResponseError); ok {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// checkCapability return ErrModuleMissing when the capabilities were
+// detected and the server doesn't have the action
+func (client *AMIClient) checkCapability(action string) error {
+	client.mutexConfig.RLock()
+	caps := client.capabilities
+	client.mutexConfig.RUnlock()
+
+	if caps == nil || caps.HasAction(action) {
+		return nil
+	}
+	return ErrModuleMissing
+}
+
+// unknownCommand check if the error is Asterisk refusing an action it
+// doesn't have
+func unknownCommand(err error) bool {
+	respErr, ok := err.(*ResponseError)
+	return ok && respErr.Code() == CodeInvalidCommand
+}
@@ -0,0 +1,84 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+)
+
+func TestDetectCapabilities(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	sent := make(chan string, 10)
+	srv.Mock("ListCommands", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid"),
+			"Ping":         "Keepalive command (Priv: <none>)",
+			"PJSIPQualify": "Qualify a chan_pjsip endpoint. (Priv: system,reporting,all)",
+			"CoreStatus":   "Show PBX core status variables. (Priv: system,reporting,all)"}
+	})
+	srv.Mock("CoreStatus", func(params textproto.MIMEHeader) map[string]string {
+		sent <- "CoreStatus"
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid")}
+	})
+	srv.Mock("SIPpeers", func(params textproto.MIMEHeader) map[string]string {
+		sent <- "SIPpeers"
+		return map[string]string{"Response": "Error", "ActionID": params.Get("Actionid"),
+			"Message": "Invalid/unknown command: SIPpeers. Use Action: ListCommands to show available commands."}
+	})
+
+	// without capabilities the error of Asterisk is translated
+	if _, err := ami.SIPPeers(); err != ErrModuleMissing {
+		t.Fatal("expected module missing, got", err)
+	}
+	if <-sent != "SIPpeers" {
+		t.Fatal("expected SIPpeers sent")
+	}
+
+	caps, err := ami.DetectCapabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !caps.HasAction("ping") || caps.HasAction("SIPpeers") {
+		t.Fatal("unexpected actions")
+	}
+	if !caps.Usable("QualifyPeers") || !caps.Usable("CoreStatus") || caps.Usable("SIPPeers") ||
+		caps.Usable("Conference") || !caps.Usable("Unknown") {
+		t.Fatal("unexpected usable helpers")
+	}
+
+	if _, err := ami.SIPPeers(); err != ErrModuleMissing {
+		t.Fatal("expected module missing, got", err)
+	}
+	if _, err := ami.CoreStatus(); err != nil {
+		t.Fatal(err)
+	}
+	if action := <-sent; action != "CoreStatus" {
+		t.Fatalf("expected only CoreStatus sent, got %s", action)
+	}
+}
+
+func TestModuleLoaded(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.Mock("ModuleCheck", func(params textproto.MIMEHeader) map[string]string {
+		if params.Get("Module") == "chan_pjsip" {
+			return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid")}
+		}
+		return map[string]string{"Response": "Error", "ActionID": params.Get("Actionid"),
+			"Message": "Module not loaded"}
+	})
+
+	if loaded, err := ami.ModuleLoaded("chan_pjsip"); err != nil || !loaded {
+		t.Fatal("expected chan_pjsip loaded", err)
+	}
+	if loaded, err := ami.ModuleLoaded("chan_skinny"); err != nil || loaded {
+		t.Fatal("expected chan_skinny not loaded", err)
+	}
+}
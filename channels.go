@@ -0,0 +1,193 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"context"
+	"time"
+)
+
+// ChannelEntry a channel listed by CoreShowChannels
+type ChannelEntry struct {
+	Channel           string
+	UniqueID          string
+	LinkedID          string
+	State             string
+	CallerIDNum       string
+	CallerIDName      string
+	ConnectedLineNum  string
+	ConnectedLineName string
+	AccountCode       string
+	Context           string
+	Exten             string
+	Priority          int
+	Application       string
+	ApplicationData   string
+	Duration          time.Duration
+	BridgeID          string
+}
+
+// ChannelStream channels listed by Channels as they are read
+type ChannelStream struct {
+	entries chan ChannelEntry
+	done    chan struct{}
+	err     error
+	total   int
+	// timeout waiting for the next event of the list
+	timeout time.Duration
+}
+
+// Channels run CoreShowChannels sending each channel on the stream as it's
+// read, without keeping the list in memory. The stream is closed when the
+// list is complete, ctx is done, the client is closed, the connection is
+// lost or no channel of the list comes within 30 seconds, Err tells which
+func (client *AMIClient) Channels(ctx context.Context) (*ChannelStream, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	actionID := client.newActionID()
+	listener := client.listen(actionID)
+	if _, err := client.syncAction(Params{"Action": "CoreShowChannels", "ActionID": actionID}); err != nil {
+		client.unlisten(actionID)
+		return nil, err
+	}
+
+	stream := &ChannelStream{
+		entries: make(chan ChannelEntry),
+		done:    make(chan struct{}),
+		timeout: listTimeout,
+	}
+	go stream.run(ctx, client, actionID, listener)
+	return stream, nil
+}
+
+// Entries of the stream, closed at the end of the list
+func (stream *ChannelStream) Entries() <-chan ChannelEntry {
+	return stream.entries
+}
+
+// Err return why the stream was closed, nil when the list was complete,
+// wait for the close when called before
+func (stream *ChannelStream) Err() error {
+	<-stream.done
+	return stream.err
+}
+
+// Total return the channels the server listed, known once the list is
+// complete
+func (stream *ChannelStream) Total() int {
+	<-stream.done
+	return stream.total
+}
+
+// run send the entries read by the listener until the list is complete, on
+// cancel the stream is closed and the rest of the list is discarded so it
+// doesn't reach Events
+func (stream *ChannelStream) run(ctx context.Context, client *AMIClient, actionID string, listener *eventListener) {
+	defer client.unlisten(actionID)
+
+	// the time waiting for the reader of the stream doesn't count
+	timer := time.NewTimer(stream.timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case ev := <-listener.events:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			if completesList(ev) {
+				stream.total = ev.GetInt("ListItems")
+				stream.close(nil)
+				return
+			}
+			if ev.ID != "CoreShowChannel" {
+				continue
+			}
+			select {
+			case stream.entries <- newChannelEntry(ev):
+			case <-ctx.Done():
+				stream.close(ctx.Err())
+				stream.discard(client, listener)
+				return
+			case <-listener.done:
+				stream.close(errConnectionLost)
+				return
+			case <-client.closed:
+				stream.close(errClientClosed)
+				return
+			}
+			timer.Reset(stream.timeout)
+		case <-ctx.Done():
+			stream.close(ctx.Err())
+			stream.discard(client, listener)
+			return
+		case <-listener.done:
+			stream.close(errConnectionLost)
+			return
+		case <-timer.C:
+			stream.close(errListTimeout)
+			return
+		case <-client.closed:
+			stream.close(errClientClosed)
+			return
+		}
+	}
+}
+
+// close the stream with err
+func (stream *ChannelStream) close(err error) {
+	stream.err = err
+	close(stream.entries)
+	close(stream.done)
+}
+
+// discard the events of the list until it's complete, the connection is
+// lost or no event comes within the timeout
+func (stream *ChannelStream) discard(client *AMIClient, listener *eventListener) {
+	timer := time.NewTimer(stream.timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case ev := <-listener.events:
+			if completesList(ev) {
+				return
+			}
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(stream.timeout)
+		case <-listener.done:
+			return
+		case <-timer.C:
+			return
+		case <-client.closed:
+			return
+		}
+	}
+}
+
+func newChannelEntry(ev *AMIEvent) ChannelEntry {
+	return ChannelEntry{
+		Channel:           ev.Get("Channel"),
+		UniqueID:          ev.Get("Uniqueid"),
+		LinkedID:          ev.Get("Linkedid"),
+		State:             ev.Get("ChannelStateDesc"),
+		CallerIDNum:       ev.Get("CallerIDNum"),
+		CallerIDName:      ev.Get("CallerIDName"),
+		ConnectedLineNum:  ev.Get("ConnectedLineNum"),
+		ConnectedLineName: ev.Get("ConnectedLineName"),
+		AccountCode:       ev.Get("AccountCode"),
+		Context:           ev.Get("Context"),
+		Exten:             ev.Get("Exten"),
+		Priority:          ev.GetInt("Priority"),
+		Application:       ev.Get("Application"),
+		ApplicationData:   ev.Get("ApplicationData"),
+		Duration:          ev.GetDuration("Duration"),
+		BridgeID:          ev.Get("BridgeId"),
+	}
+}
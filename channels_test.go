@@ -0,0 +1,155 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"context"
+	"fmt"
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func mockChannels(srv *amiServer, count int) {
+	srv.MockList("CoreShowChannels", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		frames := []map[string]string{{"Response": "Success", "ActionID": id, "EventList": "start"}}
+		for i := 0; i < count; i++ {
+			frames = append(frames, map[string]string{"Event": "CoreShowChannel", "ActionID": id,
+				"Channel": fmt.Sprintf("PJSIP/%d-01", i), "Uniqueid": fmt.Sprintf("%d.1", i),
+				"ChannelStateDesc": "Up", "Priority": "3", "Duration": "00:01:05", "BridgeId": "b1"})
+		}
+		return append(frames, map[string]string{"Event": "CoreShowChannelsComplete", "ActionID": id,
+			"EventList": "Complete", "ListItems": fmt.Sprint(count)})
+	})
+}
+
+func TestChannels(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	mockChannels(srv, 500)
+
+	stream, err := ami.Channels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count := 0
+	for entry := range stream.Entries() {
+		if count == 0 && (entry.Channel != "PJSIP/0-01" || entry.Priority != 3 ||
+			entry.Duration != 65*time.Second || entry.BridgeID != "b1" || entry.State != "Up") {
+			t.Fatalf("unexpected entry %+v", entry)
+		}
+		count++
+	}
+	if err := stream.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if count != 500 || stream.Total() != 500 {
+		t.Fatalf("expected 500 channels, got %d of %d", count, stream.Total())
+	}
+}
+
+func TestChannelsCanceled(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	mockChannels(srv, 500)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stream, err := ami.Channels(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		<-stream.Entries()
+	}
+	cancel()
+
+	for range stream.Entries() {
+	}
+	if err := stream.Err(); err != context.Canceled {
+		t.Fatal("expected canceled, got", err)
+	}
+
+	// the rest of the list doesn't reach Events
+	timeout := time.After(300 * time.Millisecond)
+	for {
+		select {
+		case ev := <-ami.Events:
+			if ev.ID == "CoreShowChannel" {
+				t.Fatal("entry of the canceled list on Events")
+			}
+		case <-timeout:
+			return
+		}
+	}
+}
+
+func TestChannelsConnectionLost(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	// the list never completes
+	srv.MockList("CoreShowChannels", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "CoreShowChannel", "ActionID": id, "Channel": "PJSIP/100-01", "Uniqueid": "1.1"},
+		}
+	})
+
+	stream, err := ami.Channels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case entry := <-stream.Entries():
+		if entry.UniqueID != "1.1" {
+			t.Fatal("unexpected entry", entry)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("entry not streamed")
+	}
+
+	srv.Drop()
+	select {
+	case _, ok := <-stream.Entries():
+		if ok {
+			t.Fatal("unexpected entry")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stream not closed after the connection was lost")
+	}
+	if err := stream.Err(); err != errConnectionLost {
+		t.Fatal("expected connection lost, got", err)
+	}
+}
+
+func TestChannelsTimeout(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	timeout := listTimeout
+	listTimeout = 200 * time.Millisecond
+	defer func() { listTimeout = timeout }()
+
+	srv.MockList("CoreShowChannels", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{{"Response": "Success", "ActionID": params.Get("Actionid"), "EventList": "start"}}
+	})
+
+	stream, err := ami.Channels(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for range stream.Entries() {
+	}
+	if err := stream.Err(); err != errListTimeout {
+		t.Fatal("expected list timeout, got", err)
+	}
+}
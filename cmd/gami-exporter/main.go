@@ -0,0 +1,77 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+// Command gami-exporter serves Prometheus metrics and a JSON snapshot of the
+// channels, SIP peers and queues of one or more Asterisk servers.
+//
+//	gami-exporter -listen :9099 -server admin:secret@pbx1:5038 -server admin:secret@pbx2:5038
+//
+// The metrics are served on /metrics and the snapshot on /snapshot.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/googolgl/gami/cmd/internal/server"
+)
+
+// serverList flag repeatable of servers user:secret@host:port
+type serverList []string
+
+func (l *serverList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *serverList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func main() {
+	var servers serverList
+	listen := flag.String("listen", ":9099", "address to serve the metrics")
+	interval := flag.Duration("interval", 15*time.Second, "interval to poll the SIP peers")
+	flag.Var(&servers, "server", "Asterisk to monitor as user:secret@host:port, repeatable")
+	flag.Parse()
+
+	if len(servers) == 0 {
+		log.Fatal("gami-exporter: at least one -server is required")
+	}
+
+	var monitors []*monitor
+	for _, s := range servers {
+		target, err := server.Parse(s)
+		if err != nil {
+			log.Fatalf("gami-exporter: %v", err)
+		}
+		m := newMonitor(target, *interval)
+		go m.run()
+		monitors = append(monitors, m)
+	}
+
+	snapshots := func() []serverSnapshot {
+		snapshots := make([]serverSnapshot, 0, len(monitors))
+		for _, m := range monitors {
+			snapshots = append(snapshots, m.snapshot())
+		}
+		return snapshots
+	}
+
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		writeMetrics(w, snapshots())
+	})
+	http.HandleFunc("/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(snapshots())
+	})
+
+	log.Printf("gami-exporter: serving on %s", *listen)
+	log.Fatal(http.ListenAndServe(*listen, nil))
+}
@@ -0,0 +1,132 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sample a value of a metric with its labels as name, value pairs
+type sample struct {
+	labels []string
+	value  float64
+}
+
+// metric a family of samples on the Prometheus text format
+type metric struct {
+	name    string
+	help    string
+	kind    string
+	samples []sample
+}
+
+func (m *metric) add(value float64, labels ...string) {
+	m.samples = append(m.samples, sample{labels: labels, value: value})
+}
+
+// writeMetrics write the metrics of the servers on the Prometheus text format
+func writeMetrics(w io.Writer, snapshots []serverSnapshot) {
+	up := &metric{name: "asterisk_up", help: "Whether the AMI session with the server is up.", kind: "gauge"}
+	channels := &metric{name: "asterisk_channels", help: "Channels by state.", kind: "gauge"}
+	calls := &metric{name: "asterisk_calls", help: "Calls, channels sharing a Linkedid.", kind: "gauge"}
+	peers := &metric{name: "asterisk_sip_peer_up", help: "Whether the SIP peer is reachable.", kind: "gauge"}
+	queueCalls := &metric{name: "asterisk_queue_calls", help: "Callers waiting on the queue.", kind: "gauge"}
+	queueWait := &metric{name: "asterisk_queue_longest_wait_seconds", help: "Wait of the oldest caller on the queue.", kind: "gauge"}
+	queueCompleted := &metric{name: "asterisk_queue_completed_total", help: "Calls answered by the queue.", kind: "counter"}
+	queueAbandoned := &metric{name: "asterisk_queue_abandoned_total", help: "Calls abandoned on the queue.", kind: "counter"}
+	queueMembers := &metric{name: "asterisk_queue_members", help: "Members of the queue by paused.", kind: "gauge"}
+
+	for _, snapshot := range snapshots {
+		server := snapshot.Server
+		up.add(boolValue(snapshot.Up), "server", server)
+		if !snapshot.Up {
+			continue
+		}
+
+		byState := make(map[string]int)
+		for _, channel := range snapshot.Channels {
+			byState[channel.State]++
+		}
+		for _, state := range sortedKeys(byState) {
+			channels.add(float64(byState[state]), "server", server, "state", state)
+		}
+		calls.add(float64(len(snapshot.Calls)), "server", server)
+
+		for _, peer := range snapshot.Peers {
+			peers.add(boolValue(strings.HasPrefix(peer.Status, "OK")), "server", server, "peer", peer.Name)
+		}
+
+		for _, queue := range snapshot.Queues {
+			var longest time.Duration
+			for _, entry := range queue.Entries {
+				if entry.Wait > longest {
+					longest = entry.Wait
+				}
+			}
+			paused := 0
+			for _, member := range queue.Members {
+				if member.Paused {
+					paused++
+				}
+			}
+			queueCalls.add(float64(queue.Calls), "server", server, "queue", queue.Name)
+			queueWait.add(longest.Seconds(), "server", server, "queue", queue.Name)
+			queueCompleted.add(float64(queue.Completed), "server", server, "queue", queue.Name)
+			queueAbandoned.add(float64(queue.Abandoned), "server", server, "queue", queue.Name)
+			queueMembers.add(float64(len(queue.Members)-paused), "server", server, "queue", queue.Name, "paused", "false")
+			queueMembers.add(float64(paused), "server", server, "queue", queue.Name, "paused", "true")
+		}
+	}
+
+	for _, m := range []*metric{up, channels, calls, peers, queueCalls, queueWait, queueCompleted, queueAbandoned, queueMembers} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.kind)
+		for _, s := range m.samples {
+			fmt.Fprintf(w, "%s%s %s\n", m.name, formatLabels(s.labels), strconv.FormatFloat(s.value, 'g', -1, 64))
+		}
+	}
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := 0; i+1 < len(labels); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(labels[i])
+		b.WriteString(`="`)
+		b.WriteString(labelEscaper.Replace(labels[i+1]))
+		b.WriteByte('"')
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,58 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/googolgl/gami"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	var out bytes.Buffer
+	writeMetrics(&out, []serverSnapshot{
+		{
+			Server: "pbx1:5038",
+			Up:     true,
+			Channels: []gami.Channel{
+				{Name: "SIP/100-01", State: "Up"}, {Name: "SIP/200-02", State: "Up"}, {Name: "SIP/300-03", State: "Ringing"},
+			},
+			Calls: []gami.Call{{LinkedID: "1"}, {LinkedID: "2"}},
+			Peers: []gami.SIPPeer{{Name: "100", Status: "OK (5 ms)"}, {Name: "trunk", Status: "UNREACHABLE"}},
+			Queues: []gami.Queue{{
+				Name: "support", Calls: 2, Completed: 10, Abandoned: 1,
+				Members: []gami.QueueMember{{Name: "Alice", Paused: true}, {Name: "Bob"}},
+				Entries: []gami.QueueEntry{{Wait: 30 * time.Second}, {Wait: 90 * time.Second}},
+			}},
+		},
+		{Server: `pbx"2`, Up: false},
+	})
+
+	metrics := out.String()
+	for _, line := range []string{
+		`# TYPE asterisk_up gauge`,
+		`asterisk_up{server="pbx1:5038"} 1`,
+		`asterisk_up{server="pbx\"2"} 0`,
+		`asterisk_channels{server="pbx1:5038",state="Ringing"} 1`,
+		`asterisk_channels{server="pbx1:5038",state="Up"} 2`,
+		`asterisk_calls{server="pbx1:5038"} 2`,
+		`asterisk_sip_peer_up{server="pbx1:5038",peer="100"} 1`,
+		`asterisk_sip_peer_up{server="pbx1:5038",peer="trunk"} 0`,
+		`asterisk_queue_longest_wait_seconds{server="pbx1:5038",queue="support"} 90`,
+		`# TYPE asterisk_queue_completed_total counter`,
+		`asterisk_queue_abandoned_total{server="pbx1:5038",queue="support"} 1`,
+		`asterisk_queue_members{server="pbx1:5038",queue="support",paused="true"} 1`,
+	} {
+		if !strings.Contains(metrics, line+"\n") {
+			t.Errorf("missing %s on\n%s", line, metrics)
+		}
+	}
+	if strings.Contains(metrics, `asterisk_calls{server="pbx\"2"}`) {
+		t.Error("metrics of a server down")
+	}
+}
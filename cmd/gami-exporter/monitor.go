@@ -0,0 +1,170 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package main
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"github.com/googolgl/gami"
+	"github.com/googolgl/gami/cmd/internal/server"
+)
+
+// serverSnapshot state of a server served on /snapshot
+type serverSnapshot struct {
+	Server   string
+	Up       bool
+	Channels []gami.Channel
+	Calls    []gami.Call
+	Peers    []gami.SIPPeer
+	Queues   []gami.Queue
+}
+
+// monitor keeps the state of a server with the trackers of gami
+type monitor struct {
+	target   server.Target
+	interval time.Duration
+
+	mutex   sync.Mutex
+	up      bool
+	tracker *gami.ChannelTracker
+	feed    *gami.QueueFeed
+	peers   []gami.SIPPeer
+}
+
+func newMonitor(t server.Target, interval time.Duration) *monitor {
+	return &monitor{target: t, interval: interval}
+}
+
+// run connect to the server and keep the state until the process ends,
+// reconnecting when the connection is lost
+func (m *monitor) run() {
+	for {
+		client, err := gami.Dial(m.target.Address, gami.ReadOnly)
+		if err != nil {
+			log.Printf("gami-exporter: %s: %v", m.target.Address, err)
+			time.Sleep(m.interval)
+			continue
+		}
+
+		m.follow(client)
+		client.Close()
+		m.stopTrackers()
+	}
+}
+
+// follow log in and keep the state of the server until the connection is
+// lost, the events are consumed all along so the client never blocks
+func (m *monitor) follow(client *gami.AMIClient) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-client.Events:
+			case err := <-client.Error:
+				log.Printf("gami-exporter: %s: %v", m.target.Address, err)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	client.Run()
+	failed := make(chan struct{})
+	go func() {
+		// the actions fail once the connection is lost, ending the worker
+		if err := client.Login(m.target.Username, m.target.Secret); err != nil {
+			log.Printf("gami-exporter: %s: %v", m.target.Address, err)
+			close(failed)
+			return
+		}
+		m.start(client, done)
+
+		ticker := time.NewTicker(m.interval)
+		defer ticker.Stop()
+		for {
+			m.pollPeers(client)
+			select {
+			case <-ticker.C:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	select {
+	case err := <-client.NetError:
+		log.Printf("gami-exporter: %s: connection lost: %v", m.target.Address, err)
+	case <-failed:
+		time.Sleep(m.interval)
+	}
+}
+
+// start the trackers of channels and queues, unless the connection was
+// lost meanwhile
+func (m *monitor) start(client *gami.AMIClient, done <-chan struct{}) {
+	tracker := client.TrackChannels()
+	if err := tracker.Sync(); err != nil {
+		log.Printf("gami-exporter: %s: channels: %v", m.target.Address, err)
+	}
+	feed, err := client.QueueFeed(m.interval)
+	if err != nil {
+		log.Printf("gami-exporter: %s: queues: %v", m.target.Address, err)
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	select {
+	case <-done:
+		tracker.Stop()
+		if feed != nil {
+			feed.Stop()
+		}
+	default:
+		m.tracker, m.feed, m.up = tracker, feed, true
+	}
+}
+
+func (m *monitor) pollPeers(client *gami.AMIClient) {
+	peers, err := client.SIPPeers()
+	if err != nil {
+		log.Printf("gami-exporter: %s: peers: %v", m.target.Address, err)
+		return
+	}
+
+	m.mutex.Lock()
+	m.peers = peers
+	m.mutex.Unlock()
+}
+
+func (m *monitor) stopTrackers() {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.tracker != nil {
+		m.tracker.Stop()
+	}
+	if m.feed != nil {
+		m.feed.Stop()
+	}
+	m.tracker, m.feed, m.up = nil, nil, false
+}
+
+func (m *monitor) snapshot() serverSnapshot {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	snapshot := serverSnapshot{Server: m.target.Address, Up: m.up, Peers: m.peers}
+	if m.tracker != nil {
+		snapshot.Channels = m.tracker.Channels()
+		snapshot.Calls = m.tracker.CallsSnapshot()
+	}
+	if m.feed != nil {
+		snapshot.Queues = m.feed.Snapshot().Queues
+	}
+	return snapshot
+}
@@ -0,0 +1,132 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+// Command gami is a command line client of the Asterisk Manager Interface.
+//
+//	gami tail -server admin:secret@pbx1:5038 [-json] [filter]
+//
+// tail prints the events of the server accepted by the filter, written in
+// the language of gami.ParseFilter so it can be pasted into code unchanged:
+//
+//	gami tail -server admin:secret@pbx1:5038 'Hangup|Newchannel Channel~^PJSIP/'
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/googolgl/gami"
+	"github.com/googolgl/gami/cmd/internal/server"
+)
+
+const usage = `usage: gami <command> [flags]
+
+commands:
+  tail  print the events accepted by a filter
+`
+
+func main() {
+	log.SetFlags(0)
+	if len(os.Args) < 2 {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "tail":
+		tail(os.Args[2:])
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
+}
+
+// tail print the events accepted by the filter until the connection is lost
+func tail(args []string) {
+	flags := flag.NewFlagSet("tail", flag.ExitOnError)
+	addr := flags.String("server", "", "Asterisk as user:secret@host:port")
+	asJSON := flags.Bool("json", false, "print the events as JSON, one per line")
+	flags.Parse(args)
+
+	if *addr == "" {
+		log.Fatal("gami: tail: -server is required")
+	}
+	t, err := server.Parse(*addr)
+	if err != nil {
+		log.Fatalf("gami: %v", err)
+	}
+	options := []func(*gami.AMIClient){gami.ReadOnly}
+	// the filter is the rest of the line, quoted or not
+	if expr := strings.Join(flags.Args(), " "); strings.TrimSpace(expr) != "" {
+		filter, err := gami.ParseFilter(expr)
+		if err != nil {
+			log.Fatal(err)
+		}
+		options = append(options, gami.EventFilter(filter.Match))
+	}
+	client, err := gami.Dial(t.Address, options...)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Close()
+
+	client.Run()
+	go func() {
+		if err := client.Login(t.Username, t.Secret); err != nil {
+			log.Fatalf("gami: %s: %v", t.Address, err)
+		}
+	}()
+
+	for {
+		select {
+		case ev := <-client.Events:
+			printEvent(os.Stdout, ev, *asJSON)
+		case err := <-client.Error:
+			log.Printf("gami: %s: %v", t.Address, err)
+		case err := <-client.NetError:
+			log.Fatalf("gami: %s: connection lost: %v", t.Address, err)
+		}
+	}
+}
+
+// printEvent write the event in a line with the time it was read, its
+// params sorted by key
+func printEvent(w io.Writer, ev *gami.AMIEvent, asJSON bool) {
+	received := ev.Received
+	if received.IsZero() {
+		received = time.Now()
+	}
+	if asJSON {
+		json.NewEncoder(w).Encode(struct {
+			Time   time.Time
+			Event  string
+			Params map[string]string
+		}{received, ev.ID, ev.Params})
+		return
+	}
+
+	keys := make([]string, 0, len(ev.Params))
+	for k := range ev.Params {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var line strings.Builder
+	fmt.Fprintf(&line, "%s %s", received.Format("15:04:05.000"), ev.ID)
+	for _, k := range keys {
+		v := ev.Params[k]
+		if strings.ContainsAny(v, " \t\"") || v == "" {
+			v = fmt.Sprintf("%q", v)
+		}
+		fmt.Fprintf(&line, " %s=%s", k, v)
+	}
+	fmt.Fprintln(w, line.String())
+}
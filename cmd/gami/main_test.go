@@ -0,0 +1,43 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/googolgl/gami"
+)
+
+func TestPrintEvent(t *testing.T) {
+	ev := &gami.AMIEvent{ID: "Hangup", Params: map[string]string{
+		"Channel": "PJSIP/100-01", "Cause-txt": "Normal Clearing", "Cause": "16",
+	}, Received: time.Date(2024, 1, 2, 10, 30, 5, 0, time.UTC)}
+
+	var out bytes.Buffer
+	printEvent(&out, ev, false)
+	expected := `10:30:05.000 Hangup Cause=16 Cause-txt="Normal Clearing" Channel=PJSIP/100-01` + "\n"
+	if out.String() != expected {
+		t.Fatalf("unexpected line %q", out.String())
+	}
+
+	// the params printed are valid predicates of a filter
+	line := strings.TrimSuffix(strings.SplitN(out.String(), " ", 2)[1], "\n")
+	filter, err := gami.ParseFilter(line)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !filter.Match(ev) {
+		t.Fatal("printed event not matched by its own line", line)
+	}
+
+	out.Reset()
+	printEvent(&out, ev, true)
+	if !strings.Contains(out.String(), `"Event":"Hangup"`) || !strings.Contains(out.String(), `"Time":"2024-01-02T10:30:05Z"`) {
+		t.Fatal("unexpected JSON", out.String())
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+// Package server parses the Asterisk servers given to the commands of gami
+// as user:secret@host:port
+package server
+
+import (
+	"errors"
+	"net/url"
+)
+
+// Target an Asterisk to connect to
+type Target struct {
+	Address  string
+	Username string
+	Secret   string
+}
+
+// Parse parse user:secret@host:port
+func Parse(s string) (Target, error) {
+	u, err := url.Parse("ami://" + s)
+	if err != nil {
+		return Target{}, err
+	}
+	if u.User == nil || u.Host == "" {
+		return Target{}, errors.New("server must be user:secret@host:port, got " + s)
+	}
+
+	secret, _ := u.User.Password()
+	return Target{Address: u.Host, Username: u.User.Username(), Secret: secret}, nil
+}
@@ -0,0 +1,22 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package server
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	target, err := Parse("admin:s3cr3t@pbx1:5038")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target.Address != "pbx1:5038" || target.Username != "admin" || target.Secret != "s3cr3t" {
+		t.Fatalf("unexpected target %+v", target)
+	}
+	if _, err := Parse("pbx1:5038"); err == nil {
+		t.Fatal("expected error without credentials")
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"strings"
+	"sync"
+)
+
+// Code a well-known Message of a response or status of an event, stable
+// whatever the text Asterisk sends
+type Code int
+
+const (
+	// CodeUnknown a text without code
+	CodeUnknown Code = iota
+	CodeAuthAccepted
+	CodeAuthFailed
+	CodePermissionDenied
+	CodeInvalidCommand
+	CodeMissingAction
+	CodeNoSuchChannel
+	CodeNoChannelSpecified
+	CodeOriginateQueued
+	CodeOriginateFailed
+	CodeRedirectSuccessful
+	CodeVariableSet
+	CodeChannelHungup
+	CodeListFollows
+	CodeGoodbye
+
+	// the statuses of the events, as DialStatus or PeerStatus
+
+	CodeStatusAnswer
+	CodeStatusBusy
+	CodeStatusNoAnswer
+	CodeStatusCancel
+	CodeStatusCongestion
+	CodeStatusUnavailable
+	CodeStatusRegistered
+	CodeStatusUnregistered
+	CodeStatusRejected
+	CodeStatusReachable
+	CodeStatusUnreachable
+	CodeStatusLagged
+)
+
+var codeNames = map[Code]string{
+	CodeUnknown:            "Unknown",
+	CodeAuthAccepted:       "AuthAccepted",
+	CodeAuthFailed:         "AuthFailed",
+	CodePermissionDenied:   "PermissionDenied",
+	CodeInvalidCommand:     "InvalidCommand",
+	CodeMissingAction:      "MissingAction",
+	CodeNoSuchChannel:      "NoSuchChannel",
+	CodeNoChannelSpecified: "NoChannelSpecified",
+	CodeOriginateQueued:    "OriginateQueued",
+	CodeOriginateFailed:    "OriginateFailed",
+	CodeRedirectSuccessful: "RedirectSuccessful",
+	CodeVariableSet:        "VariableSet",
+	CodeChannelHungup:      "ChannelHungup",
+	CodeListFollows:        "ListFollows",
+	CodeGoodbye:            "Goodbye",
+	CodeStatusAnswer:       "Answer",
+	CodeStatusBusy:         "Busy",
+	CodeStatusNoAnswer:     "NoAnswer",
+	CodeStatusCancel:       "Cancel",
+	CodeStatusCongestion:   "Congestion",
+	CodeStatusUnavailable:  "Unavailable",
+	CodeStatusRegistered:   "Registered",
+	CodeStatusUnregistered: "Unregistered",
+	CodeStatusRejected:     "Rejected",
+	CodeStatusReachable:    "Reachable",
+	CodeStatusUnreachable:  "Unreachable",
+	CodeStatusLagged:       "Lagged",
+}
+
+func (code Code) String() string {
+	if name, ok := codeNames[code]; ok {
+		return name
+	}
+	return codeNames[CodeUnknown]
+}
+
+var (
+	codesMutex = new(sync.RWMutex)
+	// codeTexts normalised texts of the codes, a text followed by more
+	// words, as the action of an Invalid/unknown command, has its code
+	codeTexts = map[string]Code{
+		"authentication accepted":       CodeAuthAccepted,
+		"authentication failed":         CodeAuthFailed,
+		"permission denied":             CodePermissionDenied,
+		"invalid/unknown command":       CodeInvalidCommand,
+		"missing action in request":     CodeMissingAction,
+		"no such channel":               CodeNoSuchChannel,
+		"channel not found":             CodeNoSuchChannel,
+		"no channel specified":          CodeNoChannelSpecified,
+		"channel not specified":         CodeNoChannelSpecified,
+		"originate successfully queued": CodeOriginateQueued,
+		"originate failed":              CodeOriginateFailed,
+		"redirect successful":           CodeRedirectSuccessful,
+		"dual redirect successful":      CodeRedirectSuccessful,
+		"variable set":                  CodeVariableSet,
+		"channel hungup":                CodeChannelHungup,
+		"thanks for all the fish":       CodeGoodbye,
+		"answer":                        CodeStatusAnswer,
+		"answered":                      CodeStatusAnswer,
+		"busy":                          CodeStatusBusy,
+		"noanswer":                      CodeStatusNoAnswer,
+		"cancel":                        CodeStatusCancel,
+		"congestion":                    CodeStatusCongestion,
+		"chanunavail":                   CodeStatusUnavailable,
+		"unavailable":                   CodeStatusUnavailable,
+		"registered":                    CodeStatusRegistered,
+		"unregistered":                  CodeStatusUnregistered,
+		"rejected":                      CodeStatusRejected,
+		"reachable":                     CodeStatusReachable,
+		"unreachable":                   CodeStatusUnreachable,
+		"lagged":                        CodeStatusLagged,
+	}
+)
+
+// RegisterCode map text to code, for the texts of a localized or patched
+// Asterisk, the case and a final dot are ignored
+func RegisterCode(text string, code Code) {
+	codesMutex.Lock()
+	codeTexts[normaliseCodeText(text)] = code
+	codesMutex.Unlock()
+}
+
+// ParseCode return the code of a Message or a status, CodeUnknown when
+// the text is not known
+func ParseCode(text string) Code {
+	text = normaliseCodeText(text)
+	if text == "" {
+		return CodeUnknown
+	}
+
+	codesMutex.RLock()
+	defer codesMutex.RUnlock()
+
+	if code, ok := codeTexts[text]; ok {
+		return code
+	}
+	// the longest text the message starts with
+	code, longest := CodeUnknown, 0
+	for known, knownCode := range codeTexts {
+		if len(known) > longest && strings.HasPrefix(text, known) && !isWordChar(text[len(known)]) {
+			code, longest = knownCode, len(known)
+		}
+	}
+	if code == CodeUnknown && strings.HasSuffix(text, "will follow") {
+		return CodeListFollows
+	}
+	return code
+}
+
+func normaliseCodeText(text string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(text)), ".")
+}
+
+func isWordChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9'
+}
+
+// Code of the Message of the response
+func (resp *AMIResponse) Code() Code {
+	return ParseCode(resp.Get("Message"))
+}
+
+// Code of the Message of the failed action
+func (e *ResponseError) Code() Code {
+	return ParseCode(e.Message)
+}
+
+// Code of the status in the param key of the event, as DialStatus or
+// PeerStatus
+func (ev *AMIEvent) Code(key string) Code {
+	return ParseCode(ev.Get(key))
+}
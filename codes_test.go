@@ -0,0 +1,45 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"testing"
+)
+
+func TestParseCode(t *testing.T) {
+	tests := map[string]Code{
+		"Authentication accepted":    CodeAuthAccepted,
+		"Permission denied":          CodePermissionDenied,
+		"No such channel":            CodeNoSuchChannel,
+		"Channel not found.":         CodeNoSuchChannel,
+		"Thanks for all the fish.":   CodeGoodbye,
+		"Channel status will follow": CodeListFollows,
+		"Invalid/unknown command: Foo. Use Action: ListCommands to show available commands.": CodeInvalidCommand,
+		"Dual Redirect successful": CodeRedirectSuccessful,
+		"CHANUNAVAIL":              CodeStatusUnavailable,
+		" Reachable ":              CodeStatusReachable,
+		"Busyness":                 CodeUnknown,
+		"":                         CodeUnknown,
+	}
+	for text, code := range tests {
+		if got := ParseCode(text); got != code {
+			t.Errorf("%q: expected %s, got %s", text, code, got)
+		}
+	}
+
+	RegisterCode("Permiso denegado.", CodePermissionDenied)
+	resp := &AMIResponse{Status: "Error", Params: map[string]string{"Message": "Permiso denegado"}}
+	if resp.Code() != CodePermissionDenied {
+		t.Fatal("localized message not mapped", resp.Code())
+	}
+	if err, ok := resp.Err().(*ResponseError); !ok || err.Code() != CodePermissionDenied {
+		t.Fatal("unexpected error", resp.Err())
+	}
+
+	ev := &AMIEvent{ID: "DialEnd", Params: map[string]string{"Dialstatus": "NOANSWER"}}
+	if ev.Code("DialStatus") != CodeStatusNoAnswer || ev.Code("DialStatus").String() != "NoAnswer" {
+		t.Fatal("unexpected status", ev.Code("DialStatus"))
+	}
+}
@@ -0,0 +1,276 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Participant a channel on a conference
+type Participant struct {
+	Channel      string
+	UniqueID     string
+	CallerIDNum  string
+	CallerIDName string
+	Admin        bool
+	Marked       bool
+	Muted        bool
+	Talking      bool
+	Joined       time.Time
+}
+
+// ConferenceOptions profiles and dialing of a conference
+type ConferenceOptions struct {
+	BridgeProfile string
+	UserProfile   string
+	// Context where Invite dials the numbers, default when empty
+	Context  string
+	CallerID string
+	// Timeout to answer the invited, the default of Asterisk when zero
+	Timeout time.Duration
+}
+
+// Conference a conference of app_confbridge with its participants kept up to date
+type Conference struct {
+	Name string
+
+	client  *AMIClient
+	opts    ConferenceOptions
+	watcher *eventListener
+	mutex   *sync.RWMutex
+	once    *sync.Once
+
+	locked       bool
+	recording    bool
+	participants map[string]*Participant
+}
+
+// Conference follow the conference name, the conference is created on
+// Asterisk when the first participant joins
+func (client *AMIClient) Conference(name string, opts *ConferenceOptions) (*Conference, error) {
+	conf := &Conference{
+		Name:         name,
+		client:       client,
+		watcher:      client.watch(),
+		mutex:        new(sync.RWMutex),
+		once:         new(sync.Once),
+		participants: make(map[string]*Participant),
+	}
+	if opts != nil {
+		conf.opts = *opts
+	}
+
+	go func() {
+		for {
+			select {
+			case <-conf.watcher.done:
+				return
+			case ev := <-conf.watcher.events:
+				conf.handle(ev)
+			}
+		}
+	}()
+
+	events, err := client.listAction(Params{"Action": "ConfbridgeList", "Conference": name}, "ConfbridgeListComplete")
+	if err != nil {
+		// the conference doesn't exist yet
+		if _, ok := err.(*ResponseError); ok {
+			return conf, nil
+		}
+		conf.Stop()
+		return nil, err
+	}
+
+	for _, ev := range events {
+		if ev.ID == "ConfbridgeList" {
+			conf.handle(ev)
+		}
+	}
+	return conf, nil
+}
+
+// CreateOrJoin call the channel (SIP/100) and put it on the conference,
+// creating it if it's the first participant
+func (conf *Conference) CreateOrJoin(channel string) error {
+	return conf.originate(channel)
+}
+
+// Invite call the number on the context of the conference options and put
+// it on the conference when it answers
+func (conf *Conference) Invite(number string) error {
+	context := conf.opts.Context
+	if context == "" {
+		context = "default"
+	}
+	return conf.originate(fmt.Sprintf("Local/%s@%s", number, context))
+}
+
+// Mute the participant channel
+func (conf *Conference) Mute(channel string) error {
+	return conf.action("ConfbridgeMute", Params{"Channel": channel})
+}
+
+// Unmute the participant channel
+func (conf *Conference) Unmute(channel string) error {
+	return conf.action("ConfbridgeUnmute", Params{"Channel": channel})
+}
+
+// Kick the participant channel, all kicks every participant
+func (conf *Conference) Kick(channel string) error {
+	return conf.action("ConfbridgeKick", Params{"Channel": channel})
+}
+
+// Lock the conference, new participants can't join
+func (conf *Conference) Lock() error {
+	return conf.setLocked("ConfbridgeLock", true)
+}
+
+// Unlock the conference
+func (conf *Conference) Unlock() error {
+	return conf.setLocked("ConfbridgeUnlock", false)
+}
+
+// StartRecording record the conference on file, the file of the bridge
+// profile when empty
+func (conf *Conference) StartRecording(file string) error {
+	p := Params{}
+	if file != "" {
+		p["RecordFile"] = file
+	}
+	return conf.action("ConfbridgeStartRecord", p)
+}
+
+// StopRecording stop recording the conference
+func (conf *Conference) StopRecording() error {
+	return conf.action("ConfbridgeStopRecord", Params{})
+}
+
+// Participants return the participants ordered by join
+func (conf *Conference) Participants() []Participant {
+	conf.mutex.RLock()
+	defer conf.mutex.RUnlock()
+
+	participants := make([]Participant, 0, len(conf.participants))
+	for _, participant := range conf.participants {
+		participants = append(participants, *participant)
+	}
+	sort.SliceStable(participants, func(i, j int) bool {
+		return participants[i].Joined.Before(participants[j].Joined)
+	})
+	return participants
+}
+
+// Locked check if the conference is locked
+func (conf *Conference) Locked() bool {
+	conf.mutex.RLock()
+	defer conf.mutex.RUnlock()
+	return conf.locked
+}
+
+// Recording check if the conference is being recorded
+func (conf *Conference) Recording() bool {
+	conf.mutex.RLock()
+	defer conf.mutex.RUnlock()
+	return conf.recording
+}
+
+// Stop following the conference, it keeps going on Asterisk
+func (conf *Conference) Stop() {
+	conf.once.Do(func() {
+		conf.client.unwatch(conf.watcher)
+	})
+}
+
+func (conf *Conference) originate(channel string) error {
+	data := conf.Name
+	if conf.opts.BridgeProfile != "" || conf.opts.UserProfile != "" {
+		data += "," + conf.opts.BridgeProfile + "," + conf.opts.UserProfile
+	}
+
+	result, err := conf.client.Originate(OriginateRequest{
+		Channel:     channel,
+		Application: "ConfBridge",
+		Data:        data,
+		CallerID:    conf.opts.CallerID,
+		Timeout:     conf.opts.Timeout,
+	})
+	if err != nil {
+		return err
+	}
+	if !result.Success {
+		return fmt.Errorf("Originate to %s failed, reason %d", channel, result.Reason)
+	}
+	return nil
+}
+
+// setLocked send the action and keep the lock, Asterisk has no events for it
+func (conf *Conference) setLocked(action string, locked bool) error {
+	if err := conf.action(action, Params{}); err != nil {
+		return err
+	}
+
+	conf.mutex.Lock()
+	conf.locked = locked
+	conf.mutex.Unlock()
+	return nil
+}
+
+func (conf *Conference) action(action string, p Params) error {
+	p["Action"] = action
+	p["Conference"] = conf.Name
+	_, err := conf.client.syncAction(p)
+	return err
+}
+
+// handle update the conference from the event
+func (conf *Conference) handle(ev *AMIEvent) {
+	if ev.Get("Conference") != conf.Name {
+		return
+	}
+
+	conf.mutex.Lock()
+	defer conf.mutex.Unlock()
+
+	channel := ev.Get("Channel")
+	switch ev.ID {
+	case "ConfbridgeJoin", "ConfbridgeList":
+		participant := &Participant{
+			Channel:      channel,
+			UniqueID:     ev.Get("Uniqueid"),
+			CallerIDNum:  ev.Get("CallerIDNum"),
+			CallerIDName: ev.Get("CallerIDName"),
+			Admin:        ev.GetBool("Admin"),
+			Marked:       ev.GetBool("MarkedUser"),
+			Muted:        ev.GetBool("Muted"),
+			Talking:      ev.GetBool("Talking"),
+			Joined:       time.Now(),
+		}
+		if answered := ev.GetDuration("AnsweredTime"); answered > 0 {
+			participant.Joined = participant.Joined.Add(-answered)
+		}
+		if known, ok := conf.participants[channel]; ok {
+			participant.Joined = known.Joined
+		}
+		conf.participants[channel] = participant
+	case "ConfbridgeLeave":
+		delete(conf.participants, channel)
+	case "ConfbridgeEnd":
+		conf.participants = make(map[string]*Participant)
+		conf.locked, conf.recording = false, false
+	case "ConfbridgeMute", "ConfbridgeUnmute":
+		if participant, ok := conf.participants[channel]; ok {
+			participant.Muted = ev.ID == "ConfbridgeMute"
+		}
+	case "ConfbridgeTalking":
+		if participant, ok := conf.participants[channel]; ok {
+			participant.Talking = ev.Get("TalkingStatus") == "on"
+		}
+	case "ConfbridgeRecord", "ConfbridgeStopRecord":
+		conf.recording = ev.ID == "ConfbridgeRecord"
+	}
+}
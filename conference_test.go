@@ -0,0 +1,135 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func waitParticipants(t *testing.T, conf *Conference, n int) []Participant {
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if participants := conf.Participants(); len(participants) == n {
+			return participants
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("expected %d participants, got %+v", n, conf.Participants())
+	return nil
+}
+
+func TestConference(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.MockList("ConfbridgeList", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start", "Message": "Confbridge user list will follow"},
+			{"Event": "ConfbridgeList", "ActionID": id, "Conference": "sales", "Channel": "SIP/100-01",
+				"CallerIDNum": "100", "Admin": "Yes", "MarkedUser": "No", "Muted": "No", "AnsweredTime": "60"},
+			{"Event": "ConfbridgeListComplete", "ActionID": id, "EventList": "Complete", "ListItems": "1"},
+		}
+	})
+	srv.MockList("Originate", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		if params.Get("Application") != "ConfBridge" || params.Get("Data") != "sales,,guest" ||
+			params.Get("Channel") != "Local/200@conferences" {
+			return []map[string]string{{"Response": "Error", "ActionID": id, "Message": "Unexpected originate"}}
+		}
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "Message": "Originate successfully queued"},
+			{"Event": "ConfbridgeJoin", "Conference": "sales", "Channel": "Local/200@conferences-01;2",
+				"CallerIDNum": "200", "Admin": "No", "Muted": "No"},
+			{"Event": "OriginateResponse", "ActionID": id, "Response": "Success", "Reason": "4"},
+		}
+	})
+	srv.MockList("ConfbridgeMute", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid"), "Message": "User muted"},
+			{"Event": "ConfbridgeMute", "Conference": params.Get("Conference"), "Channel": params.Get("Channel")},
+		}
+	})
+	srv.MockList("ConfbridgeKick", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid"), "Message": "User kicked"},
+			{"Event": "ConfbridgeLeave", "Conference": params.Get("Conference"), "Channel": params.Get("Channel")},
+			{"Event": "ConfbridgeLeave", "Conference": "other", "Channel": "SIP/300-03"},
+		}
+	})
+	srv.Mock("ConfbridgeLock", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid")}
+	})
+	srv.Mock("ConfbridgeStartRecord", func(params textproto.MIMEHeader) map[string]string {
+		if params.Get("Recordfile") != "/tmp/sales.wav" {
+			return map[string]string{"Response": "Error", "ActionID": params.Get("Actionid"), "Message": "Unexpected file"}
+		}
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid")}
+	})
+
+	conf, err := ami.Conference("sales", &ConferenceOptions{UserProfile: "guest", Context: "conferences"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conf.Stop()
+
+	participants := waitParticipants(t, conf, 1)
+	if !participants[0].Admin || participants[0].CallerIDNum != "100" || time.Since(participants[0].Joined) < time.Minute {
+		t.Fatalf("unexpected participant %+v", participants[0])
+	}
+
+	if err := conf.Invite("200"); err != nil {
+		t.Fatal(err)
+	}
+	participants = waitParticipants(t, conf, 2)
+	if participants[0].Channel != "SIP/100-01" || participants[1].CallerIDNum != "200" {
+		t.Fatalf("unexpected participants %+v", participants)
+	}
+
+	if err := conf.Mute("SIP/100-01"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.Kick("Local/200@conferences-01;2"); err != nil {
+		t.Fatal(err)
+	}
+	participants = waitParticipants(t, conf, 1)
+	deadline := time.Now().Add(5 * time.Second)
+	for !conf.Participants()[0].Muted && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !conf.Participants()[0].Muted {
+		t.Fatalf("expected muted participant %+v", participants[0])
+	}
+
+	if err := conf.Lock(); err != nil || !conf.Locked() {
+		t.Fatal("expected locked conference", err)
+	}
+	if err := conf.StartRecording("/tmp/sales.wav"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConferenceNotCreated(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.Mock("ConfbridgeList", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{"Response": "Error", "ActionID": params.Get("Actionid"),
+			"Message": "No Conference by that name found."}
+	})
+
+	conf, err := ami.Conference("support", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conf.Stop()
+	if len(conf.Participants()) != 0 {
+		t.Fatal("expected empty conference")
+	}
+}
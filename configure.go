@@ -0,0 +1,151 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"sync"
+	"time"
+)
+
+// Configure change options of a live client without a new session, it's
+// safe while actions are sent and events read. UseLogger,
+// SlowActionThreshold, BufferedWrites, RouteActionEvents, ReadOnly,
+// AllowActions, DenyActions, DefaultParams, ActionDefaults,
+// ActionIDPrefix, UseInterceptors, EventFilter, EventHistory, StrictMode,
+// StrictModeOff, KeepAlive, ValidateSession and RateLimit apply to the next
+// action or frame, the options of the connection (TLS, banner) apply on
+// Reconnect.
+//
+// The buffer of Events is made by Dial and kept for the life of the client,
+// as the chan is read by the application. The client has no log level of its
+// own: the Logger given to UseLogger filters the diagnostics, UseLogger(nil)
+// disable them
+func (client *AMIClient) Configure(options ...func(*AMIClient)) {
+	client.mutexConfig.Lock()
+	defer client.mutexConfig.Unlock()
+
+	for _, op := range options {
+		op(client)
+	}
+	close(client.reconfigured)
+	client.reconfigured = make(chan struct{})
+}
+
+// EventFilter send on Events only the events accepted by filter, the
+// helpers of the client still see every event, nil accept all
+func EventFilter(filter func(ev *AMIEvent) bool) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.eventFilter = filter
+	}
+}
+
+// KeepAlive send a Ping every interval to keep the session open through
+// firewalls and NAT, logging when it's not answered, 0 disable it
+func KeepAlive(interval time.Duration) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.keepAlive = interval
+	}
+}
+
+// RateLimit limit the actions sent to perSecond with bursts of burst
+// actions, Action waits for its turn, perSecond 0 remove the limit
+func RateLimit(perSecond float64, burst int) func(*AMIClient) {
+	return func(c *AMIClient) {
+		if perSecond <= 0 {
+			c.limiter = nil
+			return
+		}
+		c.limiter = newRateLimiter(perSecond, burst)
+	}
+}
+
+// every call fn each interval returned by the options until Close,
+// following the changes of Configure, fn is not called while interval is 0
+func (client *AMIClient) every(interval func() time.Duration, fn func(time.Duration)) {
+	for {
+		client.mutexConfig.RLock()
+		d := interval()
+		reconfigured := client.reconfigured
+		client.mutexConfig.RUnlock()
+
+		if d <= 0 {
+			select {
+			case <-client.closed:
+				return
+			case <-reconfigured:
+			}
+			continue
+		}
+
+		timer := time.NewTimer(d)
+		select {
+		case <-client.closed:
+			timer.Stop()
+			return
+		case <-reconfigured:
+			timer.Stop()
+		case <-timer.C:
+			fn(d)
+		}
+	}
+}
+
+// ping send a Ping and log it if it's not answered within timeout
+func (client *AMIClient) ping(timeout time.Duration) {
+	response, _, err := client.Action(Params{"Action": "Ping"})
+	if err == nil {
+		err = client.Flush()
+	}
+	if err != nil {
+		client.logf("gami: keepalive: %s", err)
+		return
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-response:
+	case <-client.closed:
+	case <-timer.C:
+		client.logf("gami: keepalive without response after %s", timeout)
+	}
+}
+
+// rateLimiter token bucket of the actions sent
+type rateLimiter struct {
+	mutex     sync.Mutex
+	perSecond float64
+	burst     float64
+	tokens    float64
+	last      time.Time
+}
+
+func newRateLimiter(perSecond float64, burst int) *rateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{perSecond: perSecond, burst: float64(burst), tokens: float64(burst), last: time.Now()}
+}
+
+// wait until a token is available, the token is taken when it returns
+func (l *rateLimiter) wait() {
+	l.mutex.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.perSecond
+	if l.tokens > l.burst {
+		l.tokens = l.burst
+	}
+	l.last = now
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.perSecond * float64(time.Second))
+	}
+	l.mutex.Unlock()
+
+	if delay > 0 {
+		time.Sleep(delay)
+	}
+}
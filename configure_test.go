@@ -0,0 +1,138 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestConfigureEventFilter(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "VarSet", "Variable": "A"},
+			{"Event": "Hangup", "Channel": "SIP/100-01"},
+		}
+	})
+
+	ami.Configure(EventFilter(func(ev *AMIEvent) bool {
+		return ev.ID == "Hangup"
+	}))
+	if _, _, err := ami.Action(Params{"Action": "UserEvent"}); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case ev := <-ami.Events:
+			if ev.ID == "VarSet" || ev.ID == "HeartBeat" {
+				t.Fatalf("event %s not filtered", ev.ID)
+			}
+			if ev.ID == "Hangup" {
+				return
+			}
+		case <-timeout:
+			t.Fatal("event accepted not received")
+		}
+	}
+}
+
+func TestConfigureKeepAlive(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	var pings int32
+	srv.Mock("Ping", func(params textproto.MIMEHeader) map[string]string {
+		atomic.AddInt32(&pings, 1)
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid"), "Ping": "Pong"}
+	})
+
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt32(&pings) != 0 {
+		t.Fatal("keepalive without KeepAlive")
+	}
+
+	ami.Configure(KeepAlive(20 * time.Millisecond))
+	deadline := time.Now().Add(5 * time.Second)
+	for atomic.LoadInt32(&pings) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if atomic.LoadInt32(&pings) < 3 {
+		t.Fatal("expected keepalive pings")
+	}
+
+	ami.Configure(KeepAlive(0))
+	time.Sleep(50 * time.Millisecond)
+	sent := atomic.LoadInt32(&pings)
+	time.Sleep(100 * time.Millisecond)
+	if atomic.LoadInt32(&pings) != sent {
+		t.Fatal("keepalive not disabled")
+	}
+}
+
+func TestConfigureRateLimit(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	ami.Configure(RateLimit(20, 1))
+	start := time.Now()
+	for i := 0; i < 5; i++ {
+		if _, _, err := ami.Action(Params{"Action": "Ping"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 190*time.Millisecond {
+		t.Fatalf("5 actions at 20/s sent in %s", elapsed)
+	}
+
+	ami.Configure(RateLimit(0, 0))
+	start = time.Now()
+	for i := 0; i < 5; i++ {
+		if _, _, err := ami.Action(Params{"Action": "Ping"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Fatalf("actions limited after removing the limit, %s", elapsed)
+	}
+}
+
+func TestConfigureLoggerAndFlush(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	logger := &testLogger{}
+	ami.Configure(UseLogger(logger), BufferedWrites(20*time.Millisecond))
+
+	response, _, err := ami.Action(Params{"Action": "Ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-response:
+	case <-time.After(5 * time.Second):
+		t.Fatal("buffered action not flushed by the interval")
+	}
+
+	srv.Mock("Ping", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{"Response": "Success", "ActionID": "unknown"}
+	})
+	ami.Action(Params{"Action": "Ping"})
+	waitLogged(t, logger, `unknown ActionID "unknown"`)
+}
@@ -0,0 +1,264 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// QueueSnapshot state of the queues at a time
+type QueueSnapshot struct {
+	Time   time.Time
+	Queues []Queue
+}
+
+// QueueFeed keeps the queues, their members and callers waiting up to date
+// from the events and emits a snapshot of them periodically, the server is
+// only queried on Sync
+type QueueFeed struct {
+	client   *AMIClient
+	watcher  *eventListener
+	mutex    *sync.Mutex
+	once     *sync.Once
+	interval time.Duration
+
+	queues map[string]*Queue
+	// joined time a caller started waiting by uniqueid
+	joined    map[string]time.Time
+	snapshots chan QueueSnapshot
+	done      chan struct{}
+}
+
+// QueueFeed start a feed of the queues emitting a snapshot every interval,
+// a second when zero, the queues are loaded with QueueStatus and again when
+// Asterisk boots after a Reconnect
+func (client *AMIClient) QueueFeed(interval time.Duration) (*QueueFeed, error) {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	feed := &QueueFeed{
+		client:    client,
+		watcher:   client.watch(),
+		mutex:     new(sync.Mutex),
+		once:      new(sync.Once),
+		interval:  interval,
+		queues:    make(map[string]*Queue),
+		joined:    make(map[string]time.Time),
+		snapshots: make(chan QueueSnapshot, 1),
+		done:      make(chan struct{}),
+	}
+
+	go feed.run()
+	client.syncOnBoot(feed.watcher, feed.Sync)
+	if err := feed.Sync(); err != nil {
+		feed.Stop()
+		return nil, err
+	}
+	return feed, nil
+}
+
+// Sync reload the queues from the server with QueueStatus
+func (feed *QueueFeed) Sync() error {
+	queues, err := feed.client.QueueStatus("")
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	feed.mutex.Lock()
+	defer feed.mutex.Unlock()
+
+	feed.queues = make(map[string]*Queue, len(queues))
+	joined := make(map[string]time.Time)
+	for i := range queues {
+		queue := queues[i]
+		feed.queues[queue.Name] = &queue
+		for _, entry := range queue.Entries {
+			joined[entry.UniqueID] = now.Add(-entry.Wait)
+		}
+	}
+	feed.joined = joined
+	return nil
+}
+
+// Snapshots return the snapshots emitted every interval, a snapshot is
+// dropped if the previous one was not received yet
+func (feed *QueueFeed) Snapshots() <-chan QueueSnapshot {
+	return feed.snapshots
+}
+
+// Snapshot return the state of the queues now
+func (feed *QueueFeed) Snapshot() QueueSnapshot {
+	feed.mutex.Lock()
+	defer feed.mutex.Unlock()
+
+	now := time.Now()
+	snapshot := QueueSnapshot{Time: now, Queues: make([]Queue, 0, len(feed.queues))}
+	for _, queue := range feed.queues {
+		copied := *queue
+		copied.Members = append([]QueueMember(nil), queue.Members...)
+		copied.Entries = append([]QueueEntry(nil), queue.Entries...)
+		for i := range copied.Entries {
+			if joined, ok := feed.joined[copied.Entries[i].UniqueID]; ok {
+				copied.Entries[i].Wait = now.Sub(joined)
+			}
+		}
+		snapshot.Queues = append(snapshot.Queues, copied)
+	}
+	sort.Slice(snapshot.Queues, func(i, j int) bool {
+		return snapshot.Queues[i].Name < snapshot.Queues[j].Name
+	})
+	return snapshot
+}
+
+// Stop the feed, Snapshots is closed
+func (feed *QueueFeed) Stop() {
+	feed.once.Do(func() {
+		feed.client.unwatch(feed.watcher)
+		close(feed.done)
+	})
+}
+
+func (feed *QueueFeed) run() {
+	defer close(feed.snapshots)
+
+	ticker := time.NewTicker(feed.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-feed.done:
+			return
+		case ev := <-feed.watcher.events:
+			feed.handle(ev)
+		case <-ticker.C:
+			select {
+			case feed.snapshots <- feed.Snapshot():
+			default:
+			}
+		}
+	}
+}
+
+// handle update the queues from the event
+func (feed *QueueFeed) handle(ev *AMIEvent) {
+	name := ev.Get("Queue")
+	if name == "" {
+		return
+	}
+
+	feed.mutex.Lock()
+	defer feed.mutex.Unlock()
+
+	queue, ok := feed.queues[name]
+	if !ok {
+		queue = &Queue{Name: name}
+		feed.queues[name] = queue
+	}
+
+	switch ev.ID {
+	case "QueueCallerJoin":
+		uniqueID := ev.Get("Uniqueid")
+		feed.joined[uniqueID] = time.Now()
+		queue.Entries = append(queue.Entries, QueueEntry{
+			Position:     ev.GetInt("Position"),
+			Channel:      ev.Get("Channel"),
+			UniqueID:     uniqueID,
+			CallerIDNum:  ev.Get("CallerIDNum"),
+			CallerIDName: ev.Get("CallerIDName"),
+		})
+		queue.Calls = ev.GetInt("Count")
+	case "QueueCallerLeave":
+		feed.removeEntry(queue, ev.Get("Uniqueid"))
+		queue.Calls = ev.GetInt("Count")
+	case "QueueCallerAbandon":
+		queue.Abandoned++
+	case "AgentComplete":
+		queue.Completed++
+	case "QueueMemberAdded", "QueueMemberStatus", "QueueMemberPause", "QueueMemberPenalty":
+		member := memberOf(queue, ev)
+		if member == nil {
+			queue.Members = append(queue.Members, QueueMember{Interface: memberInterface(ev)})
+			member = &queue.Members[len(queue.Members)-1]
+		}
+		updateMember(member, ev)
+	case "QueueMemberRemoved":
+		for i := range queue.Members {
+			if isMember(&queue.Members[i], ev) {
+				queue.Members = append(queue.Members[:i], queue.Members[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// removeEntry remove the caller uniqueID from the queue and move up the
+// callers behind it
+func (feed *QueueFeed) removeEntry(queue *Queue, uniqueID string) {
+	delete(feed.joined, uniqueID)
+	for i := range queue.Entries {
+		if queue.Entries[i].UniqueID != uniqueID {
+			continue
+		}
+		queue.Entries = append(queue.Entries[:i], queue.Entries[i+1:]...)
+		for j := i; j < len(queue.Entries); j++ {
+			queue.Entries[j].Position--
+		}
+		return
+	}
+}
+
+// memberOf return the member of the queue the event is about, nil if none
+func memberOf(queue *Queue, ev *AMIEvent) *QueueMember {
+	for i := range queue.Members {
+		if isMember(&queue.Members[i], ev) {
+			return &queue.Members[i]
+		}
+	}
+	return nil
+}
+
+// memberInterface the interface of the member of the event as QueueStatus
+// reports it, the StateInterface if any
+func memberInterface(ev *AMIEvent) string {
+	if iface := ev.Get("StateInterface"); iface != "" {
+		return iface
+	}
+	return ev.Get("Interface")
+}
+
+// isMember check if the event is about member, the Interface of the events
+// is the Location of the member, which QueueStatus reports only without a
+// StateInterface
+func isMember(member *QueueMember, ev *AMIEvent) bool {
+	return member.Interface == memberInterface(ev) || member.Interface == ev.Get("Interface")
+}
+
+// updateMember set the fields of the member present on the event
+func updateMember(member *QueueMember, ev *AMIEvent) {
+	if name := ev.Get("MemberName"); name != "" {
+		member.Name = name
+	}
+	if membership := ev.Get("Membership"); membership != "" {
+		member.Membership = membership
+	}
+	if ev.Get("Penalty") != "" {
+		member.Penalty = ev.GetInt("Penalty")
+	}
+	if ev.Get("CallsTaken") != "" {
+		member.CallsTaken = ev.GetInt("CallsTaken")
+	}
+	if ev.Get("LastCall") != "" {
+		member.LastCall = ev.GetTime("LastCall")
+	}
+	if ev.Get("Status") != "" {
+		member.Status = ev.GetInt("Status")
+	}
+	if ev.Get("Paused") != "" {
+		member.Paused = ev.GetBool("Paused")
+	}
+}
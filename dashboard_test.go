@@ -0,0 +1,87 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestQueueFeed(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	srv.MockList("QueueStatus", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "QueueParams", "ActionID": id, "Queue": "support", "Calls": "1", "Completed": "3"},
+			{"Event": "QueueMember", "ActionID": id, "Queue": "support", "Name": "Alice",
+				"Location": "Local/100@agents/n", "StateInterface": "SIP/100", "Status": "1", "Paused": "0"},
+			{"Event": "QueueEntry", "ActionID": id, "Queue": "support", "Position": "1",
+				"Channel": "SIP/trunk-01", "Uniqueid": "caller-1", "Wait": "30"},
+			{"Event": "QueueStatusComplete", "ActionID": id, "EventList": "Complete"},
+		}
+	})
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "QueueCallerJoin", "Queue": "support", "Channel": "SIP/trunk-02", "Uniqueid": "caller-2",
+				"CallerIDNum": "5551234", "Position": "2", "Count": "2"},
+			{"Event": "QueueCallerLeave", "Queue": "support", "Channel": "SIP/trunk-01", "Uniqueid": "caller-1",
+				"Position": "1", "Count": "1"},
+			{"Event": "AgentComplete", "Queue": "support", "Interface": "SIP/100"},
+			{"Event": "QueueMemberPause", "Queue": "support", "Interface": "Local/100@agents/n",
+				"StateInterface": "SIP/100", "MemberName": "Alice", "Paused": "1"},
+			{"Event": "QueueMemberAdded", "Queue": "support", "Interface": "SIP/200", "MemberName": "Bob",
+				"Status": "1", "Paused": "0"},
+		}
+	})
+
+	feed, err := ami.QueueFeed(50 * time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer feed.Stop()
+
+	snapshot := feed.Snapshot()
+	if len(snapshot.Queues) != 1 || len(snapshot.Queues[0].Entries) != 1 ||
+		snapshot.Queues[0].Entries[0].Wait < 30*time.Second {
+		t.Fatalf("unexpected initial snapshot %+v", snapshot)
+	}
+
+	if _, _, err := ami.Action(Params{"Action": "UserEvent", "UserEvent": "queue"}); err != nil {
+		t.Fatal(err)
+	}
+
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case snapshot = <-feed.Snapshots():
+		case <-timeout:
+			t.Fatalf("unexpected snapshot %+v", snapshot)
+		}
+
+		queue := snapshot.Queues[0]
+		if len(queue.Members) != 2 || queue.Completed != 4 {
+			continue
+		}
+		if queue.Calls != 1 || len(queue.Entries) != 1 || queue.Entries[0].UniqueID != "caller-2" ||
+			queue.Entries[0].Position != 1 {
+			t.Fatalf("unexpected entries %+v", queue)
+		}
+		if !queue.Members[0].Paused || queue.Members[1].Name != "Bob" {
+			t.Fatalf("unexpected members %+v", queue.Members)
+		}
+		break
+	}
+
+	feed.Stop()
+	for range feed.Snapshots() {
+	}
+}
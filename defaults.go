@@ -0,0 +1,88 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"strings"
+)
+
+// noDefaultActions open and close the session, the DefaultParams are not
+// added to them
+var noDefaultActions = map[string]bool{
+	"login":     true,
+	"challenge": true,
+	"logoff":    true,
+}
+
+// DefaultParams add params to every action but Login, Challenge and Logoff,
+// the params given to Action take precedence. Action and ActionID can't be
+// defaulted, nil remove the defaults
+func DefaultParams(p Params) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.defaultParams = normaliseDefaults(p)
+	}
+}
+
+// ActionDefaults add params to the actions named action, ignoring case,
+// over the DefaultParams and under the params given to Action, nil remove
+// the defaults of the action
+func ActionDefaults(action string, p Params) func(*AMIClient) {
+	return func(c *AMIClient) {
+		action = strings.ToLower(action)
+		// the map is replaced, not modified, as the actions read it
+		// without lock
+		actionDefaults := make(map[string]Params, len(c.actionDefaults)+1)
+		for k, v := range c.actionDefaults {
+			actionDefaults[k] = v
+		}
+		if defaults := normaliseDefaults(p); defaults != nil {
+			actionDefaults[action] = defaults
+		} else {
+			delete(actionDefaults, action)
+		}
+		c.actionDefaults = actionDefaults
+	}
+}
+
+// ActionIDPrefix prefix the ActionID generated for the actions, to tell
+// apart the actions of each application sharing a server
+func ActionIDPrefix(prefix string) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.actionIDPrefix = prefix
+	}
+}
+
+// applyDefaults add to the normalised params p the defaults missing, the
+// defaults of the action first
+func applyDefaults(p Params, defaults Params, actionDefaults map[string]Params) {
+	action := strings.ToLower(p["Action"])
+	if noDefaultActions[action] {
+		defaults = nil
+	}
+	for _, d := range []Params{actionDefaults[action], defaults} {
+		for k, v := range d {
+			if _, ok := p[k]; !ok {
+				p[k] = v
+			}
+		}
+	}
+}
+
+// normaliseDefaults copy the params with the keys as normaliser, without
+// Action and ActionID, nil when empty
+func normaliseDefaults(p Params) Params {
+	defaults := make(Params)
+	for k, v := range p {
+		k = strings.Title(strings.ToLower(k))
+		if k == "Action" || k == "Actionid" {
+			continue
+		}
+		defaults[k] = strings.TrimSpace(v)
+	}
+	if len(defaults) == 0 {
+		return nil
+	}
+	return defaults
+}
@@ -0,0 +1,108 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestDefaultParams(t *testing.T) {
+	srv := newAmiServer()
+	defer srv.Close()
+	ami, err := Dial(srv.Addr,
+		DefaultParams(Params{"X-Component": "billing", "account": "1000"}),
+		ActionDefaults("originate", Params{"Account": "2000", "Timeout": "30000"}),
+		ActionIDPrefix("billing-"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	srv.Mock("Originate", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid"),
+			"Account": params.Get("Account"), "Timeout": params.Get("Timeout"),
+			"Component": params.Get("X-Component")}
+	})
+	srv.Mock("Ping", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid"),
+			"Account": params.Get("Account"), "Component": params.Get("X-Component")}
+	})
+
+	rs, id, err := ami.Action(Params{"Action": "Ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := <-rs
+	if !strings.HasPrefix(id, "billing-") || response.ID != id {
+		t.Fatalf("expected prefixed ActionID, got %q", id)
+	}
+	if response.Params["Account"] != "1000" || response.Params["Component"] != "billing" {
+		t.Fatalf("expected default params, got %v", response.Params)
+	}
+
+	rs, _, err = ami.Action(Params{"Action": "Originate", "Timeout": "5000"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response = <-rs
+	if response.Params["Account"] != "2000" || response.Params["Timeout"] != "5000" ||
+		response.Params["Component"] != "billing" {
+		t.Fatalf("expected defaults of the action under the params, got %v", response.Params)
+	}
+
+	ami.Configure(DefaultParams(nil), ActionDefaults("Originate", nil))
+	rs, _, err = ami.Action(Params{"Action": "Originate"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	response = <-rs
+	if response.Params["Account"] != "" || response.Params["Component"] != "" {
+		t.Fatalf("expected defaults removed, got %v", response.Params)
+	}
+}
+
+func TestDefaultParamsSessionActions(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	ami.Configure(DefaultParams(Params{"Account": "1000"}), ActionDefaults("Login", Params{"Events": "off"}))
+
+	for _, action := range []string{"Login", "Challenge", "Logoff"} {
+		wire, err := ami.DryRun(Params{"Action": action})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(wire, "Account") {
+			t.Fatalf("default params added to %s: %q", action, wire)
+		}
+	}
+	if wire, _ := ami.DryRun(Params{"Action": "Login"}); !strings.Contains(wire, "Events: off") {
+		t.Fatalf("defaults of Login not added: %q", wire)
+	}
+}
+
+func TestActionDefaultsConcurrent(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			ami.Configure(ActionDefaults("Originate", Params{"Account": strconv.Itoa(i)}))
+		}
+	}()
+	for i := 0; i < 100; i++ {
+		if _, err := ami.DryRun(Params{"Action": "Originate"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-done
+}
@@ -0,0 +1,203 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Dialplan contexts listed by ShowDialplan, in the order of Asterisk
+type Dialplan struct {
+	Contexts []*DialplanContext
+}
+
+// DialplanContext a context of the dialplan
+type DialplanContext struct {
+	Name      string
+	Registrar string
+	// Includes contexts included by this one
+	Includes   []string
+	Extensions []*DialplanExtension
+}
+
+// DialplanExtension an extension or pattern of a context
+type DialplanExtension struct {
+	Name string
+	// Hint of the extension, empty without hint
+	Hint       string
+	Priorities []DialplanPriority
+}
+
+// DialplanPriority a step of an extension
+type DialplanPriority struct {
+	Priority    int
+	Label       string
+	Application string
+	AppData     string
+	Registrar   string
+}
+
+// ExtensionState state of the hint of an extension
+type ExtensionState struct {
+	Exten   string
+	Context string
+	Hint    string
+	// Status as ast_extension_state, -1 when the extension doesn't exist
+	Status     int
+	StatusText string
+}
+
+// ShowDialplan list the dialplan with ShowDialPlan, context and extension
+// limit it when not empty, extension is matched as Asterisk, patterns
+// included
+func (client *AMIClient) ShowDialplan(context, extension string) (*Dialplan, error) {
+	p := Params{"Action": "ShowDialPlan"}
+	if context != "" {
+		p["Context"] = context
+	}
+	if extension != "" {
+		p["Extension"] = extension
+	}
+
+	events, err := client.listAction(p, "ShowDialPlanComplete")
+	if err != nil {
+		return nil, err
+	}
+
+	dialplan := new(Dialplan)
+	for _, ev := range events {
+		if ev.ID != "ListDialplan" {
+			continue
+		}
+		dialplan.add(ev)
+	}
+	return dialplan, nil
+}
+
+// ExtensionState return the state of the hint of exten@context
+func (client *AMIClient) ExtensionState(exten, context string) (*ExtensionState, error) {
+	resp, err := client.syncAction(Params{"Action": "ExtensionState", "Exten": exten, "Context": context})
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExtensionState{
+		Exten:      exten,
+		Context:    context,
+		Hint:       resp.Get("Hint"),
+		Status:     parseInt(resp.Get("Status")),
+		StatusText: resp.Get("StatusText"),
+	}, nil
+}
+
+// ValidateDestination check that exten@context, directly or by its
+// includes, has priority, any priority when 0, so an Originate to it won't
+// fail on the dialplan
+func (client *AMIClient) ValidateDestination(context, exten string, priority int) error {
+	dialplan, err := client.ShowDialplan(context, exten)
+	if err != nil {
+		if _, ok := err.(*ResponseError); ok {
+			return fmt.Errorf("Extension %s@%s not found", exten, context)
+		}
+		return err
+	}
+
+	for _, c := range dialplan.Contexts {
+		for _, extension := range c.Extensions {
+			if priority == 0 && len(extension.Priorities) > 0 {
+				return nil
+			}
+			if extension.Priority(priority) != nil {
+				return nil
+			}
+		}
+	}
+	if priority == 0 {
+		return fmt.Errorf("Extension %s@%s not found", exten, context)
+	}
+	return fmt.Errorf("Priority %d of %s@%s not found", priority, exten, context)
+}
+
+// Context return the context name, nil when not listed
+func (dialplan *Dialplan) Context(name string) *DialplanContext {
+	for _, c := range dialplan.Contexts {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// Extension return the extension name of the context, nil when missing
+func (c *DialplanContext) Extension(name string) *DialplanExtension {
+	for _, extension := range c.Extensions {
+		if extension.Name == name {
+			return extension
+		}
+	}
+	return nil
+}
+
+// Priority return the priority n of the extension, nil when missing
+func (extension *DialplanExtension) Priority(n int) *DialplanPriority {
+	for i := range extension.Priorities {
+		if extension.Priorities[i].Priority == n {
+			return &extension.Priorities[i]
+		}
+	}
+	return nil
+}
+
+// Label return the priority labeled label, nil when missing
+func (extension *DialplanExtension) Label(label string) *DialplanPriority {
+	for i := range extension.Priorities {
+		if extension.Priorities[i].Label == label {
+			return &extension.Priorities[i]
+		}
+	}
+	return nil
+}
+
+// add the entry of a ListDialplan event
+func (dialplan *Dialplan) add(ev *AMIEvent) {
+	name := ev.Get("Context")
+	c := dialplan.Context(name)
+	if c == nil {
+		c = &DialplanContext{Name: name, Registrar: ev.Get("Registrar")}
+		dialplan.Contexts = append(dialplan.Contexts, c)
+	}
+
+	if include := ev.Get("IncludeContext"); include != "" {
+		c.Includes = append(c.Includes, include)
+		return
+	}
+	if ev.Get("Extension") == "" {
+		return
+	}
+
+	extension := c.Extension(ev.Get("Extension"))
+	if extension == nil {
+		extension = &DialplanExtension{Name: ev.Get("Extension")}
+		c.Extensions = append(c.Extensions, extension)
+	}
+
+	priority := ev.Get("Priority")
+	if priority == "hint" {
+		extension.Hint = ev.Get("Application")
+		return
+	}
+	n, err := strconv.Atoi(priority)
+	if err != nil {
+		return
+	}
+	extension.Priorities = append(extension.Priorities, DialplanPriority{
+		Priority:    n,
+		Label:       ev.Get("ExtensionLabel"),
+		Application: ev.Get("Application"),
+		AppData:     ev.Get("AppData"),
+		Registrar:   ev.Get("Registrar"),
+	})
+}
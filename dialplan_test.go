@@ -0,0 +1,107 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+)
+
+func mockDialplan(srv *amiServer) {
+	srv.MockList("ShowDialPlan", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		if params.Get("Context") == "unknown" {
+			return []map[string]string{{"Response": "Error", "ActionID": id,
+				"Message": "Did not find context unknown"}}
+		}
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "ListDialplan", "ActionID": id, "Context": "internal", "IncludeContext": "features",
+				"Registrar": "pbx_config"},
+			{"Event": "ListDialplan", "ActionID": id, "Context": "internal", "Extension": "100",
+				"Priority": "hint", "Application": "SIP/100", "Registrar": "pbx_config"},
+			{"Event": "ListDialplan", "ActionID": id, "Context": "internal", "Extension": "100",
+				"Priority": "1", "Application": "Dial", "AppData": "SIP/100,30", "Registrar": "pbx_config"},
+			{"Event": "ListDialplan", "ActionID": id, "Context": "internal", "Extension": "100",
+				"ExtensionLabel": "busy", "Priority": "2", "Application": "VoiceMail", "AppData": "100@default",
+				"Registrar": "pbx_config"},
+			{"Event": "ListDialplan", "ActionID": id, "Context": "features", "Extension": "_*7X.",
+				"Priority": "1", "Application": "Pickup", "AppData": "${EXTEN:2}", "Registrar": "pbx_config"},
+			{"Event": "ShowDialPlanComplete", "ActionID": id, "EventList": "Complete", "ListItems": "5",
+				"ListExtensions": "2", "ListPriorities": "3", "ListContexts": "2"},
+		}
+	})
+}
+
+func TestShowDialplan(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	mockDialplan(srv)
+
+	dialplan, err := ami.ShowDialplan("internal", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dialplan.Contexts) != 2 {
+		t.Fatalf("unexpected contexts %+v", dialplan.Contexts)
+	}
+
+	internal := dialplan.Context("internal")
+	if internal == nil || len(internal.Includes) != 1 || internal.Includes[0] != "features" {
+		t.Fatalf("unexpected context %+v", internal)
+	}
+	extension := internal.Extension("100")
+	if extension == nil || extension.Hint != "SIP/100" || len(extension.Priorities) != 2 {
+		t.Fatalf("unexpected extension %+v", extension)
+	}
+	if p := extension.Label("busy"); p == nil || p.Priority != 2 || p.Application != "VoiceMail" {
+		t.Fatalf("unexpected priority %+v", p)
+	}
+	if p := dialplan.Context("features").Extension("_*7X.").Priority(1); p == nil || p.AppData != "${EXTEN:2}" {
+		t.Fatalf("unexpected priority %+v", p)
+	}
+}
+
+func TestValidateDestination(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	mockDialplan(srv)
+
+	if err := ami.ValidateDestination("internal", "100", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := ami.ValidateDestination("internal", "100", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := ami.ValidateDestination("internal", "100", 5); err == nil {
+		t.Fatal("expected missing priority")
+	}
+	if err := ami.ValidateDestination("unknown", "100", 1); err == nil ||
+		err.Error() != "Extension 100@unknown not found" {
+		t.Fatal("expected missing context, got", err)
+	}
+}
+
+func TestExtensionState(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.Mock("ExtensionState", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid"),
+			"Exten": params.Get("Exten"), "Context": params.Get("Context"), "Hint": "SIP/100",
+			"Status": "1", "StatusText": "InUse"}
+	})
+
+	state, err := ami.ExtensionState("100", "internal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Hint != "SIP/100" || state.Status != 1 || state.StatusText != "InUse" || state.Context != "internal" {
+		t.Fatalf("unexpected state %+v", state)
+	}
+}
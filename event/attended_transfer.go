@@ -0,0 +1,23 @@
+// Package event for AMI
+package event
+
+// AttendedTransfer triggered when an attended transfer is complete (Asterisk >= 12).
+type AttendedTransfer struct {
+	Privilege                []string
+	Result                   string `AMI:"Result"`
+	OrigTransfererChannel    string `AMI:"Origtransfererchannel"`
+	OrigTransfererUniqueID   string `AMI:"Origtransfereruniqueid"`
+	SecondTransfererChannel  string `AMI:"Secondtransfererchannel"`
+	SecondTransfererUniqueID string `AMI:"Secondtransfereruniqueid"`
+	TransfereeChannel        string `AMI:"Transfereechannel"`
+	TransfereeUniqueID       string `AMI:"Transfereeuniqueid"`
+	TransferTargetChannel    string `AMI:"Transfertargetchannel"`
+	TransferTargetUniqueID   string `AMI:"Transfertargetuniqueid"`
+	DestType                 string `AMI:"Desttype"`
+	DestBridgeUniqueID       string `AMI:"Destbridgeuniqueid"`
+	DestApp                  string `AMI:"Destapp"`
+}
+
+func init() {
+	eventTrap["AttendedTransfer"] = AttendedTransfer{}
+}
@@ -0,0 +1,37 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/googolgl/gami"
+)
+
+func TestAttendedTransfer(t *testing.T) {
+	fixture := map[string]string{
+		"Result":                   "Result",
+		"Origtransfererchannel":    "OrigTransfererChannel",
+		"Origtransfereruniqueid":   "OrigTransfererUniqueID",
+		"Secondtransfererchannel":  "SecondTransfererChannel",
+		"Secondtransfereruniqueid": "SecondTransfererUniqueID",
+		"Transfereechannel":        "TransfereeChannel",
+		"Transfereeuniqueid":       "TransfereeUniqueID",
+		"Transfertargetchannel":    "TransferTargetChannel",
+		"Transfertargetuniqueid":   "TransferTargetUniqueID",
+		"Desttype":                 "DestType",
+		"Destbridgeuniqueid":       "DestBridgeUniqueID",
+		"Destapp":                  "DestApp",
+	}
+
+	ev := gami.AMIEvent{
+		ID:        "AttendedTransfer",
+		Privilege: []string{"all"},
+		Params:    fixture,
+	}
+
+	evtype := New(&ev)
+	if _, ok := evtype.(AttendedTransfer); !ok {
+		t.Fatal("AttendedTransfer type assertion")
+	}
+
+	testEvent(t, fixture, evtype)
+}
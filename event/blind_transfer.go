@@ -0,0 +1,21 @@
+// Package event for AMI
+package event
+
+// BlindTransfer triggered when a blind transfer is complete (Asterisk >= 12).
+type BlindTransfer struct {
+	Privilege             []string
+	Result                string `AMI:"Result"`
+	TransfererChannel     string `AMI:"Transfererchannel"`
+	TransfererUniqueID    string `AMI:"Transfereruniqueid"`
+	TransfererCallerIDNum string `AMI:"Transferercalleridnum"`
+	TransfereeChannel     string `AMI:"Transfereechannel"`
+	TransfereeUniqueID    string `AMI:"Transfereeuniqueid"`
+	TransfereeCallerIDNum string `AMI:"Transfereecalleridnum"`
+	IsExternal            string `AMI:"Isexternal"`
+	Context               string `AMI:"Context"`
+	Extension             string `AMI:"Extension"`
+}
+
+func init() {
+	eventTrap["BlindTransfer"] = BlindTransfer{}
+}
@@ -0,0 +1,35 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/googolgl/gami"
+)
+
+func TestBlindTransfer(t *testing.T) {
+	fixture := map[string]string{
+		"Result":                "Result",
+		"Transfererchannel":     "TransfererChannel",
+		"Transfereruniqueid":    "TransfererUniqueID",
+		"Transferercalleridnum": "TransfererCallerIDNum",
+		"Transfereechannel":     "TransfereeChannel",
+		"Transfereeuniqueid":    "TransfereeUniqueID",
+		"Transfereecalleridnum": "TransfereeCallerIDNum",
+		"Isexternal":            "IsExternal",
+		"Context":               "Context",
+		"Extension":             "Extension",
+	}
+
+	ev := gami.AMIEvent{
+		ID:        "BlindTransfer",
+		Privilege: []string{"all"},
+		Params:    fixture,
+	}
+
+	evtype := New(&ev)
+	if _, ok := evtype.(BlindTransfer); !ok {
+		t.Fatal("BlindTransfer type assertion")
+	}
+
+	testEvent(t, fixture, evtype)
+}
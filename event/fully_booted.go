@@ -0,0 +1,15 @@
+// Package event for AMI
+package event
+
+// FullyBooted triggered when Asterisk is fully booted, it's also sent to
+// a session on login once Asterisk is up.
+type FullyBooted struct {
+	Privilege  []string
+	Status     string `AMI:"Status"`
+	Uptime     int64  `AMI:"Uptime"`
+	LastReload int64  `AMI:"Lastreload"`
+}
+
+func init() {
+	eventTrap["FullyBooted"] = FullyBooted{}
+}
@@ -0,0 +1,28 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/googolgl/gami"
+)
+
+func TestFullyBooted(t *testing.T) {
+	fixture := map[string]string{
+		"Status":     "Status",
+		"Uptime":     "Uptime",
+		"Lastreload": "LastReload",
+	}
+
+	ev := gami.AMIEvent{
+		ID:        "FullyBooted",
+		Privilege: []string{"all"},
+		Params:    fixture,
+	}
+
+	evtype := New(&ev)
+	if _, ok := evtype.(FullyBooted); !ok {
+		t.Fatal("FullyBooted type assertion")
+	}
+
+	testEvent(t, fixture, evtype)
+}
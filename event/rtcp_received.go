@@ -0,0 +1,29 @@
+// Package event for AMI
+package event
+
+// RTCPReceived triggered when a RTCP report is received, the report block
+// describes the stream sent to the remote.
+type RTCPReceived struct {
+	Privilege                   []string
+	Channel                     string `AMI:"Channel"`
+	UniqueID                    string `AMI:"Uniqueid"`
+	LinkedID                    string `AMI:"Linkedid"`
+	To                          string `AMI:"To"`
+	From                        string `AMI:"From"`
+	RTT                         string `AMI:"Rtt"`
+	SSRC                        string `AMI:"Ssrc"`
+	PT                          string `AMI:"Pt"`
+	ReportCount                 int64  `AMI:"Reportcount"`
+	Report0SourceSSRC           string `AMI:"Report0sourcessrc"`
+	Report0FractionLost         int64  `AMI:"Report0fractionlost"`
+	Report0CumulativeLost       int64  `AMI:"Report0cumulativelost"`
+	Report0HighestSequence      int64  `AMI:"Report0highestsequence"`
+	Report0SequenceNumberCycles int64  `AMI:"Report0sequencenumbercycles"`
+	Report0IAJitter             int64  `AMI:"Report0iajitter"`
+	Report0LSR                  string `AMI:"Report0lsr"`
+	Report0DLSR                 string `AMI:"Report0dlsr"`
+}
+
+func init() {
+	eventTrap["RTCPReceived"] = RTCPReceived{}
+}
@@ -0,0 +1,32 @@
+// Package event for AMI
+package event
+
+// RTCPSent triggered when a RTCP report is sent, the report block describes
+// the stream received from the remote.
+type RTCPSent struct {
+	Privilege                   []string
+	Channel                     string `AMI:"Channel"`
+	UniqueID                    string `AMI:"Uniqueid"`
+	LinkedID                    string `AMI:"Linkedid"`
+	To                          string `AMI:"To"`
+	From                        string `AMI:"From"`
+	SSRC                        string `AMI:"Ssrc"`
+	PT                          string `AMI:"Pt"`
+	ReportCount                 int64  `AMI:"Reportcount"`
+	SentNTP                     string `AMI:"Sentntp"`
+	SentRTP                     string `AMI:"Sentrtp"`
+	SentPackets                 int64  `AMI:"Sentpackets"`
+	SentOctets                  int64  `AMI:"Sentoctets"`
+	Report0SourceSSRC           string `AMI:"Report0sourcessrc"`
+	Report0FractionLost         int64  `AMI:"Report0fractionlost"`
+	Report0CumulativeLost       int64  `AMI:"Report0cumulativelost"`
+	Report0HighestSequence      int64  `AMI:"Report0highestsequence"`
+	Report0SequenceNumberCycles int64  `AMI:"Report0sequencenumbercycles"`
+	Report0IAJitter             int64  `AMI:"Report0iajitter"`
+	Report0LSR                  string `AMI:"Report0lsr"`
+	Report0DLSR                 string `AMI:"Report0dlsr"`
+}
+
+func init() {
+	eventTrap["RTCPSent"] = RTCPSent{}
+}
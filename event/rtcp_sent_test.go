@@ -0,0 +1,45 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/googolgl/gami"
+)
+
+func TestRTCPSent(t *testing.T) {
+	fixture := map[string]string{
+		"Channel":                     "Channel",
+		"Uniqueid":                    "UniqueID",
+		"Linkedid":                    "LinkedID",
+		"To":                          "To",
+		"From":                        "From",
+		"Ssrc":                        "SSRC",
+		"Pt":                          "PT",
+		"Reportcount":                 "ReportCount",
+		"Sentntp":                     "SentNTP",
+		"Sentrtp":                     "SentRTP",
+		"Sentpackets":                 "SentPackets",
+		"Sentoctets":                  "SentOctets",
+		"Report0sourcessrc":           "Report0SourceSSRC",
+		"Report0fractionlost":         "Report0FractionLost",
+		"Report0cumulativelost":       "Report0CumulativeLost",
+		"Report0highestsequence":      "Report0HighestSequence",
+		"Report0sequencenumbercycles": "Report0SequenceNumberCycles",
+		"Report0iajitter":             "Report0IAJitter",
+		"Report0lsr":                  "Report0LSR",
+		"Report0dlsr":                 "Report0DLSR",
+	}
+
+	ev := gami.AMIEvent{
+		ID:        "RTCPSent",
+		Privilege: []string{"all"},
+		Params:    fixture,
+	}
+
+	evtype := New(&ev)
+	if _, ok := evtype.(RTCPSent); !ok {
+		t.Fatal("RTCPSent type assertion")
+	}
+
+	testEvent(t, fixture, evtype)
+}
@@ -0,0 +1,14 @@
+// Package event for AMI
+package event
+
+// Shutdown triggered when Asterisk is shutting down, Shutdown tells if it's
+// done Cleanly or Uncleanly and Restart if it's followed by a start.
+type Shutdown struct {
+	Privilege []string
+	Shutdown  string `AMI:"Shutdown"`
+	Restart   string `AMI:"Restart"`
+}
+
+func init() {
+	eventTrap["Shutdown"] = Shutdown{}
+}
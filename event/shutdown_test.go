@@ -0,0 +1,27 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/googolgl/gami"
+)
+
+func TestShutdown(t *testing.T) {
+	fixture := map[string]string{
+		"Shutdown": "Shutdown",
+		"Restart":  "Restart",
+	}
+
+	ev := gami.AMIEvent{
+		ID:        "Shutdown",
+		Privilege: []string{"all"},
+		Params:    fixture,
+	}
+
+	evtype := New(&ev)
+	if _, ok := evtype.(Shutdown); !ok {
+		t.Fatal("Shutdown type assertion")
+	}
+
+	testEvent(t, fixture, evtype)
+}
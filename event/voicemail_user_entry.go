@@ -0,0 +1,22 @@
+// Package event for AMI
+package event
+
+// VoicemailUserEntry triggered for each mailbox when an action VoicemailUsersList is issued.
+type VoicemailUserEntry struct {
+	Privilege       []string
+	VMContext       string `AMI:"Vmcontext"`
+	VoiceMailbox    string `AMI:"Voicemailbox"`
+	FullName        string `AMI:"Fullname"`
+	Email           string `AMI:"Email"`
+	Pager           string `AMI:"Pager"`
+	ServerEmail     string `AMI:"Serveremail"`
+	Language        string `AMI:"Language"`
+	TimeZone        string `AMI:"Timezone"`
+	MaxMessageCount int64  `AMI:"Maxmessagecount"`
+	NewMessageCount int64  `AMI:"Newmessagecount"`
+	OldMessageCount int64  `AMI:"Oldmessagecount"`
+}
+
+func init() {
+	eventTrap["VoicemailUserEntry"] = VoicemailUserEntry{}
+}
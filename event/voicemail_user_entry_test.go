@@ -0,0 +1,36 @@
+package event
+
+import (
+	"testing"
+
+	"github.com/googolgl/gami"
+)
+
+func TestVoicemailUserEntry(t *testing.T) {
+	fixture := map[string]string{
+		"Vmcontext":       "VMContext",
+		"Voicemailbox":    "VoiceMailbox",
+		"Fullname":        "FullName",
+		"Email":           "Email",
+		"Pager":           "Pager",
+		"Serveremail":     "ServerEmail",
+		"Language":        "Language",
+		"Timezone":        "TimeZone",
+		"Maxmessagecount": "MaxMessageCount",
+		"Newmessagecount": "NewMessageCount",
+		"Oldmessagecount": "OldMessageCount",
+	}
+
+	ev := gami.AMIEvent{
+		ID:        "VoicemailUserEntry",
+		Privilege: []string{"all"},
+		Params:    fixture,
+	}
+
+	evtype := New(&ev)
+	if _, ok := evtype.(VoicemailUserEntry); !ok {
+		t.Fatal("VoicemailUserEntry type assertion")
+	}
+
+	testEvent(t, fixture, evtype)
+}
@@ -0,0 +1,206 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var errEmptyFilter = errors.New("Empty Filter")
+
+// filterOps operators of the predicates, the longest first
+var filterOps = []string{"!=", "!~", ">=", "<=", "=", "~", ">", "<"}
+
+// Filter an event filter parsed from an expression by ParseFilter, its
+// Match can be given to EventFilter
+type Filter struct {
+	expr string
+	// names glob patterns in lower case of the event names, nil match any
+	names      []string
+	predicates []filterPredicate
+}
+
+type filterPredicate struct {
+	key   string
+	op    string
+	value string
+	re    *regexp.Regexp
+	num   float64
+}
+
+// ParseFilter parse a filter expression: an optional event name, or names
+// separated by | with * and ? as wildcards, followed by param predicates
+// that must all hold. A predicate is Key=value, Key!=value, Key~regexp,
+// Key!~regexp or a number compared with >, >=, < and <=, the values with
+// spaces are quoted
+//
+//	Hangup|Newchannel Channel~^PJSIP/ Context="from internal"
+//	Queue* Queue=sales HoldTime>30
+func ParseFilter(expr string) (*Filter, error) {
+	tokens, err := filterTokens(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errEmptyFilter
+	}
+
+	filter := &Filter{expr: expr}
+	for i, token := range tokens {
+		key, op, value := splitPredicate(token)
+		if op == "" {
+			if i > 0 {
+				return nil, fmt.Errorf("filter: %q is not a predicate Key<op>value", token)
+			}
+			for _, name := range strings.Split(token, "|") {
+				name = strings.ToLower(name)
+				if _, err := path.Match(name, ""); err != nil || name == "" {
+					return nil, fmt.Errorf("filter: invalid event name %q", token)
+				}
+				filter.names = append(filter.names, name)
+			}
+			continue
+		}
+		if key == "" {
+			return nil, fmt.Errorf("filter: predicate %q without key", token)
+		}
+
+		predicate := filterPredicate{key: key, op: op, value: value}
+		switch op {
+		case "~", "!~":
+			if predicate.re, err = regexp.Compile(value); err != nil {
+				return nil, fmt.Errorf("filter: %s: %s", token, err)
+			}
+		case ">", ">=", "<", "<=":
+			if predicate.num, err = strconv.ParseFloat(value, 64); err != nil {
+				return nil, fmt.Errorf("filter: %s: %q is not a number", token, value)
+			}
+		}
+		filter.predicates = append(filter.predicates, predicate)
+	}
+	return filter, nil
+}
+
+// MustParseFilter like ParseFilter but panic if the expression is invalid,
+// for filters written in the code
+//
+//	client.Configure(gami.EventFilter(gami.MustParseFilter("Hangup Cause=16").Match))
+func MustParseFilter(expr string) *Filter {
+	filter, err := ParseFilter(expr)
+	if err != nil {
+		panic(err)
+	}
+	return filter
+}
+
+// String return the expression of the filter
+func (filter *Filter) String() string {
+	return filter.expr
+}
+
+// Match check if the event passes the filter
+func (filter *Filter) Match(ev *AMIEvent) bool {
+	if len(filter.names) > 0 {
+		name := strings.ToLower(ev.ID)
+		matched := false
+		for _, pattern := range filter.names {
+			if ok, _ := path.Match(pattern, name); ok {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+
+	for _, predicate := range filter.predicates {
+		if !predicate.holds(ev.Get(predicate.key)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (predicate *filterPredicate) holds(value string) bool {
+	switch predicate.op {
+	case "=":
+		return value == predicate.value
+	case "!=":
+		return value != predicate.value
+	case "~":
+		return predicate.re.MatchString(value)
+	case "!~":
+		return !predicate.re.MatchString(value)
+	}
+
+	num, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		return false
+	}
+	switch predicate.op {
+	case ">":
+		return num > predicate.num
+	case ">=":
+		return num >= predicate.num
+	case "<":
+		return num < predicate.num
+	}
+	return num <= predicate.num
+}
+
+// splitPredicate split token on its first operator, op is empty when
+// there is none
+func splitPredicate(token string) (key, op, value string) {
+	for i := 0; i < len(token); i++ {
+		for _, candidate := range filterOps {
+			if strings.HasPrefix(token[i:], candidate) {
+				return token[:i], candidate, token[i+len(candidate):]
+			}
+		}
+	}
+	return token, "", ""
+}
+
+// filterTokens split expr on spaces, a double quoted part keeps its spaces
+// and \" is a quote inside it
+func filterTokens(expr string) ([]string, error) {
+	var tokens []string
+	var token strings.Builder
+	inToken, quoted := false, false
+
+	for i := 0; i < len(expr); i++ {
+		c := expr[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(expr) && expr[i+1] == '"':
+			token.WriteByte('"')
+			i++
+		case c == '"':
+			quoted = !quoted
+			inToken = true
+		case !quoted && (c == ' ' || c == '\t'):
+			if inToken {
+				tokens = append(tokens, token.String())
+				token.Reset()
+				inToken = false
+			}
+		default:
+			token.WriteByte(c)
+			inToken = true
+		}
+	}
+	if quoted {
+		return nil, errors.New("filter: unterminated quote")
+	}
+	if inToken {
+		tokens = append(tokens, token.String())
+	}
+	return tokens, nil
+}
@@ -0,0 +1,74 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"testing"
+)
+
+func TestFilterMatch(t *testing.T) {
+	hangup := &AMIEvent{ID: "Hangup", Params: map[string]string{
+		"Channel": "PJSIP/100-00000001", "Cause": "16", "Context": "from internal",
+	}}
+	join := &AMIEvent{ID: "QueueCallerJoin", Params: map[string]string{
+		"Queue": "sales", "Position": "3",
+	}}
+
+	tests := []struct {
+		expr   string
+		hangup bool
+		join   bool
+	}{
+		{"Hangup", true, false},
+		{"hangup|queuecallerjoin", true, true},
+		{"Queue*", false, true},
+		{"Channel~^PJSIP/", true, false},
+		{"Hangup Channel!~^SIP/ Cause=16", true, false},
+		{"Hangup Cause!=16", false, false},
+		{`Context="from internal"`, true, false},
+		{`Hangup context="from internal" cause>=16 cause<17`, true, false},
+		{"Queue=sales Position>2", false, true},
+		{"Position<=2", false, false},
+		// a missing param is empty, not a number
+		{"Cause<100", true, false},
+		{"Queue!=", false, true},
+	}
+	for _, test := range tests {
+		filter, err := ParseFilter(test.expr)
+		if err != nil {
+			t.Fatal(test.expr, err)
+		}
+		if filter.Match(hangup) != test.hangup || filter.Match(join) != test.join {
+			t.Errorf("%s: expected Hangup %v, QueueCallerJoin %v", test.expr, test.hangup, test.join)
+		}
+		if filter.String() != test.expr {
+			t.Errorf("unexpected String %q", filter.String())
+		}
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"Hangup Channel",
+		"=value",
+		"Channel~(",
+		"Cause>high",
+		`Context="from internal`,
+		"Hangup[",
+	} {
+		if _, err := ParseFilter(expr); err == nil {
+			t.Errorf("%q: expected error", expr)
+		}
+	}
+}
+
+func TestFilterQuotes(t *testing.T) {
+	filter := MustParseFilter(`CallerIDName="Said \"hi\""`)
+	ev := &AMIEvent{ID: "Newchannel", Params: map[string]string{"CallerIDName": `Said "hi"`}}
+	if !filter.Match(ev) {
+		t.Fatal("quoted value not matched")
+	}
+}
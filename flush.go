@@ -0,0 +1,34 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"time"
+)
+
+// BufferedWrites keep the actions sent on a buffer until Flush, or every
+// interval if it's greater than 0, so bulk sends share writes. The helpers
+// waiting for a response flush the buffer themselves.
+func BufferedWrites(interval time.Duration) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.bufferedWrites = true
+		c.flushInterval = interval
+	}
+}
+
+// Flush write the actions buffered to the connection
+func (client *AMIClient) Flush() error {
+	client.mutexAsyncAction.Lock()
+	defer client.mutexAsyncAction.Unlock()
+	return client.conn.W.Flush()
+}
+
+// autoFlushInterval interval of the automatic flush, 0 when disabled
+func (client *AMIClient) autoFlushInterval() time.Duration {
+	if !client.bufferedWrites {
+		return 0
+	}
+	return client.flushInterval
+}
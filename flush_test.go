@@ -0,0 +1,79 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestBufferedWritesFlush(t *testing.T) {
+	srv := newAmiServer()
+	srv.maxDelay = 0
+	defer srv.Close()
+	ami, err := Dial(srv.Addr, BufferedWrites(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	response, _, err := ami.Action(Params{"Action": "Ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-response:
+		t.Fatal("action sent without Flush")
+	case <-time.After(time.Millisecond * 300):
+	}
+
+	if err := ami.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-response:
+	case <-time.After(time.Second * 5):
+		t.Fatal("action not sent on Flush")
+	}
+
+	srv.Mock("MailboxCount", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid"), "NewMessages": "1"}
+	})
+	if _, err := ami.MailboxCount("1000@default"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBufferedWritesInterval(t *testing.T) {
+	srv := newAmiServer()
+	srv.maxDelay = 0
+	defer srv.Close()
+	ami, err := Dial(srv.Addr, BufferedWrites(time.Millisecond*50))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	var responses []<-chan *AMIResponse
+	for i := 0; i < 10; i++ {
+		response, _, err := ami.Action(Params{"Action": "Ping"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		responses = append(responses, response)
+	}
+
+	for _, response := range responses {
+		select {
+		case <-response:
+		case <-time.After(time.Second * 5):
+			t.Fatal("action not flushed")
+		}
+	}
+}
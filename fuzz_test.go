@@ -0,0 +1,83 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+//go:build go1.18
+// +build go1.18
+
+package gami
+
+import (
+	"bufio"
+	"bytes"
+	"net/textproto"
+	"testing"
+)
+
+// fuzzFrames seeds with well formed and malformed frames
+var fuzzFrames = []string{
+	"Event: Newchannel\r\nPrivilege: call,all\r\nChannel: SIP/100-01\r\nUniqueid: 1.1\r\n\r\n",
+	"Response: Success\r\nActionID: 1\r\nMessage: Authentication accepted\r\n\r\n",
+	"Response: Follows\r\nPrivilege: Command\r\nActionID: 2\r\nName: x\r\n\r\nline 2\r\n--END COMMAND--\r\n\r\n",
+	"Response: Success\r\nActionID: 3\r\nOutput: a: b\r\nOutput: c\r\n\r\n",
+	"Event: Newstate\r\nChanVariable: FOO=bar\r\nChanVariable(SIP/1): =\r\nDestChanVariable: X\r\n\r\n",
+	"Event\r\n: value\r\nkey:\r\n\x00: \x00\r\nEvent: \x00\r\n\r\n",
+	"Response: Follows\r\n--END COMMAND--",
+	"ChanVariable(: x\r\nchanvariable(): y\r\nCHANVARIABLE: z\r\n\r\n",
+}
+
+func FuzzReadFrame(f *testing.F) {
+	for _, frame := range fuzzFrames {
+		f.Add([]byte(frame))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := textproto.NewReader(bufio.NewReader(bytes.NewReader(data)))
+		for i := 0; i <= len(data); i++ {
+			frame, err := ReadFrame(r)
+			if ev, err := newEvent(&frame); err == nil && ev.ID == "" {
+				t.Fatal("event without ID")
+			}
+			if resp, err := newResponse(&frame); err == nil && resp.Status == "" {
+				t.Fatal("response without status")
+			}
+			if err != nil {
+				return
+			}
+		}
+		t.Fatal("ReadFrame doesn't consume the input")
+	})
+}
+
+func FuzzNewEvent(f *testing.F) {
+	f.Add("Newchannel", "Chanvariable", "FOO=bar")
+	f.Add("Hangup", "ChanVariable(SIP/1)", "=")
+	f.Add("Dial", "Destchanvariable", "")
+
+	f.Fuzz(func(t *testing.T, id, key, value string) {
+		data := textproto.MIMEHeader{"Event": {id}, key: {value, value}}
+		ev, err := newEvent(&data)
+		if err != nil {
+			return
+		}
+		if ev.ID != id {
+			t.Fatal("unexpected ID", ev.ID)
+		}
+	})
+}
+
+func FuzzNewResponse(f *testing.F) {
+	f.Add("Success", "Output", "line")
+	f.Add("Follows", "Actionid", "")
+
+	f.Fuzz(func(t *testing.T, status, key, value string) {
+		data := textproto.MIMEHeader{"Response": {status}, key: {value}}
+		resp, err := newResponse(&data)
+		if err != nil {
+			return
+		}
+		if resp.Status != status {
+			t.Fatal("unexpected status", resp.Status)
+		}
+	})
+}
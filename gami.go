@@ -6,6 +6,7 @@
 package gami
 
 import (
+	"context"
 	"crypto/tls"
 	"errors"
 	"fmt"
@@ -14,7 +15,7 @@ import (
 	"net/textproto"
 	"strings"
 	"sync"
-	"syscall"
+	"sync/atomic"
 	"time"
 )
 
@@ -22,30 +23,148 @@ var (
 	errNoAMI         = errors.New("Server doesn`t have AMI interface")
 	errNoEvent       = errors.New("No Event")
 	errInvalidParams = errors.New("Invalid Params")
+	errFrameTooLong  = errors.New("Frame too long")
+	errListTimeout   = errors.New("List not completed in time")
+	// errConnectionLost ends the lists of a connection lost
+	errConnectionLost = errors.New(connectionLostMessage)
 )
 
+// listTimeout to receive the events of a list until its complete event
+var listTimeout = 30 * time.Second
+
+// maxFrameLines lines read before giving up on a frame without end
+const maxFrameLines = 100000
+
 // Params for the actions
 type Params map[string]string
 
 // AMIClient a connection to AMI server
 type AMIClient struct {
+	// eventLag in nanoseconds of the last event sent on Events, first for
+	// the alignment of atomic
+	eventLag int64
+	// lastRead unix time in nanoseconds of the last frame read
+	lastRead int64
+	// sessionDown 1 after a Shutdown event until a new connection
+	sessionDown int32
+
 	conn             *textproto.Conn
 	connRaw          io.ReadWriteCloser
 	mutexAsyncAction *sync.RWMutex
-
-	address     string
-	amiUser     string
-	amiPass     string
-	useTLS      bool
-	unsecureTLS bool
+	mutexListeners   *sync.RWMutex
+	// mutexRouter guard router and the indexes of its routes
+	mutexRouter *sync.RWMutex
+
+	address       string
+	authenticator Authenticator
+	useTLS        bool
+	unsecureTLS   bool
+	readOnly      bool
+
+	// allowActions names in lower case of the only actions sent, nil
+	// allow all
+	allowActions map[string]bool
+	// denyActions names in lower case of the actions refused
+	denyActions map[string]bool
+
+	// defaultParams added to every action, normalised
+	defaultParams Params
+	// actionDefaults params added by name in lower case of the action
+	actionDefaults map[string]Params
+	// actionIDPrefix of the ActionID generated
+	actionIDPrefix string
+
+	// stateStore of the trackers, nil keep the state in memory
+	stateStore StateStore
+
+	// capabilities of the server, nil until DetectCapabilities
+	capabilities *Capabilities
 
 	// TLSConfig for secure connections
 	tlsConfig *tls.Config
 
-	// network wait for a new connection
-	waitNewConnection chan struct{}
-
-	response map[string]chan *AMIResponse
+	// generation of the connection, incremented on every new connection,
+	// guarded with conn by mutexAsyncAction
+	generation uint64
+	// connChanged closed and replaced when a new connection is set
+	connChanged chan struct{}
+	// reconnects requests to ownConnection, the only one reconnecting
+	reconnects chan reconnectRequest
+	// bootedGeneration generation of the connection of the last
+	// FullyBooted, used only by the reader
+	bootedGeneration uint64
+
+	// response actions waiting for their response by ActionID
+	response *pendingMap
+
+	// bannerTimeout to read the banner on connect, 0 wait forever
+	bannerTimeout time.Duration
+	// bannerCheck validate the banner on connect, nil accept any banner
+	bannerCheck func(banner string) bool
+	// bannerPrefixes accepted besides bannerCheck
+	bannerPrefixes []string
+
+	// strict validate the frames read, see StrictMode
+	strict bool
+
+	// routeActionEvents deliver the events tagged with the ActionID of an
+	// action on its response
+	routeActionEvents bool
+
+	// bufferedWrites actions are not flushed on send
+	bufferedWrites bool
+	// flushInterval of the buffered writes, 0 flush only on Flush
+	flushInterval time.Duration
+
+	// closed on Close to stop the goroutines of the client
+	closed    chan struct{}
+	closeOnce *sync.Once
+
+	// mutexConfig guard the options that can change with Configure
+	mutexConfig *sync.RWMutex
+	// reconfigured closed and replaced on Configure to wake up the loops
+	// depending on the options
+	reconfigured chan struct{}
+	// eventFilter of the events sent on Events, nil accept all
+	eventFilter func(*AMIEvent) bool
+	// history of the events read for EventsSince, nil keep none
+	history *eventHistory
+	// keepAlive interval of the Ping sent to keep the session, 0 disable it
+	keepAlive time.Duration
+	// validateIdle idle time after which the session is validated before
+	// the validateActions, by name in lower case, 0 disable it
+	validateIdle    time.Duration
+	validateTimeout time.Duration
+	validateActions map[string]bool
+	// limiter of the actions sent, nil unlimited
+	limiter *rateLimiter
+
+	// logger for diagnostics, nil disable logging
+	logger Logger
+	// slowAction threshold to log the actions answered late, 0 disable it
+	slowAction time.Duration
+	// interceptors observing the actions, their responses and events
+	interceptors []Interceptor
+	// onShutdown and onFullyBooted hooks of the start and stop of Asterisk
+	onShutdown    func(*AMIEvent)
+	onFullyBooted func(*AMIEvent)
+
+	// listeners receive the events tagged with the ActionID of an action
+	// issued by the helpers, instead of Events
+	listeners map[string]*eventListener
+	// syncers of the helpers by their watcher, called when Asterisk boots
+	// after a Reconnect
+	syncers map[*eventListener]func() error
+	// contexts of the actions sent with ActionContext by ActionID, kept
+	// while their events are expected, guarded by mutexListeners
+	contexts map[string]context.Context
+
+	// router of the events to the Routes by entity, nil without routes
+	router *eventRouter
+
+	// watchers receive a copy of every event read, the slice is replaced
+	// on changes so it can be read without copying
+	watchers []*eventListener
 
 	// Events for client parse
 	Events chan *AMIEvent
@@ -55,6 +174,16 @@ type AMIClient struct {
 
 	//NetError a network error
 	NetError chan error
+
+	// Malformed frames refused with StrictMode, dropped while it's full
+	Malformed chan *MalformedFrame
+}
+
+// eventListener collects the events generated by a single action or
+// watched by a helper
+type eventListener struct {
+	events chan *AMIEvent
+	done   chan struct{}
 }
 
 // AMIResponse from action
@@ -62,6 +191,13 @@ type AMIResponse struct {
 	ID     string
 	Status string
 	Params map[string]string
+	// Events generated by the action, filled with RouteActionEvents
+	Events []*AMIEvent
+	// Repeated headers with all their values, Params keeps the first one,
+	// nil when no header is repeated
+	Repeated map[string][]string
+	// ctx of the action given to ActionContext
+	ctx context.Context
 }
 
 // AMIEvent it's a representation of Event readed
@@ -71,6 +207,12 @@ type AMIEvent struct {
 	Privilege []string
 	// Params  of arguments received
 	Params map[string]string
+	// ChanVariables variables attached by channelvars, by channel name
+	ChanVariables map[string]map[string]string
+	// Received when the event was read from the socket
+	Received time.Time
+	// ctx of the action sent with ActionContext the event is tagged with
+	ctx context.Context
 }
 
 //UseTLS
@@ -91,104 +233,158 @@ func UnsecureTLS(c *AMIClient) {
 	c.unsecureTLS = true
 }
 
-// Login authenticate to AMI
+// Login authenticate to AMI, the credentials are reused by Reconnect
 func (client *AMIClient) Login(username, password string) error {
-	response, _, err := client.Action(Params{"Action": "Login", "Username": username, "Secret": password})
-	if err != nil {
-		return err
-	}
-
-	resp := <-response
-	if resp.Status == "Error" {
-		return errors.New(resp.Params["Message"])
-	}
-
-	client.amiUser = username
-	client.amiPass = password
-
-	return nil
-}
-
-// Reconnect the session, autologin if a new network error it put on client.NetError
-func (client *AMIClient) Reconnect() error {
-	client.conn.Close()
-
-	err := client.NewConn()
-
-	if err != nil {
-		client.NetError <- err
-		return err
-	}
-
-	client.waitNewConnection <- struct{}{}
-
-	if err := client.Login(client.amiUser, client.amiPass); err != nil {
+	auth := PlainAuth(StaticCredentials(username, password))
+	if err := auth.Authenticate(client); err != nil {
 		return err
 	}
 
+	client.authenticator = auth
 	return nil
 }
 
 // Action return chan for wait response of action with parameter *ActionID* this can be helpful for
 // massive actions,
+//
+// The frames are delivered in the order they are read: the response is on
+// the chan before the events that follow it are on Events or the helpers
 func (client *AMIClient) Action(p Params) (<-chan *AMIResponse, string, error) {
-	client.mutexAsyncAction.Lock()
-	defer client.mutexAsyncAction.Unlock()
+	return client.action(nil, p)
+}
 
+// action send the action carrying ctx, nil when it has no context
+func (client *AMIClient) action(ctx context.Context, p Params, options ...ActionOption) (<-chan *AMIResponse, string, error) {
 	if p == nil {
 		return nil, "", errInvalidParams
 	}
+	if client.SessionDown() {
+		return nil, "", ErrSessionDown
+	}
 
-	client.normaliser(&p)
+	client.mutexConfig.RLock()
+	routeActionEvents, bufferedWrites := client.routeActionEvents, client.bufferedWrites
+	limiter := client.limiter
+	interceptors := client.interceptors
+	validateIdle, validateTimeout, validateActions := client.validateIdle, client.validateTimeout, client.validateActions
+	client.mutexConfig.RUnlock()
 
-	if _, ok := p["Action"]; !ok {
-		return nil, "", errInvalidParams
+	if limiter != nil {
+		limiter.wait()
 	}
 
-	if _, ok := client.response[p["Actionid"]]; !ok {
-		client.response[p["Actionid"]] = make(chan *AMIResponse, 1)
+	p, err := client.prepare(p)
+	if err != nil {
+		return nil, "", err
 	}
 
-	var output string
-	for k, v := range p {
-		output += fmt.Sprintf("%s: %s\r\n", k, v)
+	if validateIdle > 0 && validateActions[strings.ToLower(p["Action"])] {
+		if err := client.validateSession(validateIdle, validateTimeout); err != nil {
+			return nil, "", err
+		}
 	}
 
-	if err := client.conn.PrintfLine("%s", output); err != nil {
+	pending := client.response.register(p["Actionid"], func() *pendingAction {
+		pending := client.newPendingAction(p["Action"])
+		pending.ctx = ctx
+		pending.async = isAsyncOriginate(p)
+		pending.apply(options)
+		if routeActionEvents && !pending.untilComplete {
+			pending.listener = client.listenOnce(p["Actionid"])
+		}
+		return pending
+	})
+	if ctx != nil {
+		client.trackContext(p["Actionid"], ctx)
+	}
+	for _, interceptor := range interceptors {
+		if interceptor.Action != nil {
+			interceptor.Action(contextOf(ctx), p)
+		}
+	}
+
+	output := encodeAction(p)
+
+	client.mutexAsyncAction.Lock()
+	defer client.mutexAsyncAction.Unlock()
+
+	if _, err := client.conn.W.WriteString(output); err != nil {
+		client.response.take(p["Actionid"])
+		client.untrackContext(p["Actionid"])
 		return nil, "", err
 	}
+	if !bufferedWrites {
+		if err := client.conn.W.Flush(); err != nil {
+			client.response.take(p["Actionid"])
+			client.untrackContext(p["Actionid"])
+			return nil, "", err
+		}
+	}
 
-	return client.response[p["Actionid"]], p["Actionid"], nil
+	return pending.response, p["Actionid"], nil
 }
 
 // Run process socket waiting events and responses
 func (client *AMIClient) Run() {
 	go func() {
 		for {
-			data, err := client.conn.ReadMIMEHeader()
+			conn, generation, changed := client.connection()
+			client.mutexConfig.RLock()
+			strict := client.strict
+			client.mutexConfig.RUnlock()
+
+			var data textproto.MIMEHeader
+			var err error
+			if strict {
+				var malformed *MalformedFrame
+				data, malformed, err = readStrictFrame(&conn.Reader)
+				if malformed != nil {
+					client.reportMalformed(malformed)
+					continue
+				}
+			} else {
+				data, err = ReadFrame(&conn.Reader)
+			}
 			if err != nil {
-				switch err {
-				case syscall.ECONNABORTED:
-					fallthrough
-				case syscall.ECONNRESET:
-					fallthrough
-				case syscall.ECONNREFUSED:
-					fallthrough
-				case io.EOF:
-					client.NetError <- err
-					<-client.waitNewConnection
+				select {
+				case <-client.closed:
+					return
 				default:
+				}
+				if _, current, _ := client.connection(); current != generation {
+					// closed by Reconnect, read the new one
+					continue
+				}
+				if !isNetError(err) {
 					client.Error <- err
+					continue
+				}
+				client.connectionLost()
+				select {
+				case client.NetError <- err:
+				case <-client.closed:
+					return
+				}
+				select {
+				case <-changed:
+				case <-client.closed:
+					return
 				}
 				continue
 			}
 
+			received := time.Now()
+			client.touch()
 			if ev, err := newEvent(&data); err != nil {
 				if err != errNoEvent {
 					client.Error <- err
 				}
 			} else {
-				client.Events <- ev
+				ev.Received = received
+				client.dispatchEvent(ev)
+				// events as OriginateResponse carry Response but they
+				// don't answer the action
+				continue
 			}
 
 			//only handle valid responses
@@ -204,21 +400,230 @@ func (client *AMIClient) Run() {
 
 // Close the connection to AMI
 func (client *AMIClient) Close() {
+	client.closeOnce.Do(func() {
+		close(client.closed)
+	})
 	client.Action(Params{"Action": "Logoff"})
-	(client.connRaw).Close()
+	client.Flush()
+
+	client.mutexAsyncAction.RLock()
+	defer client.mutexAsyncAction.RUnlock()
+	client.connRaw.Close()
 }
 
+// notifyResponse deliver the response to the action waiting for it, before
+// the next frame is read so the events following the response are seen
+// after it. The response of a list routed with RouteActionEvents is
+// delivered once the list is complete
 func (client *AMIClient) notifyResponse(response *AMIResponse) {
-	go func() {
-		client.mutexAsyncAction.RLock()
-		client.response[response.ID] <- response
-		close(client.response[response.ID])
-		client.mutexAsyncAction.RUnlock()
-
-		client.mutexAsyncAction.Lock()
-		delete(client.response, response.ID)
-		client.mutexAsyncAction.Unlock()
-	}()
+	pending, ok := client.response.get(response.ID)
+	if !ok {
+		client.logf("gami: response %s for unknown ActionID %q", response.Status, response.ID)
+		return
+	}
+	if pending.untilComplete {
+		client.notifyUntilComplete(pending, response)
+		return
+	}
+	client.response.take(response.ID)
+
+	pending.answered(client, response.ID)
+	response.ctx = pending.ctx
+	if pending.ctx != nil && !announcesList(response) && !(pending.async && response.Status == "Success") {
+		client.untrackContext(response.ID)
+	}
+	if pending.listener != nil {
+		if announcesList(response) {
+			go func() {
+				client.collectActionEvents(response, pending.listener)
+				client.interceptResponse(response)
+				pending.deliver(response)
+			}()
+			return
+		}
+		client.unlisten(response.ID)
+	}
+	client.interceptResponse(response)
+	pending.deliver(response)
+}
+
+// dispatchEvent deliver the event to the listener waiting for its ActionID
+// or to Events otherwise
+func (client *AMIClient) dispatchEvent(ev *AMIEvent) {
+	client.mutexConfig.RLock()
+	history := client.history
+	client.mutexConfig.RUnlock()
+	if history != nil {
+		history.add(ev)
+	}
+	client.lifecycle(ev)
+	if completesList(ev) {
+		client.completeResponses(ev.Params["Actionid"])
+	}
+
+	client.mutexListeners.RLock()
+	listener, ok := client.listeners[ev.Params["Actionid"]]
+	watchers := client.watchers
+	ev.ctx = client.contexts[ev.Params["Actionid"]]
+	client.mutexListeners.RUnlock()
+
+	if ev.ctx != nil {
+		client.interceptEvent(ev)
+	}
+
+	for _, watcher := range watchers {
+		select {
+		case watcher.events <- ev:
+		case <-watcher.done:
+		}
+	}
+
+	if !ok {
+		client.mutexConfig.RLock()
+		filter := client.eventFilter
+		client.mutexConfig.RUnlock()
+
+		if filter == nil || filter(ev) {
+			client.Events <- ev
+			atomic.StoreInt64(&client.eventLag, int64(time.Since(ev.Received)))
+		}
+		return
+	}
+
+	select {
+	case listener.events <- ev:
+	case <-listener.done:
+	}
+}
+
+// listen register a listener for the events tagged with actionID
+func (client *AMIClient) listen(actionID string) *eventListener {
+	listener := &eventListener{
+		events: make(chan *AMIEvent, 100),
+		done:   make(chan struct{}),
+	}
+
+	client.mutexListeners.Lock()
+	client.listeners[actionID] = listener
+	client.mutexListeners.Unlock()
+
+	return listener
+}
+
+// unlisten remove the listener for actionID, pending events are discarded
+func (client *AMIClient) unlisten(actionID string) {
+	client.mutexListeners.Lock()
+	if listener, ok := client.listeners[actionID]; ok {
+		close(listener.done)
+		delete(client.listeners, actionID)
+	}
+	client.mutexListeners.Unlock()
+}
+
+// watch register a watcher for every event read
+func (client *AMIClient) watch() *eventListener {
+	watcher := &eventListener{
+		events: make(chan *AMIEvent, 100),
+		done:   make(chan struct{}),
+	}
+
+	client.mutexListeners.Lock()
+	watchers := make([]*eventListener, len(client.watchers), len(client.watchers)+1)
+	copy(watchers, client.watchers)
+	client.watchers = append(watchers, watcher)
+	client.mutexListeners.Unlock()
+
+	return watcher
+}
+
+// unwatch remove the watcher, pending events are discarded
+func (client *AMIClient) unwatch(watcher *eventListener) {
+	client.mutexListeners.Lock()
+	for i, w := range client.watchers {
+		if w != watcher {
+			continue
+		}
+		close(watcher.done)
+		delete(client.syncers, watcher)
+		watchers := make([]*eventListener, 0, len(client.watchers)-1)
+		watchers = append(watchers, client.watchers[:i]...)
+		client.watchers = append(watchers, client.watchers[i+1:]...)
+		break
+	}
+	client.mutexListeners.Unlock()
+}
+
+// syncAction send the action and wait for its response, a response with
+// status Error is returned as error, ErrModuleMissing when the server
+// doesn't have the action
+func (client *AMIClient) syncAction(p Params) (*AMIResponse, error) {
+	if err := client.checkCapability(p["Action"]); err != nil {
+		return nil, err
+	}
+
+	response, _, err := client.Action(p)
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Flush(); err != nil {
+		return nil, err
+	}
+
+	var resp *AMIResponse
+	select {
+	case resp = <-response:
+	case <-client.closed:
+		return nil, errClientClosed
+	}
+	if err := resp.Err(); err != nil {
+		if unknownCommand(err) {
+			return nil, ErrModuleMissing
+		}
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+// listAction send an action whose result is a list of events closed by the
+// event complete, and return the events of the list, the closing event is
+// the last one. It fails with errListTimeout when the list isn't completed
+// within listTimeout, and with errConnectionLost when the connection is
+// lost meanwhile
+func (client *AMIClient) listAction(p Params, complete string) ([]*AMIEvent, error) {
+	if p == nil {
+		return nil, errInvalidParams
+	}
+
+	actionID := client.newActionID()
+	p["ActionID"] = actionID
+
+	listener := client.listen(actionID)
+	defer client.unlisten(actionID)
+
+	if _, err := client.syncAction(p); err != nil {
+		return nil, err
+	}
+
+	timer := time.NewTimer(listTimeout)
+	defer timer.Stop()
+
+	var events []*AMIEvent
+	for {
+		select {
+		case ev := <-listener.events:
+			events = append(events, ev)
+			if ev.ID == complete {
+				return events, nil
+			}
+		case <-listener.done:
+			return nil, errConnectionLost
+		case <-timer.C:
+			return nil, errListTimeout
+		case <-client.closed:
+			return nil, errClientClosed
+		}
+	}
 }
 
 //newResponse build a response for action
@@ -227,14 +632,24 @@ func newResponse(data *textproto.MIMEHeader) (*AMIResponse, error) {
 		return nil, errors.New("Not Response")
 	}
 
-	response := &AMIResponse{data.Get("Actionid"),
-		data.Get("Response"),
-		make(map[string]string)}
+	response := &AMIResponse{ID: data.Get("Actionid"),
+		Status: data.Get("Response"),
+		Params: make(map[string]string, len(*data))}
 
 	for k, v := range *data {
 		if k == "Response" {
 			continue
 		}
+		if k == "Output" {
+			response.Params[k] = strings.Join(v, "\n")
+			continue
+		}
+		if len(v) > 1 {
+			if response.Repeated == nil {
+				response.Repeated = make(map[string][]string)
+			}
+			response.Repeated[k] = v
+		}
 		response.Params[k] = v[0]
 	}
 	return response, nil
@@ -245,34 +660,190 @@ func newEvent(data *textproto.MIMEHeader) (*AMIEvent, error) {
 	if data.Get("Event") == "" {
 		return nil, errNoEvent
 	}
-	ev := &AMIEvent{data.Get("Event"),
-		strings.Split(data.Get("Privilege"), ","),
-		make(map[string]string)}
+	ev := &AMIEvent{ID: data.Get("Event"),
+		Privilege: strings.Split(data.Get("Privilege"), ","),
+		Params:    make(map[string]string, len(*data))}
 
 	for k, v := range *data {
 		if k == "Event" || k == "Privilege" {
 			continue
 		}
+		if channel, ok := chanVariableChannel(k, data); ok {
+			for _, variable := range v {
+				ev.setChanVariable(channel, variable)
+			}
+			continue
+		}
+		// Status with Variables lists them as Variable: NAME=value
+		if k == "Variable" && ev.ID == "Status" {
+			for _, variable := range v {
+				ev.setChanVariable(data.Get("Channel"), variable)
+			}
+		}
 		ev.Params[k] = v[0]
 	}
 	return ev, nil
 }
 
+// chanVariableChannel check if key is a ChanVariable header and return the
+// channel it belongs, given as ChanVariable(channel) or by the Channel
+// header with the same prefix (ChanVariable, DestChanVariable...)
+func chanVariableChannel(key string, data *textproto.MIMEHeader) (string, bool) {
+	const name = "chanvariable"
+	if len(key) > len(name)+1 && strings.EqualFold(key[:len(name)+1], name+"(") && key[len(key)-1] == ')' {
+		return key[len(name)+1 : len(key)-1], true
+	}
+	if len(key) >= len(name) && strings.EqualFold(key[len(key)-len(name):], name) {
+		prefix := key[:len(key)-len(name)]
+		return data.Get(prefix + "Channel"), true
+	}
+	return "", false
+}
+
+// setChanVariable add the variable NAME=value of channel
+func (ev *AMIEvent) setChanVariable(channel, variable string) {
+	if ev.ChanVariables == nil {
+		ev.ChanVariables = make(map[string]map[string]string)
+	}
+	if ev.ChanVariables[channel] == nil {
+		ev.ChanVariables[channel] = make(map[string]string)
+	}
+
+	kv := strings.SplitN(variable, "=", 2)
+	if len(kv) == 1 {
+		ev.ChanVariables[channel][kv[0]] = ""
+		return
+	}
+	ev.ChanVariables[channel][kv[0]] = kv[1]
+}
+
+// ReadFrame read the lines of a frame until an empty line, keys are
+// canonicalized like textproto.ReadMIMEHeader but the lines it rejects as
+// malformed, like ChanVariable(channel), are kept. The raw output of a
+// "Response: Follows" is read until --END COMMAND-- as Output lines.
+func ReadFrame(r *textproto.Reader) (textproto.MIMEHeader, error) {
+	frame := make(textproto.MIMEHeader)
+	// values backing the first value of each key, saving an allocation per header
+	var values []string
+	for lines := 0; lines < maxFrameLines; lines++ {
+		line, err := r.ReadLine()
+		if err != nil {
+			return frame, err
+		}
+		if line == "" {
+			return frame, nil
+		}
+
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key := canonicalKey(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+		if vv, ok := frame[key]; ok {
+			frame[key] = append(vv, value)
+		} else {
+			if len(values) == cap(values) {
+				values = make([]string, 0, 8)
+			}
+			values = append(values, value)
+			frame[key] = values[len(values)-1 : len(values) : len(values)]
+		}
+
+		if key == "Response" && strings.EqualFold(frame[key][0], "Follows") && len(frame) == 1 {
+			if err := readFollows(r, frame); err != nil {
+				return frame, err
+			}
+		}
+	}
+	return frame, errFrameTooLong
+}
+
+// commonKeys canonical form of the keys found in most frames, looked up
+// before canonicalizing to save allocations
+var commonKeys = make(map[string]string)
+
+func init() {
+	keys := []string{"Event", "Privilege", "Response", "ActionID", "Message", "EventList", "ListItems",
+		"Channel", "ChannelState", "ChannelStateDesc", "CallerIDNum", "CallerIDName",
+		"ConnectedLineNum", "ConnectedLineName", "Language", "AccountCode", "Context", "Exten",
+		"Priority", "Uniqueid", "Linkedid", "Application", "AppData", "Variable", "Value",
+		"Cause", "Cause-txt", "BridgeUniqueid", "BridgeType", "BridgeTechnology", "BridgeNumChannels",
+		"DestChannel", "DestUniqueid", "DestLinkedid", "Queue", "Interface", "MemberName", "Status",
+		"Peer", "PeerStatus", "ChannelType", "Address", "SystemName", "Timestamp"}
+	for _, key := range keys {
+		canonical := textproto.CanonicalMIMEHeaderKey(key)
+		commonKeys[key] = canonical
+		commonKeys[canonical] = canonical
+	}
+}
+
+// canonicalKey return the canonical form of key like textproto
+func canonicalKey(key string) string {
+	if canonical, ok := commonKeys[key]; ok {
+		return canonical
+	}
+	return textproto.CanonicalMIMEHeaderKey(key)
+}
+
+// readFollows read the headers and the output of a "Response: Follows"
+// until --END COMMAND--, the output lines can have any content
+func readFollows(r *textproto.Reader, frame textproto.MIMEHeader) error {
+	headers := true
+	for lines := 0; lines < maxFrameLines; lines++ {
+		line, err := r.ReadLine()
+		if err != nil {
+			return err
+		}
+
+		if headers {
+			if i := strings.Index(line, ":"); i > 0 {
+				key := canonicalKey(strings.TrimSpace(line[:i]))
+				if key == "Privilege" || key == "Actionid" {
+					frame[key] = append(frame[key], strings.TrimSpace(line[i+1:]))
+					continue
+				}
+			}
+			headers = false
+		}
+
+		if strings.HasSuffix(line, "--END COMMAND--") {
+			if output := strings.TrimSuffix(line, "--END COMMAND--"); output != "" {
+				frame["Output"] = append(frame["Output"], output)
+			}
+			return nil
+		}
+		frame["Output"] = append(frame["Output"], line)
+	}
+	return errFrameTooLong
+}
+
 // Dial create a new connection to AMI
 func Dial(address string, options ...func(*AMIClient)) (*AMIClient, error) {
 	client := &AMIClient{
-		address:           address,
-		amiUser:           "",
-		amiPass:           "",
-		mutexAsyncAction:  new(sync.RWMutex),
-		waitNewConnection: make(chan struct{}),
-		response:          make(map[string]chan *AMIResponse),
-		Events:            make(chan *AMIEvent, 100),
-		Error:             make(chan error, 1),
-		NetError:          make(chan error, 1),
-		useTLS:            false,
-		unsecureTLS:       false,
-		tlsConfig:         new(tls.Config),
+		address:          address,
+		mutexAsyncAction: new(sync.RWMutex),
+		mutexListeners:   new(sync.RWMutex),
+		mutexRouter:      new(sync.RWMutex),
+		connChanged:      make(chan struct{}),
+		reconnects:       make(chan reconnectRequest),
+		response:         newPendingMap(),
+		listeners:        make(map[string]*eventListener),
+		contexts:         make(map[string]context.Context),
+		syncers:          make(map[*eventListener]func() error),
+		Events:           make(chan *AMIEvent, 100),
+		Error:            make(chan error, 1),
+		NetError:         make(chan error, 1),
+		Malformed:        make(chan *MalformedFrame, 10),
+		useTLS:           false,
+		unsecureTLS:      false,
+		tlsConfig:        new(tls.Config),
+		closed:           make(chan struct{}),
+		closeOnce:        new(sync.Once),
+		mutexConfig:      new(sync.RWMutex),
+		reconfigured:     make(chan struct{}),
+		bannerTimeout:    defaultBannerTimeout,
+		bannerCheck:      isAsteriskBanner,
 	}
 	for _, op := range options {
 		op(client)
@@ -281,32 +852,41 @@ func Dial(address string, options ...func(*AMIClient)) (*AMIClient, error) {
 	if err != nil {
 		return nil, err
 	}
+	go client.ownConnection()
+	go client.every(client.autoFlushInterval, func(time.Duration) {
+		if err := client.Flush(); err != nil {
+			client.logf("gami: flush: %s", err)
+		}
+	})
+	go client.every(func() time.Duration { return client.keepAlive }, client.ping)
 	return client, nil
 }
 
-// NewConn create a new connection to AMI
+// NewConn create a new connection to AMI replacing the current one, use
+// Reconnect on a running client to log in again
 func (client *AMIClient) NewConn() (err error) {
+	client.mutexConfig.RLock()
+	defer client.mutexConfig.RUnlock()
+
+	var connRaw io.ReadWriteCloser
 	if client.useTLS {
 		client.tlsConfig.InsecureSkipVerify = client.unsecureTLS
-		client.connRaw, err = tls.Dial("tcp", client.address, client.tlsConfig)
+		connRaw, err = tls.Dial("tcp", client.address, client.tlsConfig)
 	} else {
-		client.connRaw, err = net.Dial("tcp", client.address)
+		connRaw, err = net.Dial("tcp", client.address)
 	}
 
 	if err != nil {
 		return err
 	}
 
-	client.conn = textproto.NewConn(client.connRaw)
-	label, err := client.conn.ReadLine()
-	if err != nil {
+	conn := textproto.NewConn(connRaw)
+	if err := client.readBanner(connRaw, conn); err != nil {
+		connRaw.Close()
 		return err
 	}
 
-	if strings.Contains(label, "Asterisk Call Manager") != true {
-		return errNoAMI
-	}
-
+	client.setConnection(connRaw, conn)
 	return nil
 }
 
@@ -318,8 +898,22 @@ func (client *AMIClient) normaliser(p *Params) {
 	}
 
 	if _, ok := fixp["Actionid"]; !ok {
-		fixp["Actionid"] = fmt.Sprintf("%d", time.Now().UnixNano())
+		fixp["Actionid"] = client.newActionID()
 	}
 
 	*p = fixp
 }
+
+// newActionID generate an identifier for an action
+func newActionID() string {
+	return fmt.Sprintf("%d", time.Now().UnixNano())
+}
+
+// newActionID generate an identifier for an action with the prefix of the
+// client
+func (client *AMIClient) newActionID() string {
+	client.mutexConfig.RLock()
+	prefix := client.actionIDPrefix
+	client.mutexConfig.RUnlock()
+	return prefix + newActionID()
+}
@@ -5,11 +5,13 @@
 package gami
 
 import (
+	"bufio"
 	"bytes"
 	"fmt"
 	"math/rand"
 	"net"
 	"net/textproto"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -17,11 +19,21 @@ import (
 
 type amiMockAction func(params textproto.MIMEHeader) map[string]string
 
+// amiMockList returns the frames (response and events) of a list action
+type amiMockList func(params textproto.MIMEHeader) []map[string]string
+
 //amiServer for mocking Asterisk AMI
 type amiServer struct {
 	Addr          string
 	actionsMocked map[string]amiMockAction
+	listsMocked   map[string]amiMockList
 	listener      net.Listener
+	// conns accepted, closed on Drop
+	conns      []net.Conn
+	connsMutex *sync.Mutex
+	// maxDelay of the responses in milliseconds, a random delay up to it
+	// is applied to each response
+	maxDelay int
 }
 
 func TestLogin(t *testing.T) {
@@ -118,15 +130,34 @@ func defaultInstaller(t *testing.T, ami *AMIClient, timeout int) <-chan struct{}
 	return wait
 }
 
+// newTestClient start a mocked AMI server and a client running against it
+func newTestClient(t *testing.T) (*amiServer, *AMIClient) {
+	srv := newAmiServer()
+	ami, err := Dial(srv.Addr)
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	go ami.Run()
+	return srv, ami
+}
+
 func newAmiServer() *amiServer {
-	addr := "localhost:5038"
+	return newAmiServerAt("localhost:5038")
+}
+
+// newAmiServerAt start a mock listening on addr, localhost:0 for any port
+func newAmiServerAt(addr string) *amiServer {
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		panic(err)
 	}
 	srv := &amiServer{Addr: listener.Addr().String(),
 		listener:      listener,
-		actionsMocked: make(map[string]amiMockAction)}
+		actionsMocked: make(map[string]amiMockAction),
+		listsMocked:   make(map[string]amiMockList),
+		connsMutex:    new(sync.Mutex),
+		maxDelay:      1000}
 	go srv.do(listener)
 	return srv
 }
@@ -136,25 +167,37 @@ func (c *amiServer) Mock(action string, cb amiMockAction) {
 	c.actionsMocked[action] = cb
 }
 
+//MockList mock an action answered with several frames
+func (c *amiServer) MockList(action string, cb amiMockList) {
+	c.listsMocked[action] = cb
+}
+
 func (c *amiServer) do(listener net.Listener) {
 	for {
 		conn, err := listener.Accept()
 		if err != nil {
 			return
 		}
+		c.connsMutex.Lock()
+		c.conns = append(c.conns, conn)
+		c.connsMutex.Unlock()
 		fmt.Fprintf(conn, "Asterisk Call Manager\r\n")
 		tconn := textproto.NewConn(conn)
+		mutex := &sync.Mutex{}
 		//install event HeartBeat
 		go func(conn *textproto.Conn) {
 			for now := range time.Tick(time.Second) {
-				fmt.Fprintf(conn.W, "Event: HeartBeat\r\nTime: %d\r\n\r\n",
-					now.Unix())
+				mutex.Lock()
+				err := conn.PrintfLine("Event: HeartBeat\r\nTime: %d\r\n", now.Unix())
+				mutex.Unlock()
+				if err != nil {
+					return
+				}
 			}
 		}(tconn)
 
 		go func(conn *textproto.Conn) {
 			defer conn.Close()
-			mutex := &sync.Mutex{}
 			for {
 				header, err := conn.ReadMIMEHeader()
 				if err != nil {
@@ -162,32 +205,35 @@ func (c *amiServer) do(listener net.Listener) {
 				}
 				var output bytes.Buffer
 
-				time.AfterFunc(time.Millisecond*time.Duration(rand.Intn(1000)), func() {
+				delay := 0
+				if c.maxDelay > 0 {
+					delay = rand.Intn(c.maxDelay)
+				}
+				time.AfterFunc(time.Millisecond*time.Duration(delay), func() {
 
-					if _, ok := c.actionsMocked[header.Get("Action")]; ok {
+					if _, ok := c.listsMocked[header.Get("Action")]; ok {
+						for _, frame := range c.listsMocked[header.Get("Action")](header) {
+							for k, vals := range frame {
+								fmt.Fprintf(&output, "%s: %s\r\n", k, vals)
+							}
+							output.WriteString("\r\n")
+						}
+					} else if _, ok := c.actionsMocked[header.Get("Action")]; ok {
 						rvals := c.actionsMocked[header.Get("Action")](header)
 						for k, vals := range rvals {
 							fmt.Fprintf(&output, "%s: %s\r\n", k, vals)
 						}
 						output.WriteString("\r\n")
-
-						mutex.Lock()
-						err := conn.PrintfLine(output.String())
-						mutex.Unlock()
-						if err != nil {
-							panic(err)
-						}
 					} else {
 						//default response
 						fmt.Fprintf(&output, "Response: TEST\r\nActionID: %s\r\n\r\n",
 							header.Get("Actionid"))
-						mutex.Lock()
-						err := conn.PrintfLine(output.String())
-						mutex.Unlock()
-						if err != nil {
-							panic(err)
-						}
 					}
+
+					//the client could close the connection before the response
+					mutex.Lock()
+					conn.PrintfLine("%s", output.String())
+					mutex.Unlock()
 				})
 
 			}
@@ -198,3 +244,102 @@ func (c *amiServer) do(listener net.Listener) {
 func (c *amiServer) Close() {
 	c.listener.Close()
 }
+
+// Drop close the connections accepted, as a restart of Asterisk
+func (c *amiServer) Drop() {
+	c.connsMutex.Lock()
+	defer c.connsMutex.Unlock()
+	for _, conn := range c.conns {
+		conn.Close()
+	}
+	c.conns = nil
+}
+
+func TestReadFrame(t *testing.T) {
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(
+		"Event: Newchannel\r\nChannel: SIP/100-01\r\nChanVariable: FOO=bar\r\nChanVariable: BAZ=a=b\r\n" +
+			"ChanVariable(SIP/200-02): X=y\r\nDestChannel: SIP/300-03\r\nDestChanVariable: DEST=1\r\n\r\n" +
+			"Response: Success\r\n\r\n")))
+
+	data, err := ReadFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ev, err := newEvent(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ev.Params["Channel"] != "SIP/100-01" {
+		t.Fatal("unexpected Channel", ev.Params["Channel"])
+	}
+	if _, ok := ev.Params["Chanvariable"]; ok {
+		t.Fatal("ChanVariable kept as param")
+	}
+
+	expected := map[string]map[string]string{
+		"SIP/100-01": {"FOO": "bar", "BAZ": "a=b"},
+		"SIP/200-02": {"X": "y"},
+		"SIP/300-03": {"DEST": "1"},
+	}
+	for channel, variables := range expected {
+		for k, v := range variables {
+			if ev.ChanVariables[channel][k] != v {
+				t.Fatalf("expected %s=%s on %s, got %v", k, v, channel, ev.ChanVariables[channel])
+			}
+		}
+	}
+
+	data, err = ReadFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Get("Response") != "Success" {
+		t.Fatal("next frame not read", data)
+	}
+}
+
+func TestReadFrameFollows(t *testing.T) {
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(
+		"Response: Follows\r\nPrivilege: Command\r\nActionID: 42\r\n" +
+			"Uptime: 1 day\r\n\r\nlast line--END COMMAND--\r\n\r\n" +
+			"Response: Success\r\nActionID: 43\r\nOutput: Uptime: 1 day\r\nOutput: last line\r\n\r\n")))
+
+	for _, actionID := range []string{"42", "43"} {
+		data, err := ReadFrame(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := newResponse(&data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.ID != actionID {
+			t.Fatal("unexpected ActionID", resp.ID)
+		}
+		if _, ok := resp.Params["Uptime"]; ok {
+			t.Fatal("output parsed as header")
+		}
+		output := "Uptime: 1 day\n\nlast line"
+		if actionID == "43" {
+			output = "Uptime: 1 day\nlast line"
+		}
+		if resp.Params["Output"] != output {
+			t.Fatalf("unexpected output %q", resp.Params["Output"])
+		}
+	}
+}
+
+func TestReadFrameTooLong(t *testing.T) {
+	unterminated := "Response: Follows\r\n" + strings.Repeat("output\r\n", maxFrameLines+1)
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(unterminated)))
+	if _, err := ReadFrame(r); err != errFrameTooLong {
+		t.Fatal("expected errFrameTooLong, got", err)
+	}
+
+	endless := strings.Repeat("Key: value\r\n", maxFrameLines+1)
+	r = textproto.NewReader(bufio.NewReader(strings.NewReader(endless)))
+	if _, err := ReadFrame(r); err != errFrameTooLong {
+		t.Fatal("expected errFrameTooLong, got", err)
+	}
+}
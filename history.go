@@ -0,0 +1,117 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"sync"
+	"time"
+)
+
+// EventHistory keep in memory the events read in the last window, to be
+// queried with EventsSince, 0 disable it dropping the events kept
+func EventHistory(window time.Duration) func(*AMIClient) {
+	return func(c *AMIClient) {
+		if window <= 0 {
+			c.history = nil
+			return
+		}
+		if c.history == nil {
+			c.history = newEventHistory(window)
+			return
+		}
+		c.history.setWindow(window)
+	}
+}
+
+// eventHistory a ring buffer of the events of the last window, growing
+// as needed, ordered as read
+type eventHistory struct {
+	mutex  *sync.Mutex
+	window time.Duration
+	events []*AMIEvent
+	// head index of the oldest event
+	head int
+	size int
+}
+
+func newEventHistory(window time.Duration) *eventHistory {
+	return &eventHistory{
+		mutex:  new(sync.Mutex),
+		window: window,
+		events: make([]*AMIEvent, 64),
+	}
+}
+
+func (h *eventHistory) setWindow(window time.Duration) {
+	h.mutex.Lock()
+	h.window = window
+	h.mutex.Unlock()
+}
+
+// add the event dropping the ones out of the window
+func (h *eventHistory) add(ev *AMIEvent) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.expire(ev.Received.Add(-h.window))
+	if h.size == len(h.events) {
+		grown := make([]*AMIEvent, len(h.events)*2)
+		for i := 0; i < h.size; i++ {
+			grown[i] = h.at(i)
+		}
+		h.events, h.head = grown, 0
+	}
+	h.events[(h.head+h.size)%len(h.events)] = ev
+	h.size++
+}
+
+// expire drop the events received before limit
+func (h *eventHistory) expire(limit time.Time) {
+	for h.size > 0 && h.at(0).Received.Before(limit) {
+		h.events[h.head] = nil
+		h.head = (h.head + 1) % len(h.events)
+		h.size--
+	}
+}
+
+// at the i-th event from the oldest
+func (h *eventHistory) at(i int) *AMIEvent {
+	return h.events[(h.head+i)%len(h.events)]
+}
+
+// since the events received from t accepted by filter, oldest first
+func (h *eventHistory) since(t time.Time, filter func(*AMIEvent) bool) []*AMIEvent {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.expire(time.Now().Add(-h.window))
+	first := h.size
+	for first > 0 && !h.at(first-1).Received.Before(t) {
+		first--
+	}
+
+	var events []*AMIEvent
+	for i := first; i < h.size; i++ {
+		if ev := h.at(i); filter == nil || filter(ev) {
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+// EventsSince return the events read from t accepted by filter, nil
+// accept all, oldest first. Only the events of the window given to
+// EventHistory are kept, nil is returned without it. The events are
+// shared with Events and must not be modified
+func (client *AMIClient) EventsSince(t time.Time, filter func(ev *AMIEvent) bool) []*AMIEvent {
+	client.mutexConfig.RLock()
+	history := client.history
+	client.mutexConfig.RUnlock()
+
+	if history == nil {
+		return nil
+	}
+	return history.since(t, filter)
+}
@@ -0,0 +1,90 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestEventsSince(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	if events := ami.EventsSince(time.Time{}, nil); events != nil {
+		t.Fatal("events kept without EventHistory", events)
+	}
+	ami.Configure(EventHistory(time.Minute))
+
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "Newstate", "Channel": "SIP/100-01", "ChannelStateDesc": "Ringing"},
+			{"Event": "Newstate", "Channel": "SIP/200-02", "ChannelStateDesc": "Ringing"},
+			{"Event": "Hangup", "Channel": "SIP/100-01"},
+		}
+	})
+
+	start := time.Now()
+	rs, _, err := ami.Action(Params{"Action": "UserEvent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-rs
+	for i := 0; i < 3; i++ {
+		select {
+		case <-ami.Events:
+		case <-time.After(time.Second * 2):
+			t.Fatal("events not received")
+		}
+	}
+
+	events := ami.EventsSince(time.Now().Add(-30*time.Second), func(ev *AMIEvent) bool {
+		return ev.Get("Channel") == "SIP/100-01"
+	})
+	if len(events) != 2 || events[0].ID != "Newstate" || events[1].ID != "Hangup" {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	for _, ev := range ami.EventsSince(start, nil) {
+		if ev.Received.Before(start) {
+			t.Fatal("event before the start", ev)
+		}
+	}
+	if events := ami.EventsSince(time.Now().Add(time.Second), nil); len(events) != 0 {
+		t.Fatal("events from the future", events)
+	}
+}
+
+func TestEventHistoryWindow(t *testing.T) {
+	history := newEventHistory(time.Minute)
+	now := time.Now()
+	for i := 0; i < 200; i++ {
+		history.add(&AMIEvent{
+			ID:       "UserEvent",
+			Params:   map[string]string{"Seq": strconv.Itoa(i)},
+			Received: now.Add(time.Duration(i-200) * time.Second),
+		})
+	}
+
+	// only the events of the last minute are kept, in order
+	events := history.since(time.Time{}, nil)
+	if len(events) != 59 {
+		t.Fatalf("expected 59 events, got %d", len(events))
+	}
+	for i, ev := range events {
+		if ev.Params["Seq"] != strconv.Itoa(141+i) {
+			t.Fatalf("unexpected event %d: %v", i, ev.Params)
+		}
+	}
+
+	events = history.since(now.Add(-10*time.Second), nil)
+	if len(events) != 10 || events[0].Params["Seq"] != "190" {
+		t.Fatalf("unexpected events since 10s %+v", events)
+	}
+}
@@ -0,0 +1,17 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+// Package integration runs the helpers of gami end to end against real
+// releases of Asterisk started in Docker, a manager user and a small
+// dialplan are provisioned on each. The tests are behind the integration
+// build tag:
+//
+//	go test -tags integration ./integration
+//
+// GAMI_ASTERISK_VERSIONS selects the releases, 16,18,20 by default, and
+// GAMI_ASTERISK_IMAGE the image, a template where %s is the version,
+// andrius/asterisk:%s by default. The tests are skipped without docker,
+// unless GAMI_INTEGRATION_REQUIRED is set as the integration workflow of
+// .github/workflows does, running each release on its own job.
+package integration
@@ -0,0 +1,238 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/googolgl/gami"
+)
+
+const (
+	managerUser   = "gami"
+	managerSecret = "gami"
+	// bootTimeout time for a container to accept the manager user
+	bootTimeout = 90 * time.Second
+)
+
+// configFiles provisioned on /etc/asterisk, the rest are the ones of the
+// image
+var configFiles = map[string]string{
+	"manager.conf": `[general]
+enabled = yes
+port = 5038
+bindaddr = 0.0.0.0
+
+[` + managerUser + `]
+secret = ` + managerSecret + `
+read = all
+write = all
+`,
+	"extensions.conf": `[gami-test]
+exten => echo,1,Answer()
+ same => n,Set(GAMI=integration)
+ same => n,Wait(2)
+ same => n,Hangup()
+`,
+	"queues.conf": `[gami-sales]
+strategy = ringall
+`,
+}
+
+// asterisk a container running a release of Asterisk
+type asterisk struct {
+	Version   string
+	Container string
+	// Addr of the manager interface published on localhost
+	Addr string
+}
+
+// versions of Asterisk to test, from GAMI_ASTERISK_VERSIONS
+func versions() []string {
+	env := os.Getenv("GAMI_ASTERISK_VERSIONS")
+	if env == "" {
+		env = "16,18,20"
+	}
+	var versions []string
+	for _, version := range strings.Split(env, ",") {
+		if version = strings.TrimSpace(version); version != "" {
+			versions = append(versions, version)
+		}
+	}
+	return versions
+}
+
+// image of the version, from the template GAMI_ASTERISK_IMAGE
+func image(version string) string {
+	template := os.Getenv("GAMI_ASTERISK_IMAGE")
+	if template == "" {
+		template = "andrius/asterisk:%s"
+	}
+	return fmt.Sprintf(template, version)
+}
+
+func docker(args ...string) (string, error) {
+	out, err := exec.Command("docker", args...).CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("docker %s: %v: %s", args[0], err, out)
+	}
+	return string(out), nil
+}
+
+// startAsterisk run the version in a container removed when the test ends
+func startAsterisk(t *testing.T, version string) *asterisk {
+	t.Helper()
+	if _, err := exec.LookPath("docker"); err != nil {
+		if os.Getenv("GAMI_INTEGRATION_REQUIRED") != "" {
+			t.Fatal("docker not available")
+		}
+		t.Skip("docker not available")
+	}
+
+	dir := t.TempDir()
+	args := []string{"run", "-d", "--rm", "-p", "127.0.0.1::5038"}
+	for name, content := range configFiles {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		args = append(args, "-v", path+":/etc/asterisk/"+name+":ro")
+	}
+	args = append(args, image(version))
+
+	out, err := docker(args...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ast := &asterisk{Version: version, Container: strings.TrimSpace(out)}
+	t.Cleanup(func() {
+		if _, err := docker("rm", "-f", ast.Container); err != nil {
+			t.Log(err)
+		}
+	})
+
+	out, err = docker("port", ast.Container, "5038/tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// one line per address published, IPv4 first
+	ast.Addr = strings.TrimSpace(strings.SplitN(out, "\n", 2)[0])
+	return ast
+}
+
+// session a client logged in, the events it receives are kept
+type session struct {
+	*gami.AMIClient
+	// version of Asterisk
+	version string
+	mutex   *sync.Mutex
+	events  []*gami.AMIEvent
+	done    chan struct{}
+}
+
+// connect log in with the manager user, retrying until Asterisk is up, and
+// wait until it's fully booted
+func (ast *asterisk) connect(t *testing.T) *session {
+	t.Helper()
+	deadline := time.Now().Add(bootTimeout)
+	for {
+		s, err := ast.login()
+		if err == nil {
+			t.Cleanup(s.Close)
+			return s
+		}
+		if time.Now().After(deadline) {
+			logs, _ := docker("logs", "--tail", "50", ast.Container)
+			t.Fatalf("asterisk %s not ready: %v\n%s", ast.Version, err, logs)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func (ast *asterisk) login() (*session, error) {
+	client, err := gami.Dial(ast.Addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &session{AMIClient: client, version: ast.Version, mutex: new(sync.Mutex), done: make(chan struct{})}
+	client.Run()
+	go s.record()
+
+	if err := client.Login(managerUser, managerSecret); err != nil {
+		s.Close()
+		return nil, err
+	}
+	// the dialplan and the modules are loaded once fully booted
+	rs, _, err := client.Action(gami.Params{"Action": "Command", "Command": "core waitfullybooted"})
+	if err != nil {
+		s.Close()
+		return nil, err
+	}
+	select {
+	case <-rs:
+	case <-time.After(bootTimeout):
+		s.Close()
+		return nil, fmt.Errorf("asterisk %s: not fully booted", ast.Version)
+	}
+	return s, nil
+}
+
+// Close stop recording the events and close the client
+func (s *session) Close() {
+	close(s.done)
+	s.AMIClient.Close()
+}
+
+// record keep the events until the session is closed
+func (s *session) record() {
+	for {
+		select {
+		case ev := <-s.Events:
+			s.mutex.Lock()
+			s.events = append(s.events, ev)
+			s.mutex.Unlock()
+		case <-s.Error:
+		case <-s.NetError:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// seen the events received until now
+func (s *session) seen() []*gami.AMIEvent {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]*gami.AMIEvent(nil), s.events...)
+}
+
+// waitEvent wait for an event accepted by the filter expression, see
+// gami.ParseFilter
+func (s *session) waitEvent(t *testing.T, expr string, timeout time.Duration) *gami.AMIEvent {
+	t.Helper()
+	filter := gami.MustParseFilter(expr)
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		for _, ev := range s.seen() {
+			if filter.Match(ev) {
+				return ev
+			}
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("no event %q in %s", expr, timeout)
+	return nil
+}
@@ -0,0 +1,182 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+//go:build integration
+// +build integration
+
+package integration
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/googolgl/gami"
+	"github.com/googolgl/gami/event"
+)
+
+// echoCall originate a Local channel to the echo extension, the originated
+// side waits 3 seconds with the variable GAMI_ORIGINATE set
+var echoCall = gami.OriginateRequest{
+	Channel:     "Local/echo@gami-test",
+	Application: "Wait",
+	Data:        "3",
+	Timeout:     10 * time.Second,
+	Variables:   map[string]string{"GAMI_ORIGINATE": "yes"},
+}
+
+func TestAsterisk(t *testing.T) {
+	for _, version := range versions() {
+		version := version
+		t.Run(version, func(t *testing.T) {
+			s := startAsterisk(t, version).connect(t)
+
+			t.Run("Ping", func(t *testing.T) { testPing(t, s) })
+			t.Run("CoreStatus", func(t *testing.T) { testCoreStatus(t, s) })
+			t.Run("Capabilities", func(t *testing.T) { testCapabilities(t, s) })
+			t.Run("QueueStatus", func(t *testing.T) { testQueueStatus(t, s) })
+			t.Run("ShowDialplan", func(t *testing.T) { testShowDialplan(t, s) })
+			t.Run("Originate", func(t *testing.T) { testOriginate(t, s) })
+			// after Originate, its events are checked
+			t.Run("EventStructs", func(t *testing.T) { testEventStructs(t, s) })
+		})
+	}
+}
+
+func testPing(t *testing.T, s *session) {
+	ping, err := s.Ping()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ping.Timestamp.IsZero() {
+		t.Fatal("Ping without Timestamp")
+	}
+}
+
+func testCoreStatus(t *testing.T, s *session) {
+	status, err := s.CoreStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.StartupTime.IsZero() {
+		t.Fatal("CoreStatus without StartupTime")
+	}
+}
+
+func testCapabilities(t *testing.T, s *session) {
+	caps, err := s.DetectCapabilities()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, helper := range []string{"Originate", "QueueStatus", "TrackChannels", "ChannelVariables", "ShowDialplan"} {
+		if !caps.Usable(helper) {
+			t.Error("helper not usable", helper)
+		}
+	}
+}
+
+func testQueueStatus(t *testing.T, s *session) {
+	queues, err := s.QueueStatus("gami-sales")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queues) != 1 || queues[0].Name != "gami-sales" {
+		t.Fatalf("unexpected queues %+v", queues)
+	}
+	if queues[0].Strategy != "ringall" {
+		t.Fatal("unexpected Strategy", queues[0].Strategy)
+	}
+}
+
+func testShowDialplan(t *testing.T, s *session) {
+	dialplan, err := s.ShowDialplan("gami-test", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, context := range dialplan.Contexts {
+		if context.Name == "gami-test" {
+			return
+		}
+	}
+	t.Fatalf("context gami-test not shown %+v", dialplan.Contexts)
+}
+
+func testOriginate(t *testing.T, s *session) {
+	tracker := s.TrackChannels()
+	defer tracker.Stop()
+	if err := tracker.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := s.Originate(echoCall)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Success || result.Reason != 4 {
+		t.Fatalf("originate failed %+v", result)
+	}
+
+	tracked := false
+	for _, channel := range tracker.Channels() {
+		if channel.UniqueID == result.UniqueID {
+			tracked = true
+		}
+	}
+	if !tracked {
+		t.Error("originated channel not tracked", result.UniqueID)
+	}
+
+	snapshot, err := s.ChannelVariables(result.Channel, "GAMI_ORIGINATE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Values["GAMI_ORIGINATE"] != "yes" {
+		t.Errorf("unexpected variables %+v", snapshot)
+	}
+
+	s.waitEvent(t, "VarSet Variable=GAMI Value=integration", 5*time.Second)
+	s.waitEvent(t, "Hangup Uniqueid="+result.UniqueID, 10*time.Second)
+}
+
+// testEventStructs check the structs of package event against the events
+// received, a field never filled on any event of its type doesn't match
+// what this release emits
+func testEventStructs(t *testing.T, s *session) {
+	filled := make(map[string]map[string]bool)
+	for _, ev := range s.seen() {
+		typed := reflect.ValueOf(event.New(ev))
+		if typed.Type() == reflect.TypeOf(gami.AMIEvent{}) {
+			continue
+		}
+		fields, ok := filled[ev.ID]
+		if !ok {
+			fields = make(map[string]bool)
+			filled[ev.ID] = fields
+		}
+		for i := 0; i < typed.NumField(); i++ {
+			tag := typed.Type().Field(i).Tag.Get("AMI")
+			if tag == "" {
+				continue
+			}
+			if _, ok := ev.Params[tag]; ok {
+				fields[tag] = true
+			} else if !fields[tag] {
+				fields[tag] = false
+			}
+		}
+	}
+
+	for _, id := range []string{"Newchannel", "Newstate", "Newexten", "VarSet", "Hangup"} {
+		if _, ok := filled[id]; !ok {
+			t.Error("no event", id, "received")
+		}
+	}
+	for id, fields := range filled {
+		for tag, ok := range fields {
+			if !ok {
+				t.Errorf("%s.%s never sent by Asterisk %s", id, tag, s.version)
+			}
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"context"
+	"strings"
+)
+
+// Interceptor observe the actions sent by the client, their responses and
+// the events tagged with them, with the context of the action, as a tracer
+// or the logs of a multi-tenant app. The funcs are called on the loop
+// reading the socket and must not block, nil funcs are skipped
+type Interceptor struct {
+	// Action called before the action is sent, with its final params
+	Action func(ctx context.Context, p Params)
+	// Response called when the response of the action is delivered
+	Response func(ctx context.Context, response *AMIResponse)
+	// Event called for the events tagged with the ActionID of an action
+	// sent with ActionContext
+	Event func(ctx context.Context, ev *AMIEvent)
+}
+
+// UseInterceptors observe the actions with interceptors, replacing the
+// previous ones, none remove them
+func UseInterceptors(interceptors ...Interceptor) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.interceptors = interceptors
+	}
+}
+
+// ActionContext send the action as Action, carrying ctx, its values as a
+// tenant or a request ID are given back by AMIResponse.Context and by
+// AMIEvent.Context of the events tagged with its ActionID, the list the
+// response announces or the OriginateResponse of an Async Originate. Only
+// the values of ctx are used, the action is not cancelled with it
+func (client *AMIClient) ActionContext(ctx context.Context, p Params) (<-chan *AMIResponse, string, error) {
+	if ctx == nil {
+		return nil, "", errInvalidParams
+	}
+	return client.action(ctx, p)
+}
+
+// Context of the action given to ActionContext, Background otherwise
+func (response *AMIResponse) Context() context.Context {
+	return contextOf(response.ctx)
+}
+
+// Context of the action sent with ActionContext the event is tagged with,
+// Background otherwise
+func (ev *AMIEvent) Context() context.Context {
+	return contextOf(ev.ctx)
+}
+
+func contextOf(ctx context.Context) context.Context {
+	if ctx == nil {
+		return context.Background()
+	}
+	return ctx
+}
+
+// trackContext keep ctx for the events tagged with actionID
+func (client *AMIClient) trackContext(actionID string, ctx context.Context) {
+	client.mutexListeners.Lock()
+	client.contexts[actionID] = ctx
+	client.mutexListeners.Unlock()
+}
+
+// untrackContext forget the context of actionID, no more events expected
+func (client *AMIClient) untrackContext(actionID string) {
+	client.mutexListeners.Lock()
+	delete(client.contexts, actionID)
+	client.mutexListeners.Unlock()
+}
+
+// isAsyncOriginate check if the action is an Originate answered later by
+// an OriginateResponse
+func isAsyncOriginate(p Params) bool {
+	return strings.EqualFold(p["Action"], "Originate") && parseBool(p["Async"])
+}
+
+// interceptResponse pass the response to the interceptors
+func (client *AMIClient) interceptResponse(response *AMIResponse) {
+	client.mutexConfig.RLock()
+	interceptors := client.interceptors
+	client.mutexConfig.RUnlock()
+
+	for _, interceptor := range interceptors {
+		if interceptor.Response != nil {
+			interceptor.Response(response.Context(), response)
+		}
+	}
+}
+
+// interceptEvent pass the event of an action with context to the
+// interceptors, the context is forgotten with the last event expected
+func (client *AMIClient) interceptEvent(ev *AMIEvent) {
+	client.mutexConfig.RLock()
+	interceptors := client.interceptors
+	client.mutexConfig.RUnlock()
+
+	for _, interceptor := range interceptors {
+		if interceptor.Event != nil {
+			interceptor.Event(ev.ctx, ev)
+		}
+	}
+
+	if completesList(ev) || ev.ID == "OriginateResponse" {
+		client.untrackContext(ev.Params["Actionid"])
+	}
+}
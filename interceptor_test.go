@@ -0,0 +1,97 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"context"
+	"net/textproto"
+	"sync"
+	"testing"
+	"time"
+)
+
+type tenantKey struct{}
+
+func TestActionContext(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	srv.MockList("Status", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start", "Message": "Channel status will follow"},
+			{"Event": "Status", "ActionID": id, "Channel": "SIP/100-01"},
+			{"Event": "StatusComplete", "ActionID": id, "EventList": "Complete", "ListItems": "1"},
+		}
+	})
+
+	mutex := &sync.Mutex{}
+	seen := make(map[string]interface{})
+	record := func(kind string, ctx context.Context) {
+		mutex.Lock()
+		seen[kind] = ctx.Value(tenantKey{})
+		mutex.Unlock()
+	}
+	ami.Configure(UseInterceptors(Interceptor{
+		Action:   func(ctx context.Context, p Params) { record("action "+p["Action"], ctx) },
+		Response: func(ctx context.Context, r *AMIResponse) { record("response "+r.ID, ctx) },
+		Event:    func(ctx context.Context, ev *AMIEvent) { record("event "+ev.ID, ctx) },
+	}))
+
+	if _, _, err := ami.ActionContext(nil, Params{"Action": "Ping"}); err != errInvalidParams {
+		t.Fatal("expected errInvalidParams, got", err)
+	}
+
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+	response, actionID, err := ami.ActionContext(ctx, Params{"Action": "Status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := <-response; r.Context().Value(tenantKey{}) != "acme" {
+		t.Fatal("context not given back on the response")
+	}
+
+	for _, id := range []string{"Status", "StatusComplete"} {
+		select {
+		case ev := <-ami.Events:
+			if ev.ID != id || ev.Context().Value(tenantKey{}) != "acme" {
+				t.Fatalf("unexpected %s with tenant %v", ev.ID, ev.Context().Value(tenantKey{}))
+			}
+		case <-time.After(time.Second * 2):
+			t.Fatal("event not received", id)
+		}
+	}
+
+	mutex.Lock()
+	for _, kind := range []string{"action Status", "response " + actionID, "event Status", "event StatusComplete"} {
+		if seen[kind] != "acme" {
+			t.Errorf("%s intercepted with %v", kind, seen[kind])
+		}
+	}
+	mutex.Unlock()
+
+	ami.mutexListeners.RLock()
+	left := len(ami.contexts)
+	ami.mutexListeners.RUnlock()
+	if left != 0 {
+		t.Fatal("context kept after the list completed")
+	}
+
+	// the actions without context are intercepted with Background
+	response, actionID, err = ami.Action(Params{"Action": "Ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := <-response; r.Context() != context.Background() {
+		t.Fatal("expected Background on Action")
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	if v, ok := seen["response "+actionID]; !ok || v != nil {
+		t.Fatal("response of Action not intercepted with Background")
+	}
+}
@@ -0,0 +1,30 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Age of the event since it was read from the socket, compared by a
+// consumer on receipt it tells how far behind the server it is
+func (ev *AMIEvent) Age() time.Duration {
+	if ev.Received.IsZero() {
+		return 0
+	}
+	return time.Since(ev.Received)
+}
+
+// EventLag return the time the last event sent on Events waited since it
+// was read, it grows when the consumer falls behind and Events is full
+func (client *AMIClient) EventLag() time.Duration {
+	return time.Duration(atomic.LoadInt64(&client.eventLag))
+}
+
+// EventBacklog return the events read and not yet taken from Events
+func (client *AMIClient) EventBacklog() int {
+	return len(client.Events)
+}
@@ -0,0 +1,51 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestEventLag(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		frames := []map[string]string{{"Response": "Success", "ActionID": params.Get("Actionid")}}
+		for i := 0; i < 150; i++ {
+			frames = append(frames, map[string]string{"Event": "UserEvent", "UserEvent": "burst"})
+		}
+		return frames
+	})
+
+	rs, _, err := ami.Action(Params{"Action": "UserEvent", "UserEvent": "burst"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-rs
+
+	// the consumer falls behind, Events fills up
+	time.Sleep(200 * time.Millisecond)
+	if backlog := ami.EventBacklog(); backlog != cap(ami.Events) {
+		t.Fatalf("expected full backlog, got %d", backlog)
+	}
+
+	ev := <-ami.Events
+	if ev.Received.IsZero() || ev.Age() < 200*time.Millisecond {
+		t.Fatalf("unexpected age %s of the event received at %s", ev.Age(), ev.Received)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for ami.EventLag() < 150*time.Millisecond {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected lag of the blocked event, got %s", ami.EventLag())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrSessionDown returned by Action after Asterisk announced its shutdown,
+// until Reconnect sets a new connection
+var ErrSessionDown = errors.New("Session Down")
+
+// shutdownMessage of the responses given to the actions failed by Shutdown
+const shutdownMessage = "Asterisk shutting down"
+
+// connectionLostMessage of the responses given to the actions failed when
+// the connection is lost or replaced
+const connectionLostMessage = "Connection lost"
+
+// OnShutdown call hook with the Shutdown event when Asterisk announces its
+// shutdown, once the session is marked down and the actions waiting for a
+// response failed. The hook is called on its own goroutine
+func OnShutdown(hook func(ev *AMIEvent)) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.onShutdown = hook
+	}
+}
+
+// OnFullyBooted call hook with the FullyBooted event Asterisk sends once
+// it's up. After a Reconnect the state of the trackers and feeds is synced
+// before the hook is called on its own goroutine
+func OnFullyBooted(hook func(ev *AMIEvent)) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.onFullyBooted = hook
+	}
+}
+
+// SessionDown check if Asterisk announced its shutdown on the connection
+func (client *AMIClient) SessionDown() bool {
+	return atomic.LoadInt32(&client.sessionDown) == 1
+}
+
+// syncOnBoot sync the state of the helper of watcher with sync when
+// Asterisk boots after a Reconnect, until the watcher is removed
+func (client *AMIClient) syncOnBoot(watcher *eventListener, sync func() error) {
+	client.mutexListeners.Lock()
+	client.syncers[watcher] = sync
+	client.mutexListeners.Unlock()
+}
+
+// lifecycle handle the events of the start and stop of Asterisk, called
+// by the reader before the event is dispatched
+func (client *AMIClient) lifecycle(ev *AMIEvent) {
+	switch ev.ID {
+	case "Shutdown":
+		atomic.StoreInt32(&client.sessionDown, 1)
+		client.failPending(shutdownMessage)
+
+		client.mutexConfig.RLock()
+		hook := client.onShutdown
+		client.mutexConfig.RUnlock()
+		if hook != nil {
+			go hook(ev)
+		}
+	case "FullyBooted":
+		_, generation, _ := client.connection()
+		resync := generation > 1 && generation != client.bootedGeneration
+		client.bootedGeneration = generation
+
+		client.mutexConfig.RLock()
+		hook := client.onFullyBooted
+		client.mutexConfig.RUnlock()
+
+		client.mutexListeners.RLock()
+		syncers := make([]func() error, 0, len(client.syncers))
+		if resync {
+			for _, sync := range client.syncers {
+				syncers = append(syncers, sync)
+			}
+		}
+		client.mutexListeners.RUnlock()
+
+		if hook == nil && len(syncers) == 0 {
+			return
+		}
+		// the syncs wait for their responses, read by the caller
+		go func() {
+			for _, sync := range syncers {
+				if err := sync(); err != nil {
+					client.logf("gami: sync after boot: %s", err)
+				}
+			}
+			if hook != nil {
+				hook(ev)
+			}
+		}()
+	}
+}
+
+// connectionLost fail the actions waiting for a response and end the
+// listeners of the helpers, nothing else arrives on the connection gone
+func (client *AMIClient) connectionLost() {
+	client.failPending(connectionLostMessage)
+
+	client.mutexListeners.Lock()
+	for _, listener := range client.listeners {
+		close(listener.done)
+	}
+	client.listeners = make(map[string]*eventListener)
+	client.mutexListeners.Unlock()
+}
+
+// failPending answer the actions waiting for a response with an Error
+// response carrying message
+func (client *AMIClient) failPending(message string) {
+	for actionID, pending := range client.response.takeAll() {
+		client.failAction(actionID, pending, message)
+	}
+}
+
+// failAction answer the action taken from the pending ones with an Error
+// response carrying message
+func (client *AMIClient) failAction(actionID string, pending *pendingAction, message string) {
+	pending.answered(client, actionID)
+	if pending.listener != nil {
+		client.unlisten(actionID)
+	}
+	if pending.ctx != nil {
+		client.untrackContext(actionID)
+	}
+
+	response := &AMIResponse{
+		ID:     actionID,
+		Status: "Error",
+		Params: map[string]string{"Response": "Error", "Actionid": actionID, "Message": message},
+		ctx:    pending.ctx,
+	}
+	client.interceptResponse(response)
+	select {
+	case pending.response <- response:
+	case <-client.closed:
+		return
+	}
+	close(pending.response)
+}
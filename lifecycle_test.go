@@ -0,0 +1,114 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShutdownAndFullyBooted(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	go func() {
+		for range ami.Events {
+		}
+	}()
+
+	shutdowns := make(chan *AMIEvent, 1)
+	boots := make(chan *AMIEvent, 2)
+	ami.Configure(
+		OnShutdown(func(ev *AMIEvent) { shutdowns <- ev }),
+		OnFullyBooted(func(ev *AMIEvent) { boots <- ev }),
+	)
+
+	// UserEvent makes the server send the event named by UserEvent
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": params.Get("Userevent"), "Restart": "True", "Status": "Fully Booted"},
+		}
+	})
+	srv.Mock("Wait", func(params textproto.MIMEHeader) map[string]string {
+		time.Sleep(time.Second)
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid")}
+	})
+	var synced int32
+	srv.MockList("CoreShowChannels", func(params textproto.MIMEHeader) []map[string]string {
+		atomic.AddInt32(&synced, 1)
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "CoreShowChannelsComplete", "ActionID": id, "EventList": "Complete"},
+		}
+	})
+	tracker := ami.TrackChannels()
+	defer tracker.Stop()
+
+	send := func(event string) {
+		rs, _, err := ami.Action(Params{"Action": "UserEvent", "UserEvent": event})
+		if err != nil {
+			t.Fatal(err)
+		}
+		<-rs
+	}
+	expect := func(events <-chan *AMIEvent, id string) {
+		select {
+		case ev := <-events:
+			if ev.ID != id {
+				t.Fatal("unexpected event", ev.ID)
+			}
+		case <-time.After(time.Second * 2):
+			t.Fatal("hook not called for", id)
+		}
+	}
+
+	// on the first connection the trackers are not synced
+	send("FullyBooted")
+	expect(boots, "FullyBooted")
+	if atomic.LoadInt32(&synced) != 0 {
+		t.Fatal("synced on the first boot")
+	}
+
+	waiting, _, err := ami.Action(Params{"Action": "Wait"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	send("Shutdown")
+	expect(shutdowns, "Shutdown")
+
+	select {
+	case response := <-waiting:
+		if response.Err() == nil || response.Params["Message"] != shutdownMessage {
+			t.Fatal("pending action not failed", response.Params)
+		}
+	case <-time.After(time.Millisecond * 500):
+		t.Fatal("pending action not failed on Shutdown")
+	}
+	if !ami.SessionDown() {
+		t.Fatal("session not marked down")
+	}
+	if _, _, err := ami.Action(Params{"Action": "Ping"}); err != ErrSessionDown {
+		t.Fatal("expected ErrSessionDown, got", err)
+	}
+
+	if err := ami.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if ami.SessionDown() {
+		t.Fatal("session down after Reconnect")
+	}
+
+	// after a reconnect the trackers are synced before the hook
+	send("FullyBooted")
+	expect(boots, "FullyBooted")
+	if atomic.LoadInt32(&synced) != 1 {
+		t.Fatal("trackers not synced after boot", atomic.LoadInt32(&synced))
+	}
+}
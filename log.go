@@ -0,0 +1,40 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"time"
+)
+
+// Logger receive the diagnostics of the client, *log.Logger satisfies it
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// UseLogger log the diagnostics of the client on logger
+func UseLogger(logger Logger) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.logger = logger
+	}
+}
+
+// SlowActionThreshold log the actions without response after threshold, and
+// the responses arrived after threshold, needs UseLogger
+func SlowActionThreshold(threshold time.Duration) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.slowAction = threshold
+	}
+}
+
+// logf log on the logger of the client if any
+func (client *AMIClient) logf(format string, v ...interface{}) {
+	client.mutexConfig.RLock()
+	logger := client.logger
+	client.mutexConfig.RUnlock()
+
+	if logger != nil {
+		logger.Printf(format, v...)
+	}
+}
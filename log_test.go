@@ -0,0 +1,108 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"fmt"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testLogger keep the lines logged
+type testLogger struct {
+	mutex sync.Mutex
+	lines []string
+}
+
+func (l *testLogger) Printf(format string, v ...interface{}) {
+	l.mutex.Lock()
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+	l.mutex.Unlock()
+}
+
+func (l *testLogger) contains(s string) bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for _, line := range l.lines {
+		if strings.Contains(line, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSlowActionLog(t *testing.T) {
+	srv := newAmiServer()
+	defer srv.Close()
+	logger := &testLogger{}
+	ami, err := Dial(srv.Addr, UseLogger(logger), SlowActionThreshold(time.Millisecond*10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	srv.Mock("Ping", func(params textproto.MIMEHeader) map[string]string {
+		time.Sleep(time.Millisecond * 100)
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid")}
+	})
+
+	response, actionID, err := ami.Action(Params{"Action": "Ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-response
+
+	if !logger.contains("action Ping without response") {
+		t.Fatal("watchdog not logged", logger.lines)
+	}
+	waitLogged(t, logger, "action Ping (ActionID "+actionID+") answered after")
+}
+
+func TestUnknownActionIDLog(t *testing.T) {
+	srv := newAmiServer()
+	defer srv.Close()
+	logger := &testLogger{}
+	ami, err := Dial(srv.Addr, UseLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	srv.MockList("Ping", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": "unknown"},
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+		}
+	})
+
+	response, _, err := ami.Action(Params{"Action": "Ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-response:
+	case <-time.After(time.Second * 5):
+		t.Fatal("response blocked by unknown ActionID")
+	}
+
+	waitLogged(t, logger, `unknown ActionID "unknown"`)
+}
+
+// waitLogged wait for a line containing s to be logged
+func waitLogged(t *testing.T, logger *testLogger, s string) {
+	deadline := time.Now().Add(time.Second)
+	for !logger.contains(s) {
+		if time.Now().After(deadline) {
+			t.Fatal("not logged:", s)
+		}
+		time.Sleep(time.Millisecond * 10)
+	}
+}
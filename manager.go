@@ -0,0 +1,442 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var errServerExists = errors.New("Server Exists")
+var errUnknownServer = errors.New("Unknown Server")
+var errManagerClosed = errors.New("Manager Closed")
+
+const (
+	// managerRetryMin first wait to connect again to a server
+	managerRetryMin = time.Second
+	// managerRetryMax longest wait to connect again to a server
+	managerRetryMax = time.Minute
+)
+
+// ServerConfig an Asterisk managed by a Manager
+type ServerConfig struct {
+	Address  string
+	Username string
+	Secret   string
+	// Options given to Dial
+	Options []func(*AMIClient)
+}
+
+// ServerHealth state of a server of a Manager
+type ServerHealth struct {
+	Name      string
+	Address   string
+	Connected bool
+	// Since when the server is connected or disconnected
+	Since time.Time
+	// LastError of the connection, nil when connected
+	LastError  error
+	Reconnects int
+	LastEvent  time.Time
+}
+
+// ServerEvent an event read from a server of a Manager
+type ServerEvent struct {
+	Server string
+	*AMIEvent
+}
+
+// Manager owns the clients of many servers by name, connecting them and
+// reconnecting them when the connection is lost
+type Manager struct {
+	mutex       *sync.RWMutex
+	servers     map[string]*managedServer
+	subscribers []*Subscription
+	closed      bool
+}
+
+// Subscription events of the servers of a Manager
+type Subscription struct {
+	// dropped events, first for the alignment of atomic
+	dropped uint64
+	// deadline to deliver an event in nanoseconds, 0 wait forever
+	deadline int64
+	// lagging set after a drop, the events are dropped without waiting
+	// until the subscriber reads again
+	lagging int32
+
+	manager *Manager
+	// servers subscribed, every server when nil
+	servers map[string]struct{}
+	events  chan ServerEvent
+	done    chan struct{}
+	once    *sync.Once
+}
+
+// managedServer a server supervised by the manager
+type managedServer struct {
+	name    string
+	config  ServerConfig
+	manager *Manager
+	mutex   *sync.RWMutex
+	client  *AMIClient
+	health  ServerHealth
+	done    chan struct{}
+}
+
+// NewManager create a manager without servers
+func NewManager() *Manager {
+	return &Manager{
+		mutex:   new(sync.RWMutex),
+		servers: make(map[string]*managedServer),
+	}
+}
+
+// Add the server name and start connecting to it
+func (m *Manager) Add(name string, config ServerConfig) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.closed {
+		return errManagerClosed
+	}
+	if _, ok := m.servers[name]; ok {
+		return errServerExists
+	}
+
+	server := &managedServer{
+		name:    name,
+		config:  config,
+		manager: m,
+		mutex:   new(sync.RWMutex),
+		health:  ServerHealth{Name: name, Address: config.Address, Since: time.Now()},
+		done:    make(chan struct{}),
+	}
+	m.servers[name] = server
+	go server.supervise()
+	return nil
+}
+
+// Remove the server name closing its client
+func (m *Manager) Remove(name string) error {
+	m.mutex.Lock()
+	server, ok := m.servers[name]
+	delete(m.servers, name)
+	m.mutex.Unlock()
+
+	if !ok {
+		return errUnknownServer
+	}
+	server.stop()
+	return nil
+}
+
+// Client return the client of the server name, nil until it's connected
+// for the first time, a new client is created on each reconnection
+func (m *Manager) Client(name string) *AMIClient {
+	m.mutex.RLock()
+	server, ok := m.servers[name]
+	m.mutex.RUnlock()
+	if !ok {
+		return nil
+	}
+
+	server.mutex.RLock()
+	defer server.mutex.RUnlock()
+	return server.client
+}
+
+// Servers return the names of the servers ordered
+func (m *Manager) Servers() []string {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	names := make([]string, 0, len(m.servers))
+	for name := range m.servers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Health return the state of the servers ordered by name
+func (m *Manager) Health() []ServerHealth {
+	m.mutex.RLock()
+	servers := make([]*managedServer, 0, len(m.servers))
+	for _, server := range m.servers {
+		servers = append(servers, server)
+	}
+	m.mutex.RUnlock()
+
+	health := make([]ServerHealth, 0, len(servers))
+	for _, server := range servers {
+		server.mutex.RLock()
+		health = append(health, server.health)
+		server.mutex.RUnlock()
+	}
+	sort.Slice(health, func(i, j int) bool {
+		return health[i].Name < health[j].Name
+	})
+	return health
+}
+
+// Subscribe to the events of the servers given, every server when none,
+// including the servers added later
+func (m *Manager) Subscribe(servers ...string) *Subscription {
+	sub := &Subscription{
+		manager: m,
+		events:  make(chan ServerEvent, 100),
+		done:    make(chan struct{}),
+		once:    new(sync.Once),
+	}
+	if len(servers) > 0 {
+		sub.servers = make(map[string]struct{}, len(servers))
+		for _, server := range servers {
+			sub.servers[server] = struct{}{}
+		}
+	}
+
+	m.mutex.Lock()
+	subscribers := make([]*Subscription, len(m.subscribers), len(m.subscribers)+1)
+	copy(subscribers, m.subscribers)
+	m.subscribers = append(subscribers, sub)
+	m.mutex.Unlock()
+
+	return sub
+}
+
+// Close remove every server and end the subscriptions
+func (m *Manager) Close() {
+	m.mutex.Lock()
+	m.closed = true
+	servers := m.servers
+	subscribers := m.subscribers
+	m.servers = make(map[string]*managedServer)
+	m.mutex.Unlock()
+
+	for _, server := range servers {
+		server.stop()
+	}
+	for _, sub := range subscribers {
+		sub.Unsubscribe()
+	}
+}
+
+// publish deliver the event of server to its subscribers
+func (m *Manager) publish(server string, ev *AMIEvent) {
+	m.mutex.RLock()
+	subscribers := m.subscribers
+	m.mutex.RUnlock()
+
+	for _, sub := range subscribers {
+		if !sub.wants(server) {
+			continue
+		}
+		sub.deliver(ServerEvent{Server: server, AMIEvent: ev})
+	}
+}
+
+// deliver the event waiting up to the deadline of the subscription, a
+// lagging subscriber is not waited for
+func (sub *Subscription) deliver(ev ServerEvent) {
+	deadline := time.Duration(atomic.LoadInt64(&sub.deadline))
+	if deadline <= 0 {
+		select {
+		case sub.events <- ev:
+		case <-sub.done:
+		}
+		return
+	}
+
+	select {
+	case sub.events <- ev:
+		atomic.StoreInt32(&sub.lagging, 0)
+		return
+	case <-sub.done:
+		return
+	default:
+	}
+	if atomic.LoadInt32(&sub.lagging) == 1 {
+		atomic.AddUint64(&sub.dropped, 1)
+		return
+	}
+
+	timer := time.NewTimer(deadline)
+	defer timer.Stop()
+	select {
+	case sub.events <- ev:
+	case <-sub.done:
+	case <-timer.C:
+		atomic.StoreInt32(&sub.lagging, 1)
+		atomic.AddUint64(&sub.dropped, 1)
+	}
+}
+
+// Events of the subscription
+func (sub *Subscription) Events() <-chan ServerEvent {
+	return sub.events
+}
+
+// SetDeadline drop the events the subscriber doesn't take within wait once
+// its buffer is full, so a stuck subscriber doesn't delay the others. After
+// a drop the events are dropped without waiting until it reads again, 0
+// wait forever, the default
+func (sub *Subscription) SetDeadline(wait time.Duration) {
+	atomic.StoreInt64(&sub.deadline, int64(wait))
+}
+
+// Dropped return the events dropped by the deadline
+func (sub *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&sub.dropped)
+}
+
+// Unsubscribe stop receiving events, pending events are discarded
+func (sub *Subscription) Unsubscribe() {
+	sub.once.Do(func() {
+		close(sub.done)
+
+		m := sub.manager
+		m.mutex.Lock()
+		for i, s := range m.subscribers {
+			if s != sub {
+				continue
+			}
+			subscribers := make([]*Subscription, 0, len(m.subscribers)-1)
+			subscribers = append(subscribers, m.subscribers[:i]...)
+			m.subscribers = append(subscribers, m.subscribers[i+1:]...)
+			break
+		}
+		m.mutex.Unlock()
+	})
+}
+
+func (sub *Subscription) wants(server string) bool {
+	if sub.servers == nil {
+		return true
+	}
+	_, ok := sub.servers[server]
+	return ok
+}
+
+// supervise connect to the server and keep it connected until stop
+func (server *managedServer) supervise() {
+	retry := managerRetryMin
+	for {
+		client, err := server.connect()
+		if err == nil {
+			retry = managerRetryMin
+			server.follow(client)
+			client.Close()
+		} else {
+			server.disconnected(err)
+		}
+
+		select {
+		case <-server.done:
+			return
+		case <-time.After(retry):
+		}
+		if retry *= 2; retry > managerRetryMax {
+			retry = managerRetryMax
+		}
+	}
+}
+
+// connect dial and log in to the server
+func (server *managedServer) connect() (*AMIClient, error) {
+	options := append([]func(*AMIClient){}, server.config.Options...)
+	if server.config.Username != "" {
+		options = append(options, UseAuthenticator(PlainAuth(StaticCredentials(server.config.Username, server.config.Secret))))
+	}
+
+	client, err := Dial(server.config.Address, options...)
+	if err != nil {
+		return nil, err
+	}
+	client.Run()
+
+	authenticated := make(chan error, 1)
+	go func() {
+		if client.authenticator == nil {
+			authenticated <- nil
+			return
+		}
+		authenticated <- client.Authenticate()
+	}()
+
+	for {
+		select {
+		case err := <-authenticated:
+			if err != nil {
+				client.Close()
+				return nil, err
+			}
+			server.connected(client)
+			return client, nil
+		case ev := <-client.Events:
+			server.manager.publish(server.name, ev)
+		case err := <-client.NetError:
+			client.Close()
+			return nil, err
+		case <-server.done:
+			client.Close()
+			return nil, errManagerClosed
+		}
+	}
+}
+
+// follow publish the events of client until the connection is lost or the
+// server is removed
+func (server *managedServer) follow(client *AMIClient) {
+	for {
+		select {
+		case ev := <-client.Events:
+			server.mutex.Lock()
+			server.health.LastEvent = time.Now()
+			server.mutex.Unlock()
+			server.manager.publish(server.name, ev)
+		case err := <-client.Error:
+			server.mutex.Lock()
+			server.health.LastError = err
+			server.mutex.Unlock()
+		case err := <-client.NetError:
+			server.disconnected(err)
+			return
+		case <-server.done:
+			return
+		}
+	}
+}
+
+func (server *managedServer) connected(client *AMIClient) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if server.client != nil {
+		server.health.Reconnects++
+	}
+	server.client = client
+	server.health.Connected = true
+	server.health.Since = time.Now()
+	server.health.LastError = nil
+}
+
+func (server *managedServer) disconnected(err error) {
+	server.mutex.Lock()
+	defer server.mutex.Unlock()
+
+	if server.health.Connected {
+		server.health.Since = time.Now()
+	}
+	server.health.Connected = false
+	server.health.LastError = err
+}
+
+func (server *managedServer) stop() {
+	close(server.done)
+}
@@ -0,0 +1,169 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func mockLogin(srv *amiServer) {
+	srv.Mock("Login", func(params textproto.MIMEHeader) map[string]string {
+		if params.Get("Username") != "admin" || params.Get("Secret") != "secret" {
+			return map[string]string{"Response": "Error", "ActionID": params.Get("Actionid"),
+				"Message": "Authentication failed"}
+		}
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid"),
+			"Message": "Authentication accepted"}
+	})
+}
+
+// waitHealth wait until the health of the server satisfies ok
+func waitHealth(t *testing.T, m *Manager, name string, ok func(ServerHealth) bool) ServerHealth {
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		for _, health := range m.Health() {
+			if health.Name == name && ok(health) {
+				return health
+			}
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected health of %s: %+v", name, m.Health())
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func TestManager(t *testing.T) {
+	srvA := newAmiServer()
+	defer srvA.Close()
+	srvB := newAmiServerAt("localhost:0")
+	defer srvB.Close()
+	srvA.maxDelay, srvB.maxDelay = 0, 0
+	mockLogin(srvA)
+	mockLogin(srvB)
+
+	m := NewManager()
+	defer m.Close()
+	all := m.Subscribe()
+	onlyB := m.Subscribe("b")
+
+	if err := m.Add("a", ServerConfig{Address: srvA.Addr, Username: "admin", Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add("b", ServerConfig{Address: srvB.Addr, Username: "admin", Secret: "secret"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Add("b", ServerConfig{Address: srvB.Addr}); err != errServerExists {
+		t.Fatal("expected server exists", err)
+	}
+	if names := m.Servers(); len(names) != 2 || names[0] != "a" || names[1] != "b" {
+		t.Fatalf("unexpected servers %v", names)
+	}
+
+	waitHealth(t, m, "a", func(h ServerHealth) bool { return h.Connected })
+	waitHealth(t, m, "b", func(h ServerHealth) bool { return h.Connected })
+	if m.Client("a") == nil || m.Client("unknown") != nil {
+		t.Fatal("unexpected clients")
+	}
+
+	seen := make(map[string]bool)
+	timeout := time.After(5 * time.Second)
+	for !seen["a"] || !seen["b"] {
+		select {
+		case ev := <-all.Events():
+			seen[ev.Server] = true
+		case ev := <-onlyB.Events():
+			if ev.Server != "b" {
+				t.Fatalf("event of %s on subscription of b", ev.Server)
+			}
+		case <-timeout:
+			t.Fatalf("events not received from every server %v", seen)
+		}
+	}
+	onlyB.Unsubscribe()
+
+	srvA.Drop()
+	health := waitHealth(t, m, "a", func(h ServerHealth) bool { return h.Connected && h.Reconnects == 1 })
+	if health.LastError != nil {
+		t.Fatalf("unexpected error after reconnect %v", health.LastError)
+	}
+
+	if err := m.Remove("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Remove("a"); err != errUnknownServer {
+		t.Fatal("expected unknown server", err)
+	}
+	if names := m.Servers(); len(names) != 1 || names[0] != "b" {
+		t.Fatalf("unexpected servers %v", names)
+	}
+}
+
+func TestManagerAuthenticationFailed(t *testing.T) {
+	srv := newAmiServer()
+	defer srv.Close()
+	srv.maxDelay = 0
+	mockLogin(srv)
+
+	m := NewManager()
+	defer m.Close()
+	if err := m.Add("pbx", ServerConfig{Address: srv.Addr, Username: "admin", Secret: "wrong"}); err != nil {
+		t.Fatal(err)
+	}
+
+	health := waitHealth(t, m, "pbx", func(h ServerHealth) bool { return h.LastError != nil })
+	if health.Connected || health.LastError.Error() != "Authentication failed" {
+		t.Fatalf("unexpected health %+v", health)
+	}
+}
+
+func TestSubscriptionDeadline(t *testing.T) {
+	m := NewManager()
+	defer m.Close()
+
+	stuck := m.Subscribe()
+	stuck.SetDeadline(50 * time.Millisecond)
+	live := m.Subscribe()
+
+	received := make(chan int)
+	go func() {
+		count := 0
+		for range live.Events() {
+			count++
+			if count == 150 {
+				received <- count
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	for i := 0; i < 150; i++ {
+		m.publish("pbx", &AMIEvent{ID: "Newchannel"})
+	}
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("live subscriber delayed by the stuck one")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("publish waited %s for the stuck subscriber", elapsed)
+	}
+	if dropped := stuck.Dropped(); dropped != 50 {
+		t.Fatalf("expected 50 events dropped, got %d", dropped)
+	}
+	if live.Dropped() != 0 {
+		t.Fatal("unexpected drops on the live subscriber")
+	}
+
+	// the subscriber reads again and stops lagging
+	<-stuck.Events()
+	m.publish("pbx", &AMIEvent{ID: "Hangup"})
+	if dropped := stuck.Dropped(); dropped != 50 {
+		t.Fatalf("expected no more drops, got %d", dropped)
+	}
+}
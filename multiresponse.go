@@ -0,0 +1,78 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"strings"
+)
+
+// ActionOption change how the responses of a single action are delivered
+type ActionOption func(*pendingAction)
+
+// ResponseBuffer size of the chan of the responses of the action, 1 by
+// default. The loop reading the socket waits while the chan is full
+func ResponseBuffer(size int) ActionOption {
+	return func(pending *pendingAction) {
+		if size < 1 {
+			size = 1
+		}
+		pending.response = make(chan *AMIResponse, size)
+	}
+}
+
+// UntilComplete keep the chan of the responses of the action open, every
+// response tagged with its ActionID is delivered until the list completes,
+// with EventList Complete on a response or an event, or an Error response.
+// The events of the action are not routed with RouteActionEvents
+func UntilComplete(pending *pendingAction) {
+	pending.untilComplete = true
+}
+
+// ActionWith send the action as Action, its responses delivered as the
+// options say
+func (client *AMIClient) ActionWith(p Params, options ...ActionOption) (<-chan *AMIResponse, string, error) {
+	return client.action(nil, p, options...)
+}
+
+func (pending *pendingAction) apply(options []ActionOption) {
+	for _, op := range options {
+		op(pending)
+	}
+}
+
+// notifyUntilComplete deliver one of the responses of an action kept open,
+// the chan is closed with the last one
+func (client *AMIClient) notifyUntilComplete(pending *pendingAction, response *AMIResponse) {
+	if pending.responses == 0 {
+		pending.answered(client, response.ID)
+	}
+	pending.responses++
+	response.ctx = pending.ctx
+	client.interceptResponse(response)
+
+	select {
+	case pending.response <- response:
+	case <-client.closed:
+		return
+	}
+
+	if response.Status == "Error" || strings.EqualFold(response.Params["Eventlist"], "Complete") {
+		client.completeResponses(response.ID)
+	}
+}
+
+// completeResponses close the chan of the responses of actionID, when it's
+// kept open until the list completes
+func (client *AMIClient) completeResponses(actionID string) {
+	pending, ok := client.response.get(actionID)
+	if !ok || !pending.untilComplete {
+		return
+	}
+	client.response.take(actionID)
+	if pending.ctx != nil {
+		client.untrackContext(actionID)
+	}
+	close(pending.response)
+}
@@ -0,0 +1,87 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestActionUntilComplete(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	go func() {
+		for range ami.Events {
+		}
+	}()
+
+	srv.MockList("PJSIPShowRegistrationsOutbound", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Response": "Success", "ActionID": id, "Registration": "trunk1"},
+			{"Response": "Success", "ActionID": id, "EventList": "Complete"},
+		}
+	})
+	srv.MockList("Status", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Response": "Success", "ActionID": id, "Channel": "SIP/100-01"},
+			{"Event": "StatusComplete", "ActionID": id, "EventList": "Complete"},
+		}
+	})
+	srv.Mock("Broken", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{"Response": "Error", "ActionID": params.Get("Actionid"), "Message": "Invalid"}
+	})
+
+	tests := []struct {
+		action    string
+		responses int
+	}{
+		// completed by a response
+		{"PJSIPShowRegistrationsOutbound", 3},
+		// completed by an event
+		{"Status", 2},
+		// an error ends it
+		{"Broken", 1},
+	}
+	for _, test := range tests {
+		rs, actionID, err := ami.ActionWith(Params{"Action": test.action}, UntilComplete, ResponseBuffer(10))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cap(rs) != 10 {
+			t.Fatal("unexpected buffer", cap(rs))
+		}
+
+		responses := 0
+		timeout := time.After(time.Second * 2)
+	read:
+		for {
+			select {
+			case response, ok := <-rs:
+				if !ok {
+					break read
+				}
+				if response.ID != actionID {
+					t.Fatal("unexpected ActionID", response.ID)
+				}
+				responses++
+			case <-timeout:
+				t.Fatalf("%s: chan not closed after %d responses", test.action, responses)
+			}
+		}
+		if responses != test.responses {
+			t.Fatalf("%s: expected %d responses, got %d", test.action, test.responses, responses)
+		}
+		if _, ok := ami.response.get(actionID); ok {
+			t.Fatal("action kept pending after completed", test.action)
+		}
+	}
+}
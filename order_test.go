@@ -0,0 +1,51 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestResponseBeforeFollowingEvents(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "UserEvent", "UserEvent": params.Get("Userevent"), "Marker": params.Get("Actionid")},
+		}
+	})
+
+	for i := 0; i < 200; i++ {
+		response, actionID, err := ami.Action(Params{"Action": "UserEvent", "UserEvent": "order"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		timeout := time.After(5 * time.Second)
+	wait:
+		for {
+			select {
+			case ev := <-ami.Events:
+				if ev.Params["Marker"] == actionID {
+					break wait
+				}
+			case <-timeout:
+				t.Fatal("event not received")
+			}
+		}
+
+		select {
+		case <-response:
+		default:
+			t.Fatalf("event of action %d delivered before its response", i)
+		}
+	}
+}
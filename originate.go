@@ -0,0 +1,307 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errClientClosed     = errors.New("Client Closed")
+	errOriginateTimeout = errors.New("Originate without response in time")
+)
+
+// defaultOriginateTimeout Asterisk waits for an originated channel to answer
+const defaultOriginateTimeout = 30 * time.Second
+
+// originateMargin waited for the OriginateResponse beyond the timeout to
+// answer
+var originateMargin = 30 * time.Second
+
+// OriginateRequest a call to originate, the channel is connected to
+// Context/Exten/Priority or to Application(Data) once it answers
+type OriginateRequest struct {
+	Channel     string
+	Context     string
+	Exten       string
+	Priority    int
+	Application string
+	Data        string
+	CallerID    string
+	// Timeout to answer the channel, the default of Asterisk when zero
+	Timeout   time.Duration
+	Account   string
+	Variables map[string]string
+	// ChannelID Uniqueid given to the originated channel, Asterisk >= 12
+	ChannelID string
+}
+
+// OriginateResult outcome of an originate, from its OriginateResponse
+type OriginateResult struct {
+	Success bool
+	// Reason of the outcome as Asterisk reports it, 4 answered, 5 busy,
+	// 3 no answer, 8 congestion...
+	Reason   int
+	Channel  string
+	UniqueID string
+}
+
+// OriginateState progress of an originated channel
+type OriginateState int
+
+const (
+	// OriginateRinging the channel is ringing
+	OriginateRinging OriginateState = iota
+	// OriginateEarlyMedia the channel has progress, audio before answer
+	OriginateEarlyMedia
+	// OriginateAnswered the channel answered
+	OriginateAnswered
+	// OriginateDone the originate ended, the step has its result
+	OriginateDone
+)
+
+var originateStateNames = map[OriginateState]string{
+	OriginateRinging:    "Ringing",
+	OriginateEarlyMedia: "EarlyMedia",
+	OriginateAnswered:   "Answered",
+	OriginateDone:       "Done",
+}
+
+func (state OriginateState) String() string {
+	return originateStateNames[state]
+}
+
+// OriginateStep a step of the progress of an originate
+type OriginateStep struct {
+	State OriginateState
+	Event *AMIEvent
+	// Result of the originate on OriginateDone
+	Result *OriginateResult
+	// Err on OriginateDone without Result, the connection was lost, the
+	// client closed or the OriginateResponse didn't come in time
+	Err error
+}
+
+// params of the Originate action, sent Async to get the OriginateResponse
+func (req *OriginateRequest) params() Params {
+	p := Params{"Action": "Originate", "Channel": req.Channel, "Async": "true"}
+	if req.Application != "" {
+		p["Application"] = req.Application
+		p["Data"] = req.Data
+	} else {
+		priority := req.Priority
+		if priority == 0 {
+			priority = 1
+		}
+		p["Context"] = req.Context
+		p["Exten"] = req.Exten
+		p["Priority"] = strconv.Itoa(priority)
+	}
+	if req.CallerID != "" {
+		p["CallerID"] = req.CallerID
+	}
+	if req.Timeout > 0 {
+		p["Timeout"] = strconv.FormatInt(int64(req.Timeout/time.Millisecond), 10)
+	}
+	if req.Account != "" {
+		p["Account"] = req.Account
+	}
+	if req.ChannelID != "" {
+		p["ChannelId"] = req.ChannelID
+	}
+	if len(req.Variables) > 0 {
+		variables := make([]string, 0, len(req.Variables))
+		for k, v := range req.Variables {
+			variables = append(variables, k+"="+v)
+		}
+		sort.Strings(variables)
+		p["Variable"] = strings.Join(variables, ",")
+	}
+	return p
+}
+
+// responseTimeout to wait for the OriginateResponse of req
+func (req *OriginateRequest) responseTimeout() time.Duration {
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = defaultOriginateTimeout
+	}
+	return timeout + originateMargin
+}
+
+// follower the events of an originated channel and what ends following it
+type follower struct {
+	watcher *eventListener
+	// listener of the action, ended when the connection is lost
+	listener *eventListener
+	// changed closed when the connection is replaced
+	changed <-chan struct{}
+	closed  <-chan struct{}
+	timer   *time.Timer
+}
+
+// follow the events of the originate actionID, sending it with p, until
+// the OriginateResponse comes within timeout
+func (client *AMIClient) follow(p Params, actionID string, timeout time.Duration) (*follower, error) {
+	watcher := client.watch()
+	// keep the OriginateResponse out of Events
+	listener := client.listen(actionID)
+	_, _, changed := client.connection()
+	if _, err := client.syncAction(p); err != nil {
+		client.unwatch(watcher)
+		client.unlisten(actionID)
+		return nil, err
+	}
+
+	return &follower{
+		watcher:  watcher,
+		listener: listener,
+		changed:  changed,
+		closed:   client.closed,
+		timer:    time.NewTimer(timeout),
+	}, nil
+}
+
+// next event, or the error ending the follow
+func (f *follower) next() (*AMIEvent, error) {
+	select {
+	case ev := <-f.watcher.events:
+		return ev, nil
+	case <-f.listener.done:
+		return nil, errConnectionLost
+	case <-f.changed:
+		return nil, errConnectionLost
+	case <-f.timer.C:
+		return nil, errOriginateTimeout
+	case <-f.closed:
+		return nil, errClientClosed
+	}
+}
+
+// answered stop the timeout, the channel lasts as long as the call
+func (f *follower) answered() {
+	if !f.timer.Stop() {
+		select {
+		case <-f.timer.C:
+		default:
+		}
+	}
+}
+
+// stop following
+func (client *AMIClient) unfollow(f *follower, actionID string) {
+	f.timer.Stop()
+	client.unwatch(f.watcher)
+	client.unlisten(actionID)
+}
+
+// Originate the call and wait until the channel answers or fails
+func (client *AMIClient) Originate(req OriginateRequest) (*OriginateResult, error) {
+	steps, err := client.OriginateProgress(req)
+	if err != nil {
+		return nil, err
+	}
+
+	for step := range steps {
+		if step.State == OriginateDone {
+			return step.Result, step.Err
+		}
+	}
+	return nil, errClientClosed
+}
+
+func newOriginateResult(ev *AMIEvent) *OriginateResult {
+	return &OriginateResult{
+		Success:  ev.Get("Response") == "Success",
+		Reason:   ev.GetInt("Reason"),
+		Channel:  ev.Get("Channel"),
+		UniqueID: ev.Get("Uniqueid"),
+	}
+}
+
+// OriginateProgress originate the call reporting its progress, ringing,
+// early media and answer, until the step OriginateDone with the result, the
+// chan is closed after it. The originated channel is followed by its
+// Uniqueid, ChannelID is set when empty. OriginateDone carries Err when
+// the connection is lost or the OriginateResponse doesn't come within
+// Timeout, or the default of 30 seconds, and a margin
+func (client *AMIClient) OriginateProgress(req OriginateRequest) (<-chan OriginateStep, error) {
+	actionID := client.newActionID()
+	if req.ChannelID == "" {
+		req.ChannelID = "gami-" + actionID
+	}
+	p := req.params()
+	p["ActionID"] = actionID
+
+	f, err := client.follow(p, actionID, req.responseTimeout())
+	if err != nil {
+		return nil, err
+	}
+
+	// each state is reported once, the buffer holds them all
+	steps := make(chan OriginateStep, len(originateStateNames))
+	go func() {
+		defer close(steps)
+		defer client.unfollow(f, actionID)
+		followOriginate(actionID, req.ChannelID, f, steps)
+	}()
+
+	return steps, nil
+}
+
+// followOriginate report the progress of the channel uniqueID originated by
+// actionID until its OriginateResponse, each state is reported once
+func followOriginate(actionID, uniqueID string, f *follower, steps chan<- OriginateStep) {
+	reported := make(map[OriginateState]bool)
+	report := func(step OriginateStep) {
+		if reported[step.State] {
+			return
+		}
+		reported[step.State] = true
+		// never blocks the watcher, and so the reader of the client
+		select {
+		case steps <- step:
+		default:
+		}
+	}
+
+	for {
+		ev, err := f.next()
+		if err != nil {
+			report(OriginateStep{State: OriginateDone, Err: err})
+			return
+		}
+
+		switch {
+		// the OriginateResponse is taken from the watcher to keep the
+		// order of the events
+		case ev.ID == "OriginateResponse" && ev.Get("ActionID") == actionID:
+			result := newOriginateResult(ev)
+			if result.Success {
+				report(OriginateStep{State: OriginateAnswered, Event: ev})
+			}
+			report(OriginateStep{State: OriginateDone, Event: ev, Result: result})
+			return
+		case ev.ID == "Newstate" && ev.Get("Uniqueid") == uniqueID:
+			switch ev.Get("ChannelStateDesc") {
+			case "Ringing":
+				report(OriginateStep{State: OriginateRinging, Event: ev})
+			case "Up":
+				report(OriginateStep{State: OriginateAnswered, Event: ev})
+			}
+		case ev.ID == "DialState" && ev.Get("DestUniqueid") == uniqueID:
+			switch ev.Get("DialStatus") {
+			case "RINGING":
+				report(OriginateStep{State: OriginateRinging, Event: ev})
+			case "PROGRESS":
+				report(OriginateStep{State: OriginateEarlyMedia, Event: ev})
+			}
+		}
+	}
+}
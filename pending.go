@@ -0,0 +1,153 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+// pendingShards number of shards of the actions waiting for a response
+const pendingShards = 32
+
+// pendingAction an action waiting for its response
+type pendingAction struct {
+	response chan *AMIResponse
+	action   string
+	sent     time.Time
+	watchdog *time.Timer
+	// threshold of the watchdog, the slow action threshold on send
+	threshold time.Duration
+	// listener of the events of the action, when they are routed to its response
+	listener *eventListener
+	// ctx given to ActionContext, nil for Action
+	ctx context.Context
+	// async an Async Originate, its OriginateResponse follows the response
+	async bool
+	// untilComplete the responses are delivered until the list completes
+	untilComplete bool
+	// responses delivered to the action
+	responses int
+}
+
+// newPendingAction register the send of action, starting its watchdog
+// when slow actions are logged
+func (client *AMIClient) newPendingAction(action string) *pendingAction {
+	pending := &pendingAction{
+		response: make(chan *AMIResponse, 1),
+		action:   action,
+		sent:     time.Now(),
+	}
+
+	client.mutexConfig.RLock()
+	threshold, logger := client.slowAction, client.logger
+	client.mutexConfig.RUnlock()
+
+	if threshold > 0 && logger != nil {
+		pending.threshold = threshold
+		pending.watchdog = time.AfterFunc(threshold, func() {
+			client.logf("gami: action %s without response after %s", pending.action, threshold)
+		})
+	}
+
+	return pending
+}
+
+// deliver the response to the action, the chan is buffered so it never blocks
+func (pending *pendingAction) deliver(response *AMIResponse) {
+	pending.response <- response
+	close(pending.response)
+}
+
+// answered stop the watchdog of the action, logging it if the response
+// arrived late
+func (pending *pendingAction) answered(client *AMIClient, actionID string) {
+	if pending.watchdog == nil {
+		return
+	}
+
+	pending.watchdog.Stop()
+	if elapsed := time.Since(pending.sent); elapsed > pending.threshold {
+		client.logf("gami: action %s (ActionID %s) answered after %s", pending.action, actionID, elapsed)
+	}
+}
+
+// pendingMap actions waiting for a response by ActionID, sharded so the
+// actions sent and the responses read don't contend on a single lock
+type pendingMap struct {
+	shards [pendingShards]pendingShard
+}
+
+type pendingShard struct {
+	mutex   sync.Mutex
+	actions map[string]*pendingAction
+}
+
+func newPendingMap() *pendingMap {
+	m := &pendingMap{}
+	for i := range m.shards {
+		m.shards[i].actions = make(map[string]*pendingAction)
+	}
+	return m
+}
+
+func (m *pendingMap) shard(actionID string) *pendingShard {
+	h := fnv.New32a()
+	h.Write([]byte(actionID))
+	return &m.shards[h.Sum32()%pendingShards]
+}
+
+// register return the action pending for actionID, registering the one
+// given by create if there is none
+func (m *pendingMap) register(actionID string, create func() *pendingAction) *pendingAction {
+	shard := m.shard(actionID)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	pending, ok := shard.actions[actionID]
+	if !ok {
+		pending = create()
+		shard.actions[actionID] = pending
+	}
+	return pending
+}
+
+// get return the action pending for actionID, keeping it
+func (m *pendingMap) get(actionID string) (*pendingAction, bool) {
+	shard := m.shard(actionID)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	pending, ok := shard.actions[actionID]
+	return pending, ok
+}
+
+// take remove and return the action pending for actionID
+func (m *pendingMap) take(actionID string) (*pendingAction, bool) {
+	shard := m.shard(actionID)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	pending, ok := shard.actions[actionID]
+	delete(shard.actions, actionID)
+	return pending, ok
+}
+
+// takeAll remove and return all the actions pending by ActionID
+func (m *pendingMap) takeAll() map[string]*pendingAction {
+	all := make(map[string]*pendingAction)
+	for i := range m.shards {
+		shard := &m.shards[i]
+		shard.mutex.Lock()
+		for actionID, pending := range shard.actions {
+			all[actionID] = pending
+			delete(shard.actions, actionID)
+		}
+		shard.mutex.Unlock()
+	}
+	return all
+}
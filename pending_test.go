@@ -0,0 +1,42 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestPendingMap(t *testing.T) {
+	pending := newPendingMap()
+
+	first := pending.register("1", func() *pendingAction { return &pendingAction{action: "Ping"} })
+	again := pending.register("1", func() *pendingAction { return &pendingAction{action: "Status"} })
+	if first != again || again.action != "Ping" {
+		t.Fatal("pending action registered twice")
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			actionID := "concurrent-" + strconv.Itoa(i)
+			pending.register(actionID, func() *pendingAction { return &pendingAction{action: actionID} })
+			if taken, ok := pending.take(actionID); !ok || taken.action != actionID {
+				t.Error("pending action not taken", actionID)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if taken, ok := pending.take("1"); !ok || taken != first {
+		t.Fatal("pending action lost")
+	}
+	if _, ok := pending.take("1"); ok {
+		t.Fatal("pending action taken twice")
+	}
+}
@@ -0,0 +1,59 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrActionDenied returned by Action for the actions refused by the
+// AllowActions or DenyActions of the client
+var ErrActionDenied = errors.New("Action denied by policy")
+
+// sessionActions needed to open the session, allowed unless denied
+var sessionActions = map[string]bool{
+	"login":     true,
+	"challenge": true,
+}
+
+// AllowActions send only the actions named, ignoring case, Login and
+// Challenge are always allowed so the session can be opened. Without
+// actions the restriction is removed
+func AllowActions(actions ...string) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.allowActions = actionSet(actions)
+	}
+}
+
+// DenyActions refuse the actions named, ignoring case, even when
+// AllowActions allows them. Without actions the restriction is removed
+func DenyActions(actions ...string) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.denyActions = actionSet(actions)
+	}
+}
+
+// allowedAction check the action against the allow and deny lists, nil
+// lists don't restrict
+func allowedAction(action string, allow, deny map[string]bool) bool {
+	action = strings.ToLower(action)
+	if deny[action] {
+		return false
+	}
+	return allow == nil || allow[action] || sessionActions[action]
+}
+
+// actionSet the actions in lower case, nil when empty
+func actionSet(actions []string) map[string]bool {
+	if len(actions) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(actions))
+	for _, action := range actions {
+		set[strings.ToLower(action)] = true
+	}
+	return set
+}
@@ -0,0 +1,46 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"testing"
+)
+
+func TestActionPolicy(t *testing.T) {
+	srv := newAmiServer()
+	defer srv.Close()
+	ami, err := Dial(srv.Addr, AllowActions("Ping", "Status", "Hangup"), DenyActions("hangup"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	if err := ami.Login("admin", "admin"); err != nil {
+		t.Fatal("expected Login allowed, got", err)
+	}
+	if _, _, err := ami.Action(Params{"Action": "Hangup", "Channel": "SIP/100-01"}); err != ErrActionDenied {
+		t.Fatal("expected Hangup denied, got", err)
+	}
+	if _, _, err := ami.Action(Params{"Action": "Reload"}); err != ErrActionDenied {
+		t.Fatal("expected Reload not allowed, got", err)
+	}
+
+	response, _, err := ami.Action(Params{"Action": "ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-response
+
+	ami.Configure(AllowActions(), DenyActions("Ping"))
+	if _, _, err := ami.Action(Params{"Action": "Ping"}); err != ErrActionDenied {
+		t.Fatal("expected Ping denied, got", err)
+	}
+	response, _, err = ami.Action(Params{"Action": "Reload"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-response
+}
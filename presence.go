@@ -0,0 +1,100 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"encoding/base64"
+	"strings"
+)
+
+// PresenceStatus presence of a provider as ast_presence_state
+type PresenceStatus int
+
+const (
+	// PresenceInvalid a state not known by the client
+	PresenceInvalid PresenceStatus = iota
+	PresenceNotSet
+	PresenceUnavailable
+	PresenceAvailable
+	PresenceAway
+	PresenceXA
+	PresenceChat
+	PresenceDND
+)
+
+// presenceNames names of the states on Asterisk
+var presenceNames = map[PresenceStatus]string{
+	PresenceInvalid:     "invalid",
+	PresenceNotSet:      "not_set",
+	PresenceUnavailable: "unavailable",
+	PresenceAvailable:   "available",
+	PresenceAway:        "away",
+	PresenceXA:          "xa",
+	PresenceChat:        "chat",
+	PresenceDND:         "dnd",
+}
+
+func (status PresenceStatus) String() string {
+	if name, ok := presenceNames[status]; ok {
+		return name
+	}
+	return presenceNames[PresenceInvalid]
+}
+
+// ParsePresenceStatus return the status named as Asterisk, ignoring case,
+// PresenceInvalid when unknown
+func ParsePresenceStatus(name string) PresenceStatus {
+	name = strings.ToLower(strings.TrimSpace(name))
+	for status, statusName := range presenceNames {
+		if statusName == name {
+			return status
+		}
+	}
+	return PresenceInvalid
+}
+
+// Presence state of a presence provider, like CustomPresence:alice
+type Presence struct {
+	Provider string
+	Status   PresenceStatus
+	Subtype  string
+	Message  string
+}
+
+// PresenceState return the presence of provider
+func (client *AMIClient) PresenceState(provider string) (*Presence, error) {
+	resp, err := client.syncAction(Params{"Action": "PresenceState", "Provider": provider})
+	if err != nil {
+		return nil, err
+	}
+
+	presence := &Presence{
+		Provider: provider,
+		Status:   ParsePresenceStatus(resp.Get("State")),
+		Subtype:  resp.Get("Subtype"),
+	}
+	// the message follows the Message of the response itself
+	if messages := resp.Repeated["Message"]; len(messages) > 1 {
+		presence.Message = messages[len(messages)-1]
+	}
+	return presence, nil
+}
+
+// SetPresenceState set the presence of a CustomPresence provider writing
+// PRESENCE_STATE, subtype and message are sent base64 encoded so they can
+// carry commas
+func (client *AMIClient) SetPresenceState(provider string, status PresenceStatus, subtype, message string) error {
+	if provider == "" || status == PresenceInvalid {
+		return errInvalidParams
+	}
+
+	value := strings.Join([]string{status.String(),
+		base64.StdEncoding.EncodeToString([]byte(subtype)),
+		base64.StdEncoding.EncodeToString([]byte(message)), "e"}, ",")
+
+	_, err := client.syncAction(Params{"Action": "Setvar",
+		"Variable": "PRESENCE_STATE(" + provider + ")", "Value": value})
+	return err
+}
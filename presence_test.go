@@ -0,0 +1,66 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+)
+
+func TestPresenceState(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.Mock("PresenceState", func(params textproto.MIMEHeader) map[string]string {
+		// Asterisk repeats Message, the second one is the presence message
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid"),
+			"Message": "Presence State\r\nMessage: Out, to lunch", "State": "away", "Subtype": "temporary"}
+	})
+
+	presence, err := ami.PresenceState("CustomPresence:alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if presence.Status != PresenceAway || presence.Subtype != "temporary" || presence.Message != "Out, to lunch" {
+		t.Fatalf("unexpected presence %+v", presence)
+	}
+}
+
+func TestSetPresenceState(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	set := make(chan textproto.MIMEHeader, 1)
+	srv.Mock("Setvar", func(params textproto.MIMEHeader) map[string]string {
+		set <- params
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid")}
+	})
+
+	if err := ami.SetPresenceState("CustomPresence:alice", PresenceDND, "meeting", "Back at 3, maybe"); err != nil {
+		t.Fatal(err)
+	}
+	params := <-set
+	if params.Get("Variable") != "PRESENCE_STATE(CustomPresence:alice)" ||
+		params.Get("Value") != "dnd,bWVldGluZw==,QmFjayBhdCAzLCBtYXliZQ==,e" {
+		t.Fatalf("unexpected Setvar %v", params)
+	}
+
+	if err := ami.SetPresenceState("CustomPresence:alice", PresenceInvalid, "", ""); err != errInvalidParams {
+		t.Fatal("expected invalid params, got", err)
+	}
+}
+
+func TestParsePresenceStatus(t *testing.T) {
+	for status := PresenceNotSet; status <= PresenceDND; status++ {
+		if ParsePresenceStatus(status.String()) != status {
+			t.Fatalf("%s not parsed", status)
+		}
+	}
+	if ParsePresenceStatus("AWAY") != PresenceAway || ParsePresenceStatus("busy") != PresenceInvalid {
+		t.Fatal("unexpected parse")
+	}
+}
@@ -0,0 +1,497 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+// Package proxy implements the server side of AMI, sharing one upstream
+// connection to Asterisk with many AMI clients.
+//
+// Login, Logoff and Events are answered by the proxy, any other action is
+// forwarded upstream with an ActionID owned by the proxy and its response
+// and events are returned to the client that sent it. Headers are forwarded
+// canonicalized as read by gami.
+package proxy
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/googolgl/gami"
+)
+
+// ErrServerClosed returned by Serve after Close
+var ErrServerClosed = errors.New("Server closed")
+
+// actionIDPrefix prefix of the ActionID of the actions forwarded upstream,
+// followed by the session id and the ActionID of the client. The session id
+// of an action sent without ActionID has a sequence, as in 3.1, to keep the
+// ActionID unique
+const actionIDPrefix = "gami-proxy-"
+
+// User a client allowed to login on the proxy
+type User struct {
+	Username string
+	Secret   string
+	// Filter select the events forwarded to the user, nil forward all
+	Filter func(ev *gami.AMIEvent) bool
+}
+
+// Server an AMI server forwarding the actions of its clients to upstream
+type Server struct {
+	upstream *gami.AMIClient
+	users    map[string]User
+
+	mutex     *sync.RWMutex
+	sessions  map[uint64]*session
+	listeners map[net.Listener]struct{}
+	nextID    uint64
+
+	done chan struct{}
+	once *sync.Once
+}
+
+// NewServer create a proxy for upstream, the server consumes the Events of
+// upstream from now on to dispatch them to its clients
+func NewServer(upstream *gami.AMIClient, users ...User) *Server {
+	srv := &Server{
+		upstream:  upstream,
+		users:     make(map[string]User),
+		mutex:     new(sync.RWMutex),
+		sessions:  make(map[uint64]*session),
+		listeners: make(map[net.Listener]struct{}),
+		done:      make(chan struct{}),
+		once:      new(sync.Once),
+	}
+	for _, user := range users {
+		srv.users[user.Username] = user
+	}
+
+	go srv.dispatchEvents()
+	return srv
+}
+
+// ListenAndServe listen on the TCP address and serve AMI clients
+func (srv *Server) ListenAndServe(address string) error {
+	l, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(l)
+}
+
+// Serve AMI clients accepted on l until Close
+func (srv *Server) Serve(l net.Listener) error {
+	srv.mutex.Lock()
+	select {
+	case <-srv.done:
+		srv.mutex.Unlock()
+		l.Close()
+		return ErrServerClosed
+	default:
+	}
+	srv.listeners[l] = struct{}{}
+	srv.mutex.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			select {
+			case <-srv.done:
+				return ErrServerClosed
+			default:
+				return err
+			}
+		}
+
+		srv.mutex.Lock()
+		srv.nextID++
+		sess := newSession(srv, srv.nextID, conn)
+		srv.sessions[sess.id] = sess
+		srv.mutex.Unlock()
+
+		go sess.serve()
+	}
+}
+
+// Close the listeners and the clients connected, upstream is not closed
+func (srv *Server) Close() error {
+	srv.once.Do(func() {
+		close(srv.done)
+	})
+
+	srv.mutex.Lock()
+	listeners := srv.listeners
+	sessions := srv.sessions
+	srv.listeners = make(map[net.Listener]struct{})
+	srv.sessions = make(map[uint64]*session)
+	srv.mutex.Unlock()
+
+	for l := range listeners {
+		l.Close()
+	}
+	for _, sess := range sessions {
+		sess.close()
+	}
+	return nil
+}
+
+// authenticate return the user with the credentials
+func (srv *Server) authenticate(username, secret string) (*User, bool) {
+	user, ok := srv.users[username]
+	if !ok || subtle.ConstantTimeCompare([]byte(user.Secret), []byte(secret)) != 1 {
+		return nil, false
+	}
+	return &user, true
+}
+
+// remove the session from the server
+func (srv *Server) remove(sess *session) {
+	srv.mutex.Lock()
+	delete(srv.sessions, sess.id)
+	srv.mutex.Unlock()
+}
+
+// dispatchEvents send the events of upstream to the clients, the events of
+// an action forwarded go only to the client that sent it
+func (srv *Server) dispatchEvents() {
+	for {
+		select {
+		case <-srv.done:
+			return
+		case ev := <-srv.upstream.Events:
+			srv.dispatch(ev)
+		}
+	}
+}
+
+func (srv *Server) dispatch(ev *gami.AMIEvent) {
+	srv.mutex.RLock()
+	defer srv.mutex.RUnlock()
+
+	if id, actionID, ok := parseActionID(ev.Params["Actionid"]); ok {
+		if sess, ok := srv.sessions[id]; ok {
+			sess.sendEvent(eventFrame(ev, actionID))
+		}
+		return
+	}
+
+	for _, sess := range srv.sessions {
+		if sess.wantEvent(ev) {
+			sess.sendEvent(eventFrame(ev, ev.Params["Actionid"]))
+		}
+	}
+}
+
+// session a client connected to the proxy
+type session struct {
+	// anonymous sequence of the actions sent without ActionID, first for
+	// the alignment of atomic
+	anonymous uint64
+
+	id     uint64
+	server *Server
+	conn   net.Conn
+	out    chan string
+
+	mutex  *sync.Mutex
+	user   *User
+	events bool
+
+	closed chan struct{}
+	once   *sync.Once
+}
+
+func newSession(srv *Server, id uint64, conn net.Conn) *session {
+	return &session{
+		id:     id,
+		server: srv,
+		conn:   conn,
+		out:    make(chan string, 100),
+		mutex:  new(sync.Mutex),
+		closed: make(chan struct{}),
+		once:   new(sync.Once),
+	}
+}
+
+func (sess *session) serve() {
+	defer sess.close()
+	go sess.write()
+
+	sess.send("Asterisk Call Manager/gami-proxy\r\n")
+
+	r := textproto.NewReader(bufio.NewReader(sess.conn))
+	for {
+		frame, err := gami.ReadFrame(r)
+		if err != nil {
+			return
+		}
+		if len(frame) == 0 {
+			continue
+		}
+		if !sess.handle(frame) {
+			return
+		}
+	}
+}
+
+// handle the action received, return false to end the session
+func (sess *session) handle(frame textproto.MIMEHeader) bool {
+	actionID := frame.Get("Actionid")
+
+	switch strings.ToLower(frame.Get("Action")) {
+	case "":
+		sess.reply(actionID, "Error", "Message", "Missing action in request")
+		return true
+	case "login":
+		user, ok := sess.server.authenticate(frame.Get("Username"), frame.Get("Secret"))
+		if !ok {
+			sess.reply(actionID, "Error", "Message", "Authentication failed")
+			return true
+		}
+		sess.mutex.Lock()
+		sess.user = user
+		sess.events = strings.ToLower(frame.Get("Events")) != "off"
+		sess.mutex.Unlock()
+		sess.reply(actionID, "Success", "Message", "Authentication accepted")
+		return true
+	case "logoff":
+		sess.reply(actionID, "Goodbye", "Message", "Thanks for all the fish.")
+		return false
+	}
+
+	sess.mutex.Lock()
+	authenticated := sess.user != nil
+	sess.mutex.Unlock()
+	if !authenticated {
+		sess.reply(actionID, "Error", "Message", "Permission denied")
+		return true
+	}
+
+	if strings.ToLower(frame.Get("Action")) == "events" {
+		on := strings.ToLower(frame.Get("Eventmask")) != "off"
+		sess.mutex.Lock()
+		sess.events = on
+		sess.mutex.Unlock()
+		if on {
+			sess.reply(actionID, "Success", "Events", "On")
+		} else {
+			sess.reply(actionID, "Success", "Events", "Off")
+		}
+		return true
+	}
+
+	sess.forward(frame, actionID)
+	return true
+}
+
+// forward the action upstream, its response is sent back asynchronously
+func (sess *session) forward(frame textproto.MIMEHeader, actionID string) {
+	p := make(gami.Params)
+	for k, v := range frame {
+		p[k] = v[0]
+	}
+	upstreamID := actionIDPrefix + strconv.FormatUint(sess.id, 10)
+	if actionID == "" {
+		upstreamID += "." + strconv.FormatUint(atomic.AddUint64(&sess.anonymous, 1), 10)
+	}
+	p["Actionid"] = upstreamID + "-" + actionID
+
+	response, _, err := sess.server.upstream.Action(p)
+	if err != nil {
+		sess.reply(actionID, "Error", "Message", err.Error())
+		return
+	}
+
+	go func() {
+		select {
+		case resp, ok := <-response:
+			if ok {
+				sess.send(responseFrame(resp, actionID))
+			}
+		case <-sess.closed:
+		}
+	}()
+}
+
+// wantEvent check if the event is forwarded to the client
+func (sess *session) wantEvent(ev *gami.AMIEvent) bool {
+	sess.mutex.Lock()
+	defer sess.mutex.Unlock()
+
+	if sess.user == nil || !sess.events {
+		return false
+	}
+	return sess.user.Filter == nil || sess.user.Filter(ev)
+}
+
+// reply send a response built by the proxy
+func (sess *session) reply(actionID, status string, fields ...string) {
+	params := make(map[string]string)
+	for i := 0; i+1 < len(fields); i += 2 {
+		params[fields[i]] = fields[i+1]
+	}
+	sess.send(responseFrame(&gami.AMIResponse{Status: status, Params: params}, actionID))
+}
+
+// send the frame waiting while the client is slow
+func (sess *session) send(frame string) {
+	select {
+	case sess.out <- frame:
+	case <-sess.closed:
+	}
+}
+
+// sendEvent send the event frame, dropping it when the client is slow to
+// not delay the other clients
+func (sess *session) sendEvent(frame string) {
+	select {
+	case sess.out <- frame:
+	default:
+	}
+}
+
+func (sess *session) write() {
+	defer sess.conn.Close()
+
+	for {
+		select {
+		case frame := <-sess.out:
+			if _, err := io.WriteString(sess.conn, frame); err != nil {
+				sess.close()
+				return
+			}
+		case <-sess.closed:
+			//flush the frames queued before closing, like the Goodbye of Logoff
+			sess.conn.SetWriteDeadline(time.Now().Add(time.Second))
+			for {
+				select {
+				case frame := <-sess.out:
+					if _, err := io.WriteString(sess.conn, frame); err != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// close the session, the connection is closed once the frames queued are written
+func (sess *session) close() {
+	sess.once.Do(func() {
+		close(sess.closed)
+		sess.server.remove(sess)
+	})
+}
+
+// parseActionID return the session and the ActionID of the client of an
+// ActionID of an action forwarded
+func parseActionID(actionID string) (uint64, string, bool) {
+	if !strings.HasPrefix(actionID, actionIDPrefix) {
+		return 0, "", false
+	}
+
+	parts := strings.SplitN(actionID[len(actionIDPrefix):], "-", 2)
+	if len(parts) != 2 {
+		return 0, "", false
+	}
+
+	// the sequence of an action without ActionID
+	if i := strings.IndexByte(parts[0], '.'); i >= 0 {
+		parts[0] = parts[0][:i]
+	}
+	id, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		return 0, "", false
+	}
+	return id, parts[1], true
+}
+
+// responseFrame serialize the response with the ActionID of the client, the
+// output of a Follows response is written raw until --END COMMAND--
+func responseFrame(resp *gami.AMIResponse, actionID string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Response: %s\r\n", resp.Status)
+	if !strings.EqualFold(resp.Status, "Follows") {
+		writeParams(&b, resp.Params, resp.Repeated, actionID)
+		b.WriteString("\r\n")
+		return b.String()
+	}
+
+	if privilege := resp.Params["Privilege"]; privilege != "" {
+		fmt.Fprintf(&b, "Privilege: %s\r\n", privilege)
+	}
+	if actionID != "" {
+		fmt.Fprintf(&b, "ActionID: %s\r\n", actionID)
+	}
+	if output, ok := resp.Params["Output"]; ok {
+		for _, line := range strings.Split(output, "\n") {
+			fmt.Fprintf(&b, "%s\r\n", line)
+		}
+	}
+	b.WriteString("--END COMMAND--\r\n\r\n")
+	return b.String()
+}
+
+// eventFrame serialize the event with the ActionID of the client
+func eventFrame(ev *gami.AMIEvent, actionID string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Event: %s\r\n", ev.ID)
+	if privilege := strings.Join(ev.Privilege, ","); privilege != "" {
+		fmt.Fprintf(&b, "Privilege: %s\r\n", privilege)
+	}
+	writeParams(&b, ev.Params, nil, actionID)
+
+	for channel, variables := range ev.ChanVariables {
+		key := "ChanVariable"
+		if channel != ev.Params["Channel"] {
+			key = "ChanVariable(" + channel + ")"
+		}
+		for k, v := range variables {
+			fmt.Fprintf(&b, "%s: %s=%s\r\n", key, k, v)
+		}
+	}
+	b.WriteString("\r\n")
+	return b.String()
+}
+
+// writeParams write ActionID if not empty and the params ordered by key, a
+// repeated param with all its values and the Output joined by gami with a
+// line per value
+func writeParams(b *strings.Builder, params map[string]string, repeated map[string][]string, actionID string) {
+	if actionID != "" {
+		fmt.Fprintf(b, "ActionID: %s\r\n", actionID)
+	}
+
+	keys := make([]string, 0, len(params))
+	for k := range params {
+		if k != "Actionid" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		values, ok := repeated[k]
+		switch {
+		case ok:
+		case k == "Output":
+			values = strings.Split(params[k], "\n")
+		default:
+			values = []string{params[k]}
+		}
+		for _, v := range values {
+			fmt.Fprintf(b, "%s: %s\r\n", k, v)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package proxy
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/googolgl/gami"
+)
+
+// upstreamServer fake Asterisk answering Success to every action
+type upstreamServer struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	conn     net.Conn
+}
+
+func newUpstreamServer(t *testing.T) *upstreamServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &upstreamServer{listener: listener}
+	go srv.serve()
+	return srv
+}
+
+func (srv *upstreamServer) serve() {
+	conn, err := srv.listener.Accept()
+	if err != nil {
+		return
+	}
+	srv.mutex.Lock()
+	srv.conn = conn
+	srv.mutex.Unlock()
+
+	srv.write("Asterisk Call Manager/5.0.0\r\n")
+	r := textproto.NewReader(bufio.NewReader(conn))
+	for {
+		header, err := r.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		id := header.Get("Actionid")
+		srv.write(fmt.Sprintf("Response: Success\r\nActionID: %s\r\n\r\n", id))
+		if header.Get("Action") == "CoreShowChannels" {
+			srv.write(fmt.Sprintf("Event: CoreShowChannel\r\nActionID: %s\r\nChannel: SIP/100-01\r\n\r\n", id))
+		}
+	}
+}
+
+// write raw data to the client
+func (srv *upstreamServer) write(data string) {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	fmt.Fprint(srv.conn, data)
+}
+
+func (srv *upstreamServer) Close() {
+	srv.listener.Close()
+	srv.mutex.Lock()
+	if srv.conn != nil {
+		srv.conn.Close()
+	}
+	srv.mutex.Unlock()
+}
+
+// newTestProxy start an upstream server and a proxy to it
+func newTestProxy(t *testing.T, users ...User) (*upstreamServer, *Server, string) {
+	upstreamSrv := newUpstreamServer(t)
+	upstream, err := gami.Dial(upstreamSrv.listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream.Run()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := NewServer(upstream, users...)
+	go srv.Serve(listener)
+	return upstreamSrv, srv, listener.Addr().String()
+}
+
+// dialProxy connect and login a client to the proxy
+func dialProxy(t *testing.T, addr, username, secret string) *gami.AMIClient {
+	client, err := gami.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Run()
+	if err := client.Login(username, secret); err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+// waitEvent wait for the event id on client
+func waitEvent(client *gami.AMIClient, id string, timeout time.Duration) (*gami.AMIEvent, bool) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case ev := <-client.Events:
+			if ev.ID == id {
+				return ev, true
+			}
+		case <-deadline:
+			return nil, false
+		}
+	}
+}
+
+func TestProxyLoginAndAction(t *testing.T) {
+	upstreamSrv, srv, addr := newTestProxy(t, User{Username: "user", Secret: "secret"})
+	defer upstreamSrv.Close()
+	defer srv.Close()
+
+	client, err := gami.Dial(addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Run()
+	defer client.Close()
+
+	if err := client.Login("user", "bad"); err == nil || err.Error() != "Authentication failed" {
+		t.Fatal("expected authentication failed, got", err)
+	}
+
+	response, _, err := client.Action(gami.Params{"Action": "Ping", "ActionID": "ping-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := <-response; resp.Status != "Error" {
+		t.Fatal("action allowed without login")
+	}
+
+	if err := client.Login("user", "secret"); err != nil {
+		t.Fatal(err)
+	}
+
+	response, _, err = client.Action(gami.Params{"Action": "Ping", "ActionID": "ping-2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case resp := <-response:
+		if resp.Status != "Success" || resp.ID != "ping-2" {
+			t.Fatalf("unexpected response %+v", resp)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("response not forwarded")
+	}
+}
+
+func TestProxyEvents(t *testing.T) {
+	onlyHangup := func(ev *gami.AMIEvent) bool {
+		return ev.ID == "Hangup"
+	}
+	upstreamSrv, srv, addr := newTestProxy(t,
+		User{Username: "all", Secret: "secret"},
+		User{Username: "hangup", Secret: "secret", Filter: onlyHangup})
+	defer upstreamSrv.Close()
+	defer srv.Close()
+
+	all := dialProxy(t, addr, "all", "secret")
+	defer all.Close()
+	hangup := dialProxy(t, addr, "hangup", "secret")
+	defer hangup.Close()
+
+	upstreamSrv.write("Event: Newchannel\r\nPrivilege: call,all\r\nChannel: SIP/100-01\r\nChanVariable: FOO=bar\r\n\r\n")
+	upstreamSrv.write("Event: Hangup\r\nChannel: SIP/100-01\r\n\r\n")
+
+	ev, ok := waitEvent(all, "Newchannel", time.Second*5)
+	if !ok {
+		t.Fatal("Newchannel not forwarded")
+	}
+	if ev.Params["Channel"] != "SIP/100-01" || ev.ChanVariables["SIP/100-01"]["FOO"] != "bar" {
+		t.Fatalf("unexpected event %+v", ev)
+	}
+	if strings.Join(ev.Privilege, ",") != "call,all" {
+		t.Fatal("unexpected privilege", ev.Privilege)
+	}
+
+	ev, ok = waitEvent(hangup, "Hangup", time.Second*5)
+	if !ok {
+		t.Fatal("Hangup not forwarded")
+	}
+	select {
+	case ev := <-hangup.Events:
+		if ev.ID == "Newchannel" {
+			t.Fatal("filtered event forwarded")
+		}
+	default:
+	}
+}
+
+func TestProxyActionEvents(t *testing.T) {
+	upstreamSrv, srv, addr := newTestProxy(t,
+		User{Username: "one", Secret: "secret"},
+		User{Username: "two", Secret: "secret"})
+	defer upstreamSrv.Close()
+	defer srv.Close()
+
+	one := dialProxy(t, addr, "one", "secret")
+	defer one.Close()
+	two := dialProxy(t, addr, "two", "secret")
+	defer two.Close()
+
+	if _, _, err := one.Action(gami.Params{"Action": "CoreShowChannels", "ActionID": "list-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	ev, ok := waitEvent(one, "CoreShowChannel", time.Second*5)
+	if !ok {
+		t.Fatal("action event not forwarded")
+	}
+	if ev.Params["Actionid"] != "list-1" {
+		t.Fatal("ActionID not restored", ev.Params["Actionid"])
+	}
+
+	if _, ok := waitEvent(two, "CoreShowChannel", time.Millisecond*200); ok {
+		t.Fatal("action event forwarded to another client")
+	}
+}
+
+func TestParseActionID(t *testing.T) {
+	id, actionID, ok := parseActionID("gami-proxy-12-my-id")
+	if !ok || id != 12 || actionID != "my-id" {
+		t.Fatal("unexpected", id, actionID, ok)
+	}
+	id, actionID, ok = parseActionID("gami-proxy-12.3-")
+	if !ok || id != 12 || actionID != "" {
+		t.Fatal("unexpected", id, actionID, ok)
+	}
+	if _, _, ok := parseActionID("12345"); ok {
+		t.Fatal("parsed foreign ActionID")
+	}
+}
+
+func TestProxyActionsWithoutActionID(t *testing.T) {
+	upstreamSrv, srv, addr := newTestProxy(t, User{Username: "user", Secret: "secret"})
+	defer upstreamSrv.Close()
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(time.Second * 5))
+	r := textproto.NewReader(bufio.NewReader(conn))
+	if _, err := r.ReadLine(); err != nil {
+		t.Fatal(err)
+	}
+
+	fmt.Fprint(conn, "Action: Login\r\nUsername: user\r\nSecret: secret\r\n\r\n")
+	fmt.Fprint(conn, "Action: Ping\r\n\r\nAction: Ping\r\n\r\n")
+
+	// the login and both pings are answered, without ActionID
+	for i := 0; i < 3; i++ {
+		frame, err := gami.ReadFrame(r)
+		if err != nil {
+			t.Fatal("response", i, err)
+		}
+		if frame.Get("Response") != "Success" || frame.Get("Actionid") != "" {
+			t.Fatalf("unexpected response %v", frame)
+		}
+	}
+}
+
+func TestResponseFrame(t *testing.T) {
+	follows := &gami.AMIResponse{Status: "Follows", Params: map[string]string{
+		"Privilege": "Command", "Actionid": "gami-proxy-1-cmd", "Output": "Uptime: 1 day\nlast line",
+	}}
+	success := &gami.AMIResponse{Status: "Success",
+		Params:   map[string]string{"Output": "a\nb", "Variable": "A=1"},
+		Repeated: map[string][]string{"Variable": {"A=1", "B=2"}},
+	}
+
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(
+		responseFrame(follows, "cmd") + responseFrame(success, "next"))))
+
+	frame, err := gami.ReadFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Get("Actionid") != "cmd" || strings.Join(frame["Output"], "\n") != "Uptime: 1 day\nlast line" {
+		t.Fatalf("unexpected Follows %v", frame)
+	}
+
+	frame, err = gami.ReadFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Get("Actionid") != "next" {
+		t.Fatalf("frames out of step %v", frame)
+	}
+	if strings.Join(frame["Output"], ",") != "a,b" || strings.Join(frame["Variable"], ",") != "A=1,B=2" {
+		t.Fatalf("unexpected response %v", frame)
+	}
+}
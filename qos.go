@@ -0,0 +1,200 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// defaultClockRate of the RTP timestamps, G.711 and most narrowband codecs
+const defaultClockRate = 8000
+
+// QoSOptions of a QoSMonitor
+type QoSOptions struct {
+	// ClockRate of the RTP timestamps to convert the jitter, 8000 when zero
+	ClockRate int
+	// OnSummary called with the quality of a channel when it hangs up, only
+	// for channels with RTCP reports
+	OnSummary func(QoSSummary)
+}
+
+// QoSStats quality of a direction of the media of a channel
+type QoSStats struct {
+	// Reports received with a report block
+	Reports   int
+	AvgJitter time.Duration
+	MaxJitter time.Duration
+	// AvgLoss and MaxLoss fraction of packets lost between reports, in
+	// percent
+	AvgLoss float64
+	MaxLoss float64
+	// Lost packets lost since the start of the stream
+	Lost int
+
+	totalJitter time.Duration
+	totalLoss   float64
+}
+
+// QoSSummary quality of the media of a channel
+type QoSSummary struct {
+	Channel  string
+	UniqueID string
+	LinkedID string
+	// Inbound the media received from the remote, from RTCPSent
+	Inbound QoSStats
+	// Outbound the media sent to the remote, from RTCPReceived
+	Outbound QoSStats
+	AvgRTT   time.Duration
+	MaxRTT   time.Duration
+	Start    time.Time
+	End      time.Time
+
+	rttSamples int
+	totalRTT   time.Duration
+}
+
+// QoSMonitor aggregate the RTCPSent and RTCPReceived events by channel
+type QoSMonitor struct {
+	client   *AMIClient
+	opts     QoSOptions
+	watcher  *eventListener
+	mutex    *sync.Mutex
+	once     *sync.Once
+	channels map[string]*QoSSummary
+}
+
+// MonitorQoS start aggregating the RTCP reports of the channels, rtcp_events
+// must be enabled on the server for them to be sent
+func (client *AMIClient) MonitorQoS(opts QoSOptions) *QoSMonitor {
+	if opts.ClockRate <= 0 {
+		opts.ClockRate = defaultClockRate
+	}
+
+	monitor := &QoSMonitor{
+		client:   client,
+		opts:     opts,
+		watcher:  client.watch(),
+		mutex:    new(sync.Mutex),
+		once:     new(sync.Once),
+		channels: make(map[string]*QoSSummary),
+	}
+
+	go func() {
+		for {
+			select {
+			case <-monitor.watcher.done:
+				return
+			case ev := <-monitor.watcher.events:
+				monitor.handle(ev)
+			}
+		}
+	}()
+
+	return monitor
+}
+
+// Active return the quality so far of the channels alive, ordered by start
+func (monitor *QoSMonitor) Active() []QoSSummary {
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	summaries := make([]QoSSummary, 0, len(monitor.channels))
+	for _, summary := range monitor.channels {
+		summaries = append(summaries, *summary)
+	}
+	sort.Slice(summaries, func(i, j int) bool {
+		return summaries[i].Start.Before(summaries[j].Start)
+	})
+	return summaries
+}
+
+// Stop aggregating the reports
+func (monitor *QoSMonitor) Stop() {
+	monitor.once.Do(func() {
+		monitor.client.unwatch(monitor.watcher)
+	})
+}
+
+// handle add the report of the event or emit the summary on Hangup
+func (monitor *QoSMonitor) handle(ev *AMIEvent) {
+	key := ev.Get("Uniqueid")
+	if key == "" {
+		key = ev.Get("Channel")
+	}
+	if key == "" {
+		return
+	}
+
+	switch ev.ID {
+	case "RTCPSent", "RTCPReceived":
+	case "Hangup":
+		monitor.mutex.Lock()
+		summary, ok := monitor.channels[key]
+		delete(monitor.channels, key)
+		monitor.mutex.Unlock()
+
+		if ok && monitor.opts.OnSummary != nil {
+			summary.End = time.Now()
+			monitor.opts.OnSummary(*summary)
+		}
+		return
+	default:
+		return
+	}
+
+	monitor.mutex.Lock()
+	defer monitor.mutex.Unlock()
+
+	summary, ok := monitor.channels[key]
+	if !ok {
+		summary = &QoSSummary{
+			Channel:  ev.Get("Channel"),
+			UniqueID: ev.Get("Uniqueid"),
+			LinkedID: ev.Get("Linkedid"),
+			Start:    time.Now(),
+		}
+		monitor.channels[key] = summary
+	}
+
+	stats := &summary.Inbound
+	if ev.ID == "RTCPReceived" {
+		stats = &summary.Outbound
+		if rtt := ev.Get("RTT"); rtt != "" {
+			summary.addRTT(time.Duration(parseFloat(rtt) * float64(time.Second)))
+		}
+	}
+	if ev.GetInt("ReportCount") > 0 {
+		jitter := time.Duration(ev.GetInt("Report0IAJitter")) * time.Second / time.Duration(monitor.opts.ClockRate)
+		stats.add(jitter, float64(ev.GetInt("Report0FractionLost"))*100/256, ev.GetInt("Report0CumulativeLost"))
+	}
+}
+
+// add a report block to the stats
+func (stats *QoSStats) add(jitter time.Duration, loss float64, lost int) {
+	stats.Reports++
+	stats.totalJitter += jitter
+	stats.totalLoss += loss
+	stats.AvgJitter = stats.totalJitter / time.Duration(stats.Reports)
+	stats.AvgLoss = stats.totalLoss / float64(stats.Reports)
+	if jitter > stats.MaxJitter {
+		stats.MaxJitter = jitter
+	}
+	if loss > stats.MaxLoss {
+		stats.MaxLoss = loss
+	}
+	stats.Lost = lost
+}
+
+// addRTT add a round trip time measured from a report
+func (summary *QoSSummary) addRTT(rtt time.Duration) {
+	summary.rttSamples++
+	summary.totalRTT += rtt
+	summary.AvgRTT = summary.totalRTT / time.Duration(summary.rttSamples)
+	if rtt > summary.MaxRTT {
+		summary.MaxRTT = rtt
+	}
+}
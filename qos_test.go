@@ -0,0 +1,75 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestMonitorQoS(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		channel := map[string]string{"Channel": "PJSIP/100-01", "Uniqueid": "1.1", "Linkedid": "1.1"}
+		report := func(ev map[string]string) map[string]string {
+			for k, v := range channel {
+				ev[k] = v
+			}
+			return ev
+		}
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			report(map[string]string{"Event": "RTCPSent", "ReportCount": "1", "Report0IAJitter": "80",
+				"Report0FractionLost": "0", "Report0CumulativeLost": "0"}),
+			report(map[string]string{"Event": "RTCPSent", "ReportCount": "1", "Report0IAJitter": "240",
+				"Report0FractionLost": "64", "Report0CumulativeLost": "12"}),
+			report(map[string]string{"Event": "RTCPReceived", "ReportCount": "1", "RTT": "0.040",
+				"Report0IAJitter": "160", "Report0FractionLost": "0", "Report0CumulativeLost": "0"}),
+			report(map[string]string{"Event": "RTCPReceived", "ReportCount": "0", "RTT": "0.060"}),
+			{"Event": "Hangup", "Channel": "SIP/200-02", "Uniqueid": "2.1"},
+			report(map[string]string{"Event": "Hangup", "Cause": "16"}),
+		}
+	})
+
+	summaries := make(chan QoSSummary, 2)
+	monitor := ami.MonitorQoS(QoSOptions{OnSummary: func(summary QoSSummary) {
+		summaries <- summary
+	}})
+	defer monitor.Stop()
+
+	if _, _, err := ami.Action(Params{"Action": "UserEvent", "UserEvent": "qos"}); err != nil {
+		t.Fatal(err)
+	}
+
+	var summary QoSSummary
+	select {
+	case summary = <-summaries:
+	case <-time.After(5 * time.Second):
+		t.Fatal("summary not emitted")
+	}
+
+	if summary.UniqueID != "1.1" || summary.Channel != "PJSIP/100-01" || summary.End.Before(summary.Start) {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	inbound := summary.Inbound
+	if inbound.Reports != 2 || inbound.AvgJitter != 20*time.Millisecond || inbound.MaxJitter != 30*time.Millisecond ||
+		inbound.MaxLoss != 25 || inbound.AvgLoss != 12.5 || inbound.Lost != 12 {
+		t.Fatalf("unexpected inbound %+v", inbound)
+	}
+	if summary.Outbound.Reports != 1 || summary.Outbound.MaxJitter != 20*time.Millisecond {
+		t.Fatalf("unexpected outbound %+v", summary.Outbound)
+	}
+	if summary.AvgRTT != 50*time.Millisecond || summary.MaxRTT != 60*time.Millisecond {
+		t.Fatalf("unexpected rtt %s %s", summary.AvgRTT, summary.MaxRTT)
+	}
+	if len(monitor.Active()) != 0 {
+		t.Fatal("channel kept after hangup")
+	}
+}
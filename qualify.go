@@ -0,0 +1,413 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultQualifyInterval = time.Minute
+	defaultQualifyTimeout  = 5 * time.Second
+	defaultQualifyWindow   = 10
+	// defaultQualifyConcurrency actions of a round in flight at once
+	defaultQualifyConcurrency = 10
+	// peersKind kind of the peers qualified on a StateStore
+	peersKind = "peers"
+)
+
+// QualifyOptions of a QualifyScheduler
+type QualifyOptions struct {
+	// Interval between rounds of qualify, a minute when zero
+	Interval time.Duration
+	// Timeout given to the qualify before the latency is read from the
+	// channel driver, 5 seconds when zero
+	Timeout time.Duration
+	// Threshold of latency to alert a peer as lagged, no alert when zero
+	Threshold time.Duration
+	// Window samples kept to compute the trend, 10 when zero
+	Window int
+	// Concurrency actions of a round sent at once, 10 when zero
+	Concurrency int
+	// OnAlert called when a peer becomes lagged, unreachable or recovers
+	OnAlert func(QualifyAlert)
+}
+
+// QualifyAlert a change of the state of a peer qualified
+type QualifyAlert struct {
+	Peer string
+	// State Reachable, Lagged or Unreachable
+	State   string
+	Latency time.Duration
+	Average time.Duration
+}
+
+// QualifyStats latency of a peer qualified
+type QualifyStats struct {
+	Peer string
+	// State Reachable, Lagged or Unreachable, empty until qualified
+	State   string
+	Last    time.Duration
+	Average time.Duration
+	Min     time.Duration
+	Max     time.Duration
+	// Trend the average of the newer half of the samples minus the older,
+	// positive when the latency grows
+	Trend   time.Duration
+	Samples int
+}
+
+// storedPeer state of a peer qualified kept on a StateStore
+type storedPeer struct {
+	State   string
+	Samples []time.Duration
+}
+
+// QualifyScheduler qualify SIP peers and PJSIP endpoints periodically,
+// for servers where the qualify of Asterisk is disabled. The latency is read
+// with SIPshowpeer and a single PJSIPShowContacts by round, the status
+// events are raised only when the reachability changes
+type QualifyScheduler struct {
+	client *AMIClient
+	opts   QualifyOptions
+	mutex  *sync.Mutex
+	once   *sync.Once
+	done   chan struct{}
+
+	peers   []string
+	state   map[string]string
+	samples map[string][]time.Duration
+	// store shared with other schedulers, nil keep the peers in memory
+	store StateStore
+	// writer of the changes to store
+	writer *storeWriter
+}
+
+// QualifyPeers start qualifying the peers, given as SIP/name for chan_sip
+// and PJSIP/name for PJSIP endpoints, until Stop
+func (client *AMIClient) QualifyPeers(peers []string, opts QualifyOptions) *QualifyScheduler {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultQualifyInterval
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultQualifyTimeout
+	}
+	if opts.Window <= 0 {
+		opts.Window = defaultQualifyWindow
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = defaultQualifyConcurrency
+	}
+
+	scheduler := &QualifyScheduler{
+		client:  client,
+		opts:    opts,
+		mutex:   new(sync.Mutex),
+		once:    new(sync.Once),
+		done:    make(chan struct{}),
+		peers:   append([]string(nil), peers...),
+		state:   make(map[string]string),
+		samples: make(map[string][]time.Duration),
+		store:   client.stateStoreOf(),
+	}
+	if scheduler.store != nil {
+		if stored, err := scheduler.stored(nil); err != nil {
+			client.logf("gami: load peers: %s", err)
+		} else {
+			for peer, state := range stored {
+				scheduler.state[peer] = state.State
+				scheduler.samples[peer] = state.Samples
+			}
+		}
+		scheduler.writer = newStoreWriter(client, scheduler.store, peersKind)
+	}
+
+	go scheduler.run()
+	return scheduler
+}
+
+// Stats return the latency of the peers ordered by name, read from the
+// StateStore when there is one with the changes not written yet applied
+func (scheduler *QualifyScheduler) Stats() []QualifyStats {
+	var stored map[string]*storedPeer
+	if scheduler.store != nil {
+		var err error
+		// the store is read without holding the scheduler
+		if stored, err = scheduler.stored(scheduler.writer.unwritten()); err != nil {
+			scheduler.client.logf("gami: load peers: %s", err)
+		}
+	}
+
+	scheduler.mutex.Lock()
+	defer scheduler.mutex.Unlock()
+
+	stats := make([]QualifyStats, 0, len(scheduler.peers))
+	for _, peer := range scheduler.peers {
+		if stored == nil {
+			stats = append(stats, peerStats(peer, scheduler.state[peer], scheduler.samples[peer]))
+			continue
+		}
+		state := stored[peer]
+		if state == nil {
+			state = new(storedPeer)
+		}
+		stats = append(stats, peerStats(peer, state.State, state.Samples))
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Peer < stats[j].Peer
+	})
+	return stats
+}
+
+// Stop qualifying the peers
+func (scheduler *QualifyScheduler) Stop() {
+	scheduler.once.Do(func() {
+		close(scheduler.done)
+		if scheduler.writer != nil {
+			scheduler.writer.stop()
+		}
+	})
+}
+
+func (scheduler *QualifyScheduler) run() {
+	ticker := time.NewTicker(scheduler.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		scheduler.round()
+
+		select {
+		case <-scheduler.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// round qualify every peer, at most Concurrency at once, and record the
+// latencies once the qualifies had Timeout to complete. The PJSIP contacts
+// are listed once for all the endpoints
+func (scheduler *QualifyScheduler) round() {
+	mutex := new(sync.Mutex)
+	var qualified []string
+	scheduler.each(scheduler.peers, func(peer string) {
+		if err := scheduler.qualify(peer); err != nil {
+			scheduler.client.logf("gami: qualify %s: %s", peer, err)
+			scheduler.record(peer, -1)
+			return
+		}
+		mutex.Lock()
+		qualified = append(qualified, peer)
+		mutex.Unlock()
+	})
+	if len(qualified) == 0 {
+		return
+	}
+
+	timer := time.NewTimer(scheduler.opts.Timeout)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-scheduler.done:
+		return
+	}
+
+	var peers, endpoints []string
+	for _, peer := range qualified {
+		if strings.HasPrefix(peer, "PJSIP/") {
+			endpoints = append(endpoints, peer)
+		} else {
+			peers = append(peers, peer)
+		}
+	}
+
+	if len(endpoints) > 0 {
+		events, err := scheduler.client.listAction(Params{"Action": "PJSIPShowContacts"}, "ContactListComplete")
+		if err != nil {
+			scheduler.client.logf("gami: qualify PJSIP contacts: %s", err)
+		}
+		for _, endpoint := range endpoints {
+			scheduler.record(endpoint, contactsLatency(events, strings.TrimPrefix(endpoint, "PJSIP/")))
+		}
+	}
+
+	scheduler.each(peers, func(peer string) {
+		resp, err := scheduler.client.syncAction(Params{"Action": "SIPshowpeer", "Peer": strings.TrimPrefix(peer, "SIP/")})
+		if err != nil {
+			scheduler.client.logf("gami: qualify %s: %s", peer, err)
+			scheduler.record(peer, -1)
+			return
+		}
+		scheduler.record(peer, peerLatency(resp.Params["Status"]))
+	})
+}
+
+// each call fn with the peers, at most Concurrency at once
+func (scheduler *QualifyScheduler) each(peers []string, fn func(peer string)) {
+	slots := make(chan struct{}, scheduler.opts.Concurrency)
+	var wg sync.WaitGroup
+	for _, peer := range peers {
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			fn(peer)
+		}(peer)
+	}
+	wg.Wait()
+}
+
+// qualify send the qualify of the peer
+func (scheduler *QualifyScheduler) qualify(peer string) error {
+	p := Params{"Action": "SIPqualifypeer", "Peer": strings.TrimPrefix(peer, "SIP/")}
+	if strings.HasPrefix(peer, "PJSIP/") {
+		p = Params{"Action": "PJSIPQualify", "Endpoint": strings.TrimPrefix(peer, "PJSIP/")}
+	}
+	_, err := scheduler.client.syncAction(p)
+	return err
+}
+
+// record the latency of the peer, negative when unreachable, alerting the
+// changes of state
+func (scheduler *QualifyScheduler) record(peer string, latency time.Duration) {
+	scheduler.mutex.Lock()
+	state := "Unreachable"
+	if latency >= 0 {
+		state = "Reachable"
+		if scheduler.opts.Threshold > 0 && latency > scheduler.opts.Threshold {
+			state = "Lagged"
+		}
+		samples := append(scheduler.samples[peer], latency)
+		if len(samples) > scheduler.opts.Window {
+			samples = samples[len(samples)-scheduler.opts.Window:]
+		}
+		scheduler.samples[peer] = samples
+	}
+
+	previous := scheduler.state[peer]
+	scheduler.state[peer] = state
+	stats := peerStats(peer, state, scheduler.samples[peer])
+	scheduler.save(peer)
+	scheduler.mutex.Unlock()
+
+	// a peer reachable from the start is not alerted
+	if state == previous || (previous == "" && state == "Reachable") || scheduler.opts.OnAlert == nil {
+		return
+	}
+	alert := QualifyAlert{Peer: peer, State: state, Average: stats.Average}
+	if latency >= 0 {
+		alert.Latency = latency
+	}
+	scheduler.opts.OnAlert(alert)
+}
+
+// stored read the peers of the scheduler on the StateStore with the
+// changes unwritten applied
+func (scheduler *QualifyScheduler) stored(unwritten map[string][]byte) (map[string]*storedPeer, error) {
+	values, err := scheduler.store.List(peersKind)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make(map[string]*storedPeer, len(scheduler.peers))
+	for _, peer := range scheduler.peers {
+		value, ok := unwritten[peer]
+		if !ok {
+			value = values[peer]
+		}
+		if value == nil {
+			continue
+		}
+		state := new(storedPeer)
+		if err := json.Unmarshal(value, state); err != nil {
+			scheduler.client.logf("gami: load peer %s: %s", peer, err)
+			continue
+		}
+		peers[peer] = state
+	}
+	return peers, nil
+}
+
+// save queue the write of the peer to the StateStore, if any, the mutex
+// must be held
+func (scheduler *QualifyScheduler) save(peer string) {
+	if scheduler.writer == nil {
+		return
+	}
+	value, err := json.Marshal(storedPeer{State: scheduler.state[peer], Samples: scheduler.samples[peer]})
+	if err != nil {
+		scheduler.client.logf("gami: store peer %s: %s", peer, err)
+		return
+	}
+	scheduler.writer.put(peer, value)
+}
+
+// peerStats compute the stats of the peer from its state and samples
+func peerStats(peer, state string, samples []time.Duration) QualifyStats {
+	stats := QualifyStats{Peer: peer, State: state, Samples: len(samples)}
+	if len(samples) == 0 {
+		return stats
+	}
+
+	stats.Last = samples[len(samples)-1]
+	stats.Min, stats.Max = samples[0], samples[0]
+	for _, sample := range samples {
+		if sample < stats.Min {
+			stats.Min = sample
+		}
+		if sample > stats.Max {
+			stats.Max = sample
+		}
+	}
+	stats.Average = averageDuration(samples)
+	if half := len(samples) / 2; half > 0 {
+		stats.Trend = averageDuration(samples[len(samples)-half:]) - averageDuration(samples[:half])
+	}
+	return stats
+}
+
+func averageDuration(samples []time.Duration) time.Duration {
+	var total time.Duration
+	for _, sample := range samples {
+		total += sample
+	}
+	return total / time.Duration(len(samples))
+}
+
+// peerLatency parse the Status of SIPshowpeer, as OK (12 ms) or
+// LAGGED (250 ms), negative when unreachable or not monitored
+func peerLatency(status string) time.Duration {
+	open, end := strings.Index(status, "("), strings.Index(status, " ms)")
+	if open < 0 || end < open {
+		return -1
+	}
+	ms, err := strconv.Atoi(strings.TrimSpace(status[open+1 : end]))
+	if err != nil {
+		return -1
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// contactsLatency the best round trip of the reachable contacts of endpoint
+// listed by PJSIPShowContacts, negative when none is reachable
+func contactsLatency(events []*AMIEvent, endpoint string) time.Duration {
+	latency := time.Duration(-1)
+	for _, ev := range events {
+		if ev.ID != "ContactList" || ev.Get("Endpoint") != endpoint || !strings.EqualFold(ev.Get("Status"), "Reachable") {
+			continue
+		}
+		roundtrip := time.Duration(ev.GetInt("RoundtripUsec")) * time.Microsecond
+		if latency < 0 || roundtrip < latency {
+			latency = roundtrip
+		}
+	}
+	return latency
+}
@@ -0,0 +1,206 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestQualifyPeers(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	// the qualify raises no event while the reachability doesn't change,
+	// the latency is read from the channel driver
+	var mutex sync.Mutex
+	status := map[string]string{"100": "OK (20 ms)", "300": "UNREACHABLE"}
+	srv.Mock("SIPshowpeer", func(params textproto.MIMEHeader) map[string]string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid"),
+			"ObjectName": params.Get("Peer"), "Status": status[params.Get("Peer")]}
+	})
+	srv.MockList("PJSIPShowContacts", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "ContactList", "ActionID": id, "Endpoint": "200", "Status": "Unreachable", "RoundtripUsec": "0"},
+			{"Event": "ContactList", "ActionID": id, "Endpoint": "200", "Status": "Reachable", "RoundtripUsec": "250000"},
+			{"Event": "ContactList", "ActionID": id, "Endpoint": "400", "Status": "Reachable", "RoundtripUsec": "1000"},
+			{"Event": "ContactListComplete", "ActionID": id, "EventList": "Complete", "ListItems": "3"},
+		}
+	})
+
+	alerts := make(chan QualifyAlert, 10)
+	scheduler := ami.QualifyPeers([]string{"SIP/100", "PJSIP/200", "SIP/300"}, QualifyOptions{
+		Interval:  50 * time.Millisecond,
+		Timeout:   100 * time.Millisecond,
+		Threshold: 100 * time.Millisecond,
+		OnAlert: func(alert QualifyAlert) {
+			alerts <- alert
+		},
+	})
+	defer scheduler.Stop()
+
+	states := make(map[string]QualifyAlert)
+	timeout := time.After(5 * time.Second)
+	for len(states) < 2 {
+		select {
+		case alert := <-alerts:
+			states[alert.Peer] = alert
+		case <-timeout:
+			t.Fatalf("missing alerts %+v", states)
+		}
+	}
+	if states["PJSIP/200"].State != "Lagged" || states["PJSIP/200"].Latency != 250*time.Millisecond {
+		t.Fatalf("unexpected alert %+v", states["PJSIP/200"])
+	}
+	if states["SIP/300"].State != "Unreachable" {
+		t.Fatalf("unexpected alert %+v", states["SIP/300"])
+	}
+
+	mutex.Lock()
+	status["100"] = "LAGGED (120 ms)"
+	mutex.Unlock()
+	select {
+	case alert := <-alerts:
+		if alert.Peer != "SIP/100" || alert.State != "Lagged" || alert.Latency != 120*time.Millisecond {
+			t.Fatalf("unexpected alert %+v", alert)
+		}
+	case <-timeout:
+		t.Fatal("missing alert of SIP/100")
+	}
+
+	stats := scheduler.Stats()
+	if len(stats) != 3 || stats[1].Peer != "SIP/100" || stats[1].Min != 20*time.Millisecond ||
+		stats[1].Max != 120*time.Millisecond || stats[1].Trend <= 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	if stats[0].Peer != "PJSIP/200" || stats[0].Samples == 0 ||
+		stats[2].State != "Unreachable" || stats[2].Samples != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestPeerLatency(t *testing.T) {
+	for status, latency := range map[string]time.Duration{
+		"OK (12 ms)":      12 * time.Millisecond,
+		"LAGGED (250 ms)": 250 * time.Millisecond,
+		"UNREACHABLE":     -1,
+		"Unmonitored":     -1,
+		"":                -1,
+	} {
+		if got := peerLatency(status); got != latency {
+			t.Errorf("%q: expected %s, got %s", status, latency, got)
+		}
+	}
+}
+
+func TestQualifyContactsOncePerRound(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	var mutex sync.Mutex
+	listings := 0
+	srv.MockList("PJSIPShowContacts", func(params textproto.MIMEHeader) []map[string]string {
+		mutex.Lock()
+		listings++
+		mutex.Unlock()
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "ContactList", "ActionID": id, "Endpoint": "200", "Status": "Reachable", "RoundtripUsec": "1000"},
+			{"Event": "ContactList", "ActionID": id, "Endpoint": "201", "Status": "Reachable", "RoundtripUsec": "2000"},
+			{"Event": "ContactListComplete", "ActionID": id, "EventList": "Complete", "ListItems": "2"},
+		}
+	})
+
+	endpoints := []string{"PJSIP/200", "PJSIP/201", "PJSIP/202", "PJSIP/203", "PJSIP/204"}
+	scheduler := ami.QualifyPeers(endpoints, QualifyOptions{
+		Interval:    time.Hour,
+		Timeout:     50 * time.Millisecond,
+		Concurrency: 2,
+	})
+	defer scheduler.Stop()
+
+	timeout := time.After(5 * time.Second)
+	for {
+		qualified := 0
+		for _, stats := range scheduler.Stats() {
+			if stats.State != "" {
+				qualified++
+			}
+		}
+		if qualified == len(endpoints) {
+			break
+		}
+		select {
+		case <-timeout:
+			t.Fatalf("peers not qualified %+v", scheduler.Stats())
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if listings != 1 {
+		t.Fatal("expected the contacts listed once for the round, got", listings)
+	}
+	stats := scheduler.Stats()
+	if stats[1].Peer != "PJSIP/201" || stats[1].Last != 2*time.Millisecond || stats[2].State != "Unreachable" {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestQualifyStateStore(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	// lagged before a restart, the store is never written
+	store := &blockingStore{memoryStore: memoryStore{values: map[string]map[string][]byte{"peers": {
+		"SIP/100": []byte(`{"State":"Lagged","Samples":[150000000]}`),
+	}}}, release: make(chan struct{})}
+	ami.Configure(UseStateStore(store))
+	srv.Mock("SIPshowpeer", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid"),
+			"ObjectName": params.Get("Peer"), "Status": "OK (20 ms)"}
+	})
+
+	alerts := make(chan QualifyAlert, 10)
+	scheduler := ami.QualifyPeers([]string{"SIP/100"}, QualifyOptions{
+		Interval:  time.Hour,
+		Timeout:   10 * time.Millisecond,
+		Threshold: 100 * time.Millisecond,
+		OnAlert: func(alert QualifyAlert) {
+			alerts <- alert
+		},
+	})
+	defer scheduler.Stop()
+
+	select {
+	case alert := <-alerts:
+		if alert.State != "Reachable" || alert.Latency != 20*time.Millisecond {
+			t.Fatalf("unexpected alert %+v", alert)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("missing the recovery of SIP/100")
+	}
+
+	// the write is still hung on the store
+	stats := scheduler.Stats()
+	if stats[0].State != "Reachable" || stats[0].Samples != 2 || stats[0].Max != 150*time.Millisecond {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	close(store.release)
+}
@@ -0,0 +1,88 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrReadOnlyAction returned by Action for the actions that could change the
+// state of the server on a ReadOnly client
+var ErrReadOnlyAction = errors.New("Action not allowed on read-only client")
+
+// readOnlyActions actions that don't change the state of the server, in lower case
+var readOnlyActions = map[string]bool{
+	"login":                          true,
+	"logoff":                         true,
+	"challenge":                      true,
+	"ping":                           true,
+	"events":                         true,
+	"waitevent":                      true,
+	"listcommands":                   true,
+	"modulecheck":                    true,
+	"corestatus":                     true,
+	"coresettings":                   true,
+	"coreshowchannels":               true,
+	"status":                         true,
+	"getvar":                         true,
+	"getconfig":                      true,
+	"getconfigjson":                  true,
+	"listcategories":                 true,
+	"showdialplan":                   true,
+	"extensionstate":                 true,
+	"extensionstatelist":             true,
+	"presencestate":                  true,
+	"presencestatelist":              true,
+	"devicestatelist":                true,
+	"mailboxstatus":                  true,
+	"mailboxcount":                   true,
+	"voicemailuserslist":             true,
+	"queuestatus":                    true,
+	"queuesummary":                   true,
+	"agents":                         true,
+	"bridgelist":                     true,
+	"bridgeinfo":                     true,
+	"bridgetechnologylist":           true,
+	"confbridgelist":                 true,
+	"confbridgelistrooms":            true,
+	"meetmelist":                     true,
+	"meetmelistrooms":                true,
+	"parkedcalls":                    true,
+	"parkinglots":                    true,
+	"dbget":                          true,
+	"sippeers":                       true,
+	"sipshowpeer":                    true,
+	"sipshowregistry":                true,
+	"iaxpeers":                       true,
+	"iaxpeerlist":                    true,
+	"iaxregistry":                    true,
+	"pjsipshowendpoints":             true,
+	"pjsipshowendpoint":              true,
+	"pjsipshowcontacts":              true,
+	"pjsipshowaors":                  true,
+	"pjsipshowauths":                 true,
+	"pjsipshowregistrationsinbound":  true,
+	"pjsipshowregistrationsoutbound": true,
+	"faxsessions":                    true,
+	"faxstats":                       true,
+}
+
+// ReadOnly monitoring client, Login enable the events of the session and
+// Action refuses the actions that can change the state of the server, only
+// queries like Ping, Status or CoreShowChannels are sent.
+//
+// The actions refused are never registered to wait a response. The ones
+// allowed, Login and Logoff included, are sent as on any client: Login
+// reads its response to report a failed authentication, and the response of
+// Logoff is discarded with the pending actions when the connection closes
+func ReadOnly(c *AMIClient) {
+	c.readOnly = true
+}
+
+// allowedReadOnly check if the action can be sent by a read-only client
+func allowedReadOnly(action string) bool {
+	return readOnlyActions[strings.ToLower(action)]
+}
@@ -0,0 +1,69 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	srv := newAmiServer()
+	defer srv.Close()
+	ami, err := Dial(srv.Addr, ReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	srv.Mock("Login", func(params textproto.MIMEHeader) map[string]string {
+		if params.Get("Events") != "on" {
+			return map[string]string{"Response": "Error", "ActionID": params.Get("Actionid"),
+				"Message": "Events not enabled"}
+		}
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid")}
+	})
+
+	if err := ami.Login("admin", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ami.Action(Params{"Action": "Hangup", "Channel": "SIP/100-01"}); err != ErrReadOnlyAction {
+		t.Fatal("expected Hangup refused, got", err)
+	}
+	if _, _, err := ami.Action(Params{"Action": "originate", "ActionID": "refused"}); err != ErrReadOnlyAction {
+		t.Fatal("expected Originate refused, got", err)
+	}
+	if _, ok := ami.response.get("refused"); ok {
+		t.Fatal("the action refused waits a response")
+	}
+
+	response, _, err := ami.Action(Params{"Action": "Ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-response
+}
+
+func TestReadOnlyLoginFailed(t *testing.T) {
+	srv := newAmiServer()
+	defer srv.Close()
+	ami, err := Dial(srv.Addr, ReadOnly)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	// the response of Login is still registered to read the failure
+	srv.Mock("Login", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{"Response": "Error", "ActionID": params.Get("Actionid"),
+			"Message": "Authentication failed"}
+	})
+	if err := ami.Login("admin", "wrong"); err == nil {
+		t.Fatal("expected the login refused")
+	}
+}
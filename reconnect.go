@@ -0,0 +1,120 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"io"
+	"net"
+	"net/textproto"
+	"sync/atomic"
+)
+
+// reconnectRequest a Reconnect waiting for ownConnection
+type reconnectRequest struct {
+	// generation of the connection when Reconnect was called
+	generation uint64
+	result     chan error
+}
+
+// Reconnect replace the connection and log in again with the credentials
+// of Login or the Authenticator, a failure is also put on NetError. It's
+// safe from any goroutine and while Run reads: the reader moves to the new
+// connection, and callers racing for the same broken connection share one
+// reconnection and its result
+func (client *AMIClient) Reconnect() error {
+	client.mutexAsyncAction.RLock()
+	req := reconnectRequest{generation: client.generation, result: make(chan error, 1)}
+	client.mutexAsyncAction.RUnlock()
+
+	select {
+	case client.reconnects <- req:
+	case <-client.closed:
+		return errClientClosed
+	}
+
+	select {
+	case err := <-req.result:
+		return err
+	case <-client.closed:
+		return errClientClosed
+	}
+}
+
+// ownConnection serve the Reconnect requests one at a time until Close, a
+// request made before the last reconnection gets its result
+func (client *AMIClient) ownConnection() {
+	var last error
+	for {
+		select {
+		case <-client.closed:
+			return
+		case req := <-client.reconnects:
+			client.mutexAsyncAction.RLock()
+			generation := client.generation
+			client.mutexAsyncAction.RUnlock()
+
+			if req.generation < generation {
+				req.result <- last
+				continue
+			}
+			last = client.reconnect()
+			req.result <- last
+		}
+	}
+}
+
+// reconnect dial a new connection and authenticate on it
+func (client *AMIClient) reconnect() error {
+	if err := client.NewConn(); err != nil {
+		select {
+		case client.NetError <- err:
+		default:
+		}
+		return err
+	}
+
+	if client.authenticator == nil {
+		return nil
+	}
+	return client.Authenticate()
+}
+
+// setConnection replace the connection, closing the previous one, and wake
+// up the reader waiting for it. The actions sent on the previous one fail
+func (client *AMIClient) setConnection(connRaw io.ReadWriteCloser, conn *textproto.Conn) {
+	client.mutexAsyncAction.Lock()
+	replaced := client.connRaw != nil
+	if replaced {
+		client.connRaw.Close()
+	}
+	client.connRaw, client.conn = connRaw, conn
+	client.generation++
+	atomic.StoreInt32(&client.sessionDown, 0)
+	client.touch()
+	close(client.connChanged)
+	client.connChanged = make(chan struct{})
+	client.mutexAsyncAction.Unlock()
+
+	if replaced {
+		client.connectionLost()
+	}
+}
+
+// connection return the connection to read, its generation and the chan
+// closed when it's replaced
+func (client *AMIClient) connection() (*textproto.Conn, uint64, chan struct{}) {
+	client.mutexAsyncAction.RLock()
+	defer client.mutexAsyncAction.RUnlock()
+	return client.conn, client.generation, client.connChanged
+}
+
+// isNetError check if the error reading a frame is a broken connection
+func isNetError(err error) bool {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	_, ok := err.(net.Error)
+	return ok
+}
@@ -0,0 +1,199 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countLogins mock Login counting the logins
+func countLogins(srv *amiServer) func() int {
+	var mutex sync.Mutex
+	logins := 0
+	srv.Mock("Login", func(params textproto.MIMEHeader) map[string]string {
+		mutex.Lock()
+		logins++
+		mutex.Unlock()
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid")}
+	})
+	return func() int {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return logins
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	logins := countLogins(srv)
+
+	if err := ami.Login("admin", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the reader isn't waiting for a new connection
+	done := make(chan error, 1)
+	go func() {
+		done <- ami.Reconnect()
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Reconnect blocked on a healthy connection")
+	}
+	if logins() != 2 {
+		t.Fatalf("expected login again, got %d logins", logins())
+	}
+	if _, err := ami.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the connection is lost
+	srv.Drop()
+	select {
+	case <-ami.NetError:
+	case <-time.After(5 * time.Second):
+		t.Fatal("lost connection not reported")
+	}
+	if err := ami.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ami.Ping(); err != nil {
+		t.Fatal(err)
+	}
+	if logins() != 3 {
+		t.Fatalf("expected 3 logins, got %d", logins())
+	}
+}
+
+func TestReconnectShared(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	logins := countLogins(srv)
+
+	if err := ami.Login("admin", "admin"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, generation, _ := ami.connection()
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// callers that saw the same connection
+			req := reconnectRequest{generation: generation, result: make(chan error, 1)}
+			ami.reconnects <- req
+			if err := <-req.result; err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, current, _ := ami.connection(); current != generation+1 {
+		t.Fatalf("expected one reconnection, got %d", current-generation)
+	}
+	if logins() != 2 {
+		t.Fatalf("expected 2 logins, got %d", logins())
+	}
+}
+
+func TestReconnectClosed(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	ami.Close()
+
+	if err := ami.Reconnect(); err != errClientClosed {
+		t.Fatal("expected client closed, got", err)
+	}
+}
+
+func TestConnectionLostFailsPending(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	// without response
+	srv.MockList("Hold", func(params textproto.MIMEHeader) []map[string]string {
+		return nil
+	})
+	// the list never completes
+	srv.MockList("CoreShowChannels", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "CoreShowChannel", "ActionID": id, "Channel": "SIP/100-01"},
+		}
+	})
+
+	actionErr := make(chan error, 1)
+	go func() {
+		_, err := ami.syncAction(Params{"Action": "Hold"})
+		actionErr <- err
+	}()
+	listErr := make(chan error, 1)
+	go func() {
+		_, err := ami.listAction(Params{"Action": "CoreShowChannels"}, "CoreShowChannelsComplete")
+		listErr <- err
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	srv.Drop()
+	for name, errs := range map[string]chan error{"action": actionErr, "list": listErr} {
+		select {
+		case err := <-errs:
+			if err == nil || err.Error() != connectionLostMessage {
+				t.Fatalf("expected the %s failed by the lost connection, got %v", name, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s blocked on a lost connection", name)
+		}
+	}
+}
+
+func TestReconnectFailsPending(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	srv.MockList("Hold", func(params textproto.MIMEHeader) []map[string]string {
+		return nil
+	})
+
+	actionErr := make(chan error, 1)
+	go func() {
+		_, err := ami.syncAction(Params{"Action": "Hold"})
+		actionErr <- err
+	}()
+	time.Sleep(200 * time.Millisecond)
+
+	if err := ami.Reconnect(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-actionErr:
+		if err == nil || err.Error() != connectionLostMessage {
+			t.Fatal("expected the action failed by the new connection, got", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("action blocked on a replaced connection")
+	}
+	if _, err := ami.Ping(); err != nil {
+		t.Fatal(err)
+	}
+}
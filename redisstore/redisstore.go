@@ -0,0 +1,243 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+// Package redisstore implements gami.StateStore on Redis, so the trackers
+// of many instances of an application share one view of the server.
+//
+// Every kind is a hash named by the prefix and the kind, like
+// "gami:channels", with a field by key. The store speaks RESP on a single
+// connection, dialed on first use and again after a network error.
+package redisstore
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultPrefix of the hashes when Options.Prefix is empty
+const defaultPrefix = "gami:"
+
+// defaultTimeout of the commands when Options.Timeout is zero
+const defaultTimeout = 5 * time.Second
+
+var errUnexpectedReply = errors.New("Unexpected reply")
+
+// Options of the connection to Redis
+type Options struct {
+	// Password for AUTH, empty skip it
+	Password string
+	// DB selected after connecting
+	DB int
+	// Prefix of the hashes, "gami:" when empty, a prefix by Asterisk
+	// server keep their states apart
+	Prefix string
+	// Timeout to dial and for every command, 5 seconds when zero, negative
+	// wait forever
+	Timeout time.Duration
+}
+
+// Store a gami.StateStore on Redis
+type Store struct {
+	addr  string
+	opts  Options
+	mutex *sync.Mutex
+
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// New return a store on the Redis server at addr, opts can be nil
+func New(addr string, opts *Options) *Store {
+	store := &Store{addr: addr, mutex: new(sync.Mutex)}
+	if opts != nil {
+		store.opts = *opts
+	}
+	if store.opts.Prefix == "" {
+		store.opts.Prefix = defaultPrefix
+	}
+	if store.opts.Timeout == 0 {
+		store.opts.Timeout = defaultTimeout
+	}
+	return store
+}
+
+// Put store value under key of the hash of kind
+func (store *Store) Put(kind, key string, value []byte) error {
+	_, err := store.do("HSET", store.opts.Prefix+kind, key, string(value))
+	return err
+}
+
+// Delete remove key from the hash of kind
+func (store *Store) Delete(kind, key string) error {
+	_, err := store.do("HDEL", store.opts.Prefix+kind, key)
+	return err
+}
+
+// List return the fields of the hash of kind
+func (store *Store) List(kind string) (map[string][]byte, error) {
+	reply, err := store.do("HGETALL", store.opts.Prefix+kind)
+	if err != nil {
+		return nil, err
+	}
+	fields, ok := reply.([]interface{})
+	if !ok || len(fields)%2 != 0 {
+		return nil, errUnexpectedReply
+	}
+
+	values := make(map[string][]byte, len(fields)/2)
+	for i := 0; i < len(fields); i += 2 {
+		key, ok := fields[i].([]byte)
+		value, okValue := fields[i+1].([]byte)
+		if !ok || !okValue {
+			return nil, errUnexpectedReply
+		}
+		values[string(key)] = value
+	}
+	return values, nil
+}
+
+// Close the connection to Redis, the next command dials again
+func (store *Store) Close() error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	return store.drop()
+}
+
+// do send the command and read its reply, the connection is dropped on
+// network errors
+func (store *Store) do(args ...string) (interface{}, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+
+	if store.conn == nil {
+		if err := store.connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	reply, err := store.command(args...)
+	if _, ok := err.(redisError); !ok && err != nil {
+		store.drop()
+	}
+	return reply, err
+}
+
+// connect dial Redis, authenticating and selecting the DB
+func (store *Store) connect() error {
+	var dialer net.Dialer
+	if store.opts.Timeout > 0 {
+		dialer.Timeout = store.opts.Timeout
+	}
+	conn, err := dialer.Dial("tcp", store.addr)
+	if err != nil {
+		return err
+	}
+	store.conn = conn
+	store.reader = bufio.NewReader(conn)
+
+	if store.opts.Password != "" {
+		if _, err := store.command("AUTH", store.opts.Password); err != nil {
+			store.drop()
+			return err
+		}
+	}
+	if store.opts.DB != 0 {
+		if _, err := store.command("SELECT", strconv.Itoa(store.opts.DB)); err != nil {
+			store.drop()
+			return err
+		}
+	}
+	return nil
+}
+
+// drop close the connection
+func (store *Store) drop() error {
+	if store.conn == nil {
+		return nil
+	}
+	err := store.conn.Close()
+	store.conn, store.reader = nil, nil
+	return err
+}
+
+// command write args as an array of bulk strings and read the reply
+func (store *Store) command(args ...string) (interface{}, error) {
+	if store.opts.Timeout > 0 {
+		store.conn.SetDeadline(time.Now().Add(store.opts.Timeout))
+	}
+
+	w := bufio.NewWriter(store.conn)
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return readReply(store.reader)
+}
+
+// redisError an error reply of Redis, the connection is still usable
+type redisError string
+
+func (err redisError) Error() string {
+	return string(err)
+}
+
+// readReply read a RESP reply, bulk strings are []byte, integers int64 and
+// arrays []interface{}, nil for null replies
+func readReply(r *bufio.Reader) (interface{}, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, errUnexpectedReply
+	}
+	kind, line := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, redisError(line)
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return data[:size], nil
+	case '*':
+		size, err := strconv.Atoi(line)
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, size)
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	}
+	return nil, errUnexpectedReply
+}
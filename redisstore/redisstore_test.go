@@ -0,0 +1,170 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package redisstore
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/googolgl/gami"
+)
+
+var _ gami.StateStore = (*Store)(nil)
+
+// fakeRedis answers the hash commands used by the store
+type fakeRedis struct {
+	listener net.Listener
+	mutex    sync.Mutex
+	hashes   map[string]map[string]string
+	commands []string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &fakeRedis{listener: listener, hashes: make(map[string]map[string]string)}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go srv.serve(conn)
+		}
+	}()
+	return srv
+}
+
+func (srv *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		reply, err := readReply(r)
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, arg := range reply.([]interface{}) {
+			args = append(args, string(arg.([]byte)))
+		}
+		fmt.Fprint(conn, srv.handle(args))
+	}
+}
+
+func (srv *fakeRedis) handle(args []string) string {
+	srv.mutex.Lock()
+	defer srv.mutex.Unlock()
+	srv.commands = append(srv.commands, args[0])
+
+	switch args[0] {
+	case "AUTH":
+		if args[1] != "secret" {
+			return "-WRONGPASS invalid password\r\n"
+		}
+		return "+OK\r\n"
+	case "SELECT":
+		return "+OK\r\n"
+	case "HSET":
+		if srv.hashes[args[1]] == nil {
+			srv.hashes[args[1]] = make(map[string]string)
+		}
+		srv.hashes[args[1]][args[2]] = args[3]
+		return ":1\r\n"
+	case "HDEL":
+		delete(srv.hashes[args[1]], args[2])
+		return ":1\r\n"
+	case "HGETALL":
+		reply := fmt.Sprintf("*%d\r\n", 2*len(srv.hashes[args[1]]))
+		for k, v := range srv.hashes[args[1]] {
+			reply += fmt.Sprintf("$%d\r\n%s\r\n$%d\r\n%s\r\n", len(k), k, len(v), v)
+		}
+		return reply
+	}
+	return "-ERR unknown command\r\n"
+}
+
+func TestStore(t *testing.T) {
+	srv := newFakeRedis(t)
+	defer srv.listener.Close()
+
+	store := New(srv.listener.Addr().String(), &Options{Password: "secret", DB: 2, Prefix: "pbx1:"})
+	defer store.Close()
+
+	if err := store.Put("channels", "1.1", []byte(`{"Name":"SIP/100-01"}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Put("channels", "1.2", []byte("line\r\nbreak")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete("channels", "1.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	values, err := store.List("channels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(values) != 1 || string(values["1.2"]) != "line\r\nbreak" {
+		t.Fatalf("unexpected values %q", values)
+	}
+	if _, ok := srv.hashes["pbx1:channels"]; !ok {
+		t.Fatalf("expected prefixed hash, got %v", srv.hashes)
+	}
+	if srv.commands[0] != "AUTH" || srv.commands[1] != "SELECT" {
+		t.Fatalf("expected AUTH and SELECT first, got %v", srv.commands)
+	}
+
+	// dial again after the connection is lost
+	store.Close()
+	if values, err := store.List("peers"); err != nil || len(values) != 0 {
+		t.Fatalf("unexpected list %v %v", values, err)
+	}
+}
+
+func TestStoreWrongPassword(t *testing.T) {
+	srv := newFakeRedis(t)
+	defer srv.listener.Close()
+
+	store := New(srv.listener.Addr().String(), &Options{Password: "wrong"})
+	if err := store.Put("channels", "1.1", nil); err == nil || err.Error() != "WRONGPASS invalid password" {
+		t.Fatal("expected AUTH error, got", err)
+	}
+}
+
+func TestStoreTimeout(t *testing.T) {
+	// a Redis accepting connections but never answering
+	listener, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	store := New(listener.Addr().String(), &Options{Timeout: 100 * time.Millisecond})
+	start := time.Now()
+	if err := store.Put("channels", "1.1", nil); err == nil {
+		t.Fatal("expected timeout")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatal("command not bounded by the timeout", elapsed)
+	}
+	if New("localhost:6379", nil).opts.Timeout != defaultTimeout {
+		t.Fatal("expected default timeout")
+	}
+}
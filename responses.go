@@ -0,0 +1,207 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"time"
+)
+
+// PingResponse answer of Ping
+type PingResponse struct {
+	// Timestamp of the server when it answered
+	Timestamp time.Time
+	// RTT time since the action was written until the response was readed
+	RTT time.Duration
+}
+
+// CoreStatus status of the server
+type CoreStatus struct {
+	StartupTime  time.Time
+	ReloadTime   time.Time
+	CurrentCalls int
+}
+
+// Queue a queue of app_queue with its members and callers waiting
+type Queue struct {
+	Name             string
+	Strategy         string
+	Max              int
+	Calls            int
+	HoldTime         time.Duration
+	TalkTime         time.Duration
+	Completed        int
+	Abandoned        int
+	ServiceLevel     int
+	ServiceLevelPerf float64
+	Weight           int
+	Members          []QueueMember
+	Entries          []QueueEntry
+}
+
+// QueueMember a member (agent) of a queue
+type QueueMember struct {
+	Name       string
+	Interface  string
+	Membership string
+	Penalty    int
+	CallsTaken int
+	LastCall   time.Time
+	Status     int
+	Paused     bool
+}
+
+// QueueEntry a caller waiting on a queue
+type QueueEntry struct {
+	Position     int
+	Channel      string
+	UniqueID     string
+	CallerIDNum  string
+	CallerIDName string
+	Wait         time.Duration
+}
+
+// SIPPeer a peer of chan_sip
+type SIPPeer struct {
+	Name      string
+	IPAddress string
+	IPPort    int
+	Dynamic   bool
+	Status    string
+}
+
+// Ping the server and return the time it took
+func (client *AMIClient) Ping() (*PingResponse, error) {
+	start := time.Now()
+	resp, err := client.syncAction(Params{"Action": "Ping"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &PingResponse{Timestamp: parseTime(resp.Get("Timestamp")), RTT: time.Since(start)}, nil
+}
+
+// CoreStatus return the status of the server
+func (client *AMIClient) CoreStatus() (*CoreStatus, error) {
+	resp, err := client.syncAction(Params{"Action": "CoreStatus"})
+	if err != nil {
+		return nil, err
+	}
+
+	return &CoreStatus{
+		StartupTime:  parseTime(resp.Get("CoreStartupDate") + " " + resp.Get("CoreStartupTime")),
+		ReloadTime:   parseTime(resp.Get("CoreReloadDate") + " " + resp.Get("CoreReloadTime")),
+		CurrentCalls: parseInt(resp.Get("CoreCurrentCalls")),
+	}, nil
+}
+
+// QueueStatus return the queues with their members and callers, queue
+// filter them when not empty
+func (client *AMIClient) QueueStatus(queue string) ([]Queue, error) {
+	p := Params{"Action": "QueueStatus"}
+	if queue != "" {
+		p["Queue"] = queue
+	}
+
+	events, err := client.listAction(p, "QueueStatusComplete")
+	if err != nil {
+		return nil, err
+	}
+
+	var queues []Queue
+	byName := make(map[string]int)
+	for _, ev := range events {
+		switch ev.ID {
+		case "QueueParams":
+			byName[ev.Get("Queue")] = len(queues)
+			queues = append(queues, Queue{
+				Name:             ev.Get("Queue"),
+				Strategy:         ev.Get("Strategy"),
+				Max:              ev.GetInt("Max"),
+				Calls:            ev.GetInt("Calls"),
+				HoldTime:         ev.GetDuration("Holdtime"),
+				TalkTime:         ev.GetDuration("TalkTime"),
+				Completed:        ev.GetInt("Completed"),
+				Abandoned:        ev.GetInt("Abandoned"),
+				ServiceLevel:     ev.GetInt("ServiceLevel"),
+				ServiceLevelPerf: parseFloat(ev.Get("ServicelevelPerf")),
+				Weight:           ev.GetInt("Weight"),
+			})
+		case "QueueMember":
+			i, ok := byName[ev.Get("Queue")]
+			if !ok {
+				continue
+			}
+			iface := ev.Get("StateInterface")
+			if iface == "" {
+				iface = ev.Get("Location")
+			}
+			queues[i].Members = append(queues[i].Members, QueueMember{
+				Name:       ev.Get("Name"),
+				Interface:  iface,
+				Membership: ev.Get("Membership"),
+				Penalty:    ev.GetInt("Penalty"),
+				CallsTaken: ev.GetInt("CallsTaken"),
+				LastCall:   ev.GetTime("LastCall"),
+				Status:     ev.GetInt("Status"),
+				Paused:     ev.GetBool("Paused"),
+			})
+		case "QueueEntry":
+			i, ok := byName[ev.Get("Queue")]
+			if !ok {
+				continue
+			}
+			queues[i].Entries = append(queues[i].Entries, QueueEntry{
+				Position:     ev.GetInt("Position"),
+				Channel:      ev.Get("Channel"),
+				UniqueID:     ev.Get("Uniqueid"),
+				CallerIDNum:  ev.Get("CallerIDNum"),
+				CallerIDName: ev.Get("CallerIDName"),
+				Wait:         ev.GetDuration("Wait"),
+			})
+		}
+	}
+
+	return queues, nil
+}
+
+// SIPPeers return the peers of chan_sip
+func (client *AMIClient) SIPPeers() ([]SIPPeer, error) {
+	events, err := client.listAction(Params{"Action": "SIPpeers"}, "PeerlistComplete")
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make([]SIPPeer, 0, len(events))
+	for _, ev := range events {
+		if ev.ID != "PeerEntry" {
+			continue
+		}
+		peers = append(peers, SIPPeer{
+			Name:      ev.Get("ObjectName"),
+			IPAddress: ev.Get("IPaddress"),
+			IPPort:    ev.GetInt("IPport"),
+			Dynamic:   ev.GetBool("Dynamic"),
+			Status:    ev.Get("Status"),
+		})
+	}
+
+	return peers, nil
+}
+
+// GetVar return the value of the variable on the channel, global variable
+// when channel is empty
+func (client *AMIClient) GetVar(channel, variable string) (string, error) {
+	p := Params{"Action": "GetVar", "Variable": variable}
+	if channel != "" {
+		p["Channel"] = channel
+	}
+
+	resp, err := client.syncAction(p)
+	if err != nil {
+		return "", err
+	}
+
+	return resp.Get("Value"), nil
+}
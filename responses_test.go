@@ -0,0 +1,133 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestPingCoreStatusGetVar(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	srv.Mock("Ping", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid"),
+			"Ping": "Pong", "Timestamp": "1600000000.250000"}
+	})
+	srv.Mock("CoreStatus", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid"),
+			"CoreStartupDate": "2020-09-13", "CoreStartupTime": "10:00:00",
+			"CoreReloadDate": "2020-09-13", "CoreReloadTime": "11:30:00", "CoreCurrentCalls": "7"}
+	})
+	srv.Mock("GetVar", func(params textproto.MIMEHeader) map[string]string {
+		if params.Get("Channel") != "SIP/100-01" {
+			return map[string]string{"Response": "Error", "ActionID": params.Get("Actionid"),
+				"Message": "No such channel"}
+		}
+		return map[string]string{"Response": "Success", "ActionID": params.Get("Actionid"),
+			"Variable": params.Get("Variable"), "Value": "1234"}
+	})
+
+	ping, err := ami.Ping()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ping.Timestamp.Unix() != 1600000000 || ping.RTT <= 0 {
+		t.Fatalf("unexpected ping %+v", ping)
+	}
+
+	status, err := ami.CoreStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.CurrentCalls != 7 || status.StartupTime.Hour() != 10 || status.ReloadTime.Sub(status.StartupTime) != 90*time.Minute {
+		t.Fatalf("unexpected core status %+v", status)
+	}
+
+	if value, err := ami.GetVar("SIP/100-01", "ACCOUNTCODE"); err != nil || value != "1234" {
+		t.Fatal("unexpected GetVar", value, err)
+	}
+	if _, err := ami.GetVar("SIP/200-01", "ACCOUNTCODE"); err == nil || err.Error() != "No such channel" {
+		t.Fatal("expected error of GetVar", err)
+	}
+}
+
+func TestQueueStatus(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.MockList("QueueStatus", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start", "Message": "Queue status will follow"},
+			{"Event": "QueueParams", "ActionID": id, "Queue": "support", "Max": "0", "Strategy": "ringall",
+				"Calls": "1", "Holdtime": "35", "TalkTime": "120", "Completed": "10", "Abandoned": "2",
+				"ServiceLevel": "60", "ServicelevelPerf": "83.3", "Weight": "0"},
+			{"Event": "QueueMember", "ActionID": id, "Queue": "support", "Name": "Alice",
+				"StateInterface": "SIP/100", "Membership": "static", "Penalty": "1", "CallsTaken": "4",
+				"LastCall": "0", "Status": "1", "Paused": "1"},
+			{"Event": "QueueEntry", "ActionID": id, "Queue": "support", "Position": "1",
+				"Channel": "SIP/trunk-01", "Uniqueid": "1600000000.1", "CallerIDNum": "5551234",
+				"CallerIDName": "Carol", "Wait": "42"},
+			{"Event": "QueueStatusComplete", "ActionID": id, "EventList": "Complete", "ListItems": "3"},
+		}
+	})
+
+	queues, err := ami.QueueStatus("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queues) != 1 {
+		t.Fatalf("expected 1 queue, got %d", len(queues))
+	}
+	queue := queues[0]
+	if queue.Name != "support" || queue.Calls != 1 || queue.HoldTime != 35*time.Second || queue.ServiceLevelPerf != 83.3 {
+		t.Fatalf("unexpected queue %+v", queue)
+	}
+	if len(queue.Members) != 1 || queue.Members[0].Interface != "SIP/100" || !queue.Members[0].Paused ||
+		queue.Members[0].CallsTaken != 4 || !queue.Members[0].LastCall.IsZero() {
+		t.Fatalf("unexpected members %+v", queue.Members)
+	}
+	if len(queue.Entries) != 1 || queue.Entries[0].CallerIDNum != "5551234" || queue.Entries[0].Wait != 42*time.Second {
+		t.Fatalf("unexpected entries %+v", queue.Entries)
+	}
+}
+
+func TestSIPPeers(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.MockList("SIPpeers", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start", "Message": "Peer status list will follow"},
+			{"Event": "PeerEntry", "ActionID": id, "Channeltype": "SIP", "ObjectName": "100",
+				"IPaddress": "10.0.0.5", "IPport": "5060", "Dynamic": "yes", "Status": "OK (12 ms)"},
+			{"Event": "PeerEntry", "ActionID": id, "Channeltype": "SIP", "ObjectName": "trunk",
+				"IPaddress": "-none-", "IPport": "0", "Dynamic": "no", "Status": "UNREACHABLE"},
+			{"Event": "PeerlistComplete", "ActionID": id, "EventList": "Complete", "ListItems": "2"},
+		}
+	})
+
+	peers, err := ami.SIPPeers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(peers) != 2 {
+		t.Fatalf("expected 2 peers, got %d", len(peers))
+	}
+	if peers[0].Name != "100" || peers[0].IPPort != 5060 || !peers[0].Dynamic || peers[0].Status != "OK (12 ms)" {
+		t.Fatalf("unexpected peer %+v", peers[0])
+	}
+	if peers[1].Dynamic || peers[1].Status != "UNREACHABLE" {
+		t.Fatalf("unexpected peer %+v", peers[1])
+	}
+}
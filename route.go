@@ -0,0 +1,84 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"strings"
+)
+
+// RouteActionEvents deliver the events generated by an action, like the
+// Status events of Status or DBGetResponse of DBGet, on AMIResponse.Events
+// instead of Events. The response of an action answered with a list of
+// events is delivered once the list is complete.
+func RouteActionEvents(c *AMIClient) {
+	c.routeActionEvents = true
+}
+
+// listenOnce register a listener for actionID unless a helper already
+// listens it, nil is returned then
+func (client *AMIClient) listenOnce(actionID string) *eventListener {
+	client.mutexListeners.RLock()
+	_, ok := client.listeners[actionID]
+	client.mutexListeners.RUnlock()
+	if ok {
+		return nil
+	}
+	return client.listen(actionID)
+}
+
+// collectActionEvents attach to the response the events of the list it
+// announces, the listener is removed after. The list is cut short when the
+// connection is lost
+func (client *AMIClient) collectActionEvents(response *AMIResponse, listener *eventListener) {
+	defer client.unlisten(response.ID)
+
+	for {
+		select {
+		case ev := <-listener.events:
+			response.Events = append(response.Events, ev)
+			if completesList(ev) {
+				return
+			}
+		case <-listener.done:
+			return
+		case <-client.closed:
+			return
+		}
+	}
+}
+
+// announcesList check if the response is followed by a list of events
+func announcesList(response *AMIResponse) bool {
+	if strings.EqualFold(response.Params["Eventlist"], "start") {
+		return true
+	}
+	return response.Status == "Success" && strings.Contains(strings.ToLower(response.Params["Message"]), "follow")
+}
+
+// listCompleteEvents events closing a list sent by versions of Asterisk
+// without EventList: Complete, events like AgentComplete don't close any
+var listCompleteEvents = map[string]bool{
+	"AgentsComplete":              true,
+	"BridgeInfoComplete":          true,
+	"BridgeListComplete":          true,
+	"ConfbridgeListComplete":      true,
+	"ConfbridgeListRoomsComplete": true,
+	"CoreShowChannelsComplete":    true,
+	"DBGetComplete":               true,
+	"MeetmeListComplete":          true,
+	"ParkedCallsComplete":         true,
+	"PeerlistComplete":            true,
+	"QueueStatusComplete":         true,
+	"QueueSummaryComplete":        true,
+	"RegistrationsComplete":       true,
+	"ShowDialPlanComplete":        true,
+	"StatusComplete":              true,
+	"VoicemailUserEntryComplete":  true,
+}
+
+// completesList check if the event is the last one of a list
+func completesList(ev *AMIEvent) bool {
+	return strings.EqualFold(ev.Params["Eventlist"], "Complete") || listCompleteEvents[ev.ID]
+}
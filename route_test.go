@@ -0,0 +1,119 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestRouteActionEvents(t *testing.T) {
+	srv := newAmiServer()
+	defer srv.Close()
+	ami, err := Dial(srv.Addr, RouteActionEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	srv.MockList("Status", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start", "Message": "Channel status will follow"},
+			{"Event": "Status", "ActionID": id, "Channel": "SIP/100-01"},
+			{"Event": "Status", "ActionID": id, "Channel": "SIP/200-02"},
+			{"Event": "StatusComplete", "ActionID": id, "EventList": "Complete", "ListItems": "2"},
+		}
+	})
+	srv.MockList("DBGet", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "Message": "Result will follow"},
+			{"Event": "DBGetResponse", "ActionID": id, "Family": "cf", "Key": "100", "Val": "200"},
+			{"Event": "DBGetComplete", "ActionID": id},
+		}
+	})
+
+	response, _, err := ami.Action(Params{"Action": "Status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp := <-response
+	if len(resp.Events) != 3 || resp.Events[0].Params["Channel"] != "SIP/100-01" || resp.Events[2].ID != "StatusComplete" {
+		t.Fatalf("unexpected events %+v", resp.Events)
+	}
+
+	response, _, err = ami.Action(Params{"Action": "DBGet", "Family": "cf", "Key": "100"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp = <-response
+	if len(resp.Events) != 2 || resp.Events[0].Params["Val"] != "200" {
+		t.Fatalf("unexpected events %+v", resp.Events)
+	}
+
+	response, _, err = ami.Action(Params{"Action": "Ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := <-response; len(resp.Events) != 0 {
+		t.Fatal("events on a response without list")
+	}
+
+	for {
+		select {
+		case ev := <-ami.Events:
+			if ev.Params["Actionid"] != "" {
+				t.Fatal("action event delivered on Events", ev.ID)
+			}
+		case <-time.After(time.Millisecond * 100):
+			return
+		}
+	}
+}
+
+func TestRouteActionEventsWithHelpers(t *testing.T) {
+	srv := newAmiServer()
+	defer srv.Close()
+	ami, err := Dial(srv.Addr, RouteActionEvents)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	srv.MockList("BridgeList", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "BridgeListItem", "ActionID": id, "BridgeUniqueid": "b1"},
+			{"Event": "BridgeListComplete", "ActionID": id, "EventList": "Complete"},
+		}
+	})
+
+	bridges, err := ami.BridgeList("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bridges) != 1 {
+		t.Fatalf("expected 1 bridge, got %d", len(bridges))
+	}
+}
+
+func TestCompletesList(t *testing.T) {
+	for _, ev := range []*AMIEvent{
+		{ID: "EndpointDetailComplete", Params: map[string]string{"Eventlist": "Complete"}},
+		{ID: "StatusComplete", Params: map[string]string{}},
+	} {
+		if !completesList(ev) {
+			t.Error("list not completed by", ev.ID)
+		}
+	}
+	if completesList(&AMIEvent{ID: "AgentComplete", Params: map[string]string{"Actionid": "1"}}) {
+		t.Error("AgentComplete completes a list")
+	}
+}
@@ -0,0 +1,246 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"strings"
+	"sync"
+)
+
+type routeKind int
+
+const (
+	routeQueue routeKind = iota
+	routePeer
+	routeChannelPrefix
+)
+
+// RouteKey an entity whose events are routed, whatever their type
+type RouteKey struct {
+	kind  routeKind
+	value string
+}
+
+// QueueKey the events of the queue, those with its name on Queue
+func QueueKey(queue string) RouteKey {
+	return RouteKey{kind: routeQueue, value: queue}
+}
+
+// PeerKey the events of the peer, as SIP/100 or PJSIP/alice: those naming
+// it on Peer, Interface or Endpoint and those of its channels
+func PeerKey(peer string) RouteKey {
+	return RouteKey{kind: routePeer, value: strings.ToLower(peer)}
+}
+
+// ChannelPrefixKey the events of the channels whose name starts with prefix,
+// on Channel, DestChannel or any other channel param
+func ChannelPrefixKey(prefix string) RouteKey {
+	return RouteKey{kind: routeChannelPrefix, value: prefix}
+}
+
+// routeBuffer events kept for a Route not read yet
+const routeBuffer = 100
+
+// Route the events of some entities, an event is received once even when
+// it matches several keys. The events are buffered, those routed while the
+// buffer is full are dropped and logged so a route not read never stalls
+// the client
+type Route struct {
+	router *eventRouter
+	keys   []RouteKey
+	events chan *AMIEvent
+	done   chan struct{}
+	once   *sync.Once
+	// dropping since the buffer filled, used only by run to log once
+	dropping bool
+}
+
+// eventRouter index the routes by their keys, fed by a single watcher
+// while there are routes
+type eventRouter struct {
+	client   *AMIClient
+	watcher  *eventListener
+	routes   int
+	queues   map[string][]*Route
+	peers    map[string][]*Route
+	prefixes map[string][]*Route
+}
+
+// Route receive the events of the entities given by keys, until Stop.
+// Read Events promptly, the events are dropped while its buffer is full
+func (client *AMIClient) Route(keys ...RouteKey) *Route {
+	route := &Route{
+		keys:   append([]RouteKey(nil), keys...),
+		events: make(chan *AMIEvent, routeBuffer),
+		done:   make(chan struct{}),
+		once:   new(sync.Once),
+	}
+
+	client.mutexRouter.Lock()
+	defer client.mutexRouter.Unlock()
+
+	if client.router == nil {
+		client.router = &eventRouter{
+			client:   client,
+			watcher:  client.watch(),
+			queues:   make(map[string][]*Route),
+			peers:    make(map[string][]*Route),
+			prefixes: make(map[string][]*Route),
+		}
+		go client.router.run()
+	}
+	route.router = client.router
+	route.router.routes++
+	for _, key := range route.keys {
+		index := route.router.index(key.kind)
+		index[key.value] = append(index[key.value], route)
+	}
+	return route
+}
+
+// Events return the events routed
+func (route *Route) Events() <-chan *AMIEvent {
+	return route.events
+}
+
+// Stop routing the events, the router stops with its last route
+func (route *Route) Stop() {
+	route.once.Do(func() {
+		client := route.router.client
+		client.mutexRouter.Lock()
+		defer client.mutexRouter.Unlock()
+
+		close(route.done)
+		for _, key := range route.keys {
+			index := route.router.index(key.kind)
+			index[key.value] = withoutRoute(index[key.value], route)
+			if len(index[key.value]) == 0 {
+				delete(index, key.value)
+			}
+		}
+
+		route.router.routes--
+		if route.router.routes == 0 {
+			client.unwatch(route.router.watcher)
+			client.router = nil
+		}
+	})
+}
+
+// withoutRoute copy routes without route, the slices are read without lock
+// by run once taken
+func withoutRoute(routes []*Route, route *Route) []*Route {
+	kept := make([]*Route, 0, len(routes))
+	for _, r := range routes {
+		if r != route {
+			kept = append(kept, r)
+		}
+	}
+	return kept
+}
+
+func (router *eventRouter) index(kind routeKind) map[string][]*Route {
+	switch kind {
+	case routeQueue:
+		return router.queues
+	case routePeer:
+		return router.peers
+	}
+	return router.prefixes
+}
+
+func (router *eventRouter) run() {
+	for {
+		select {
+		case <-router.watcher.done:
+			return
+		case ev := <-router.watcher.events:
+			for _, route := range router.match(ev) {
+				router.send(route, ev)
+			}
+		}
+	}
+}
+
+// send ev to route without waiting, dropping it while the route is full
+func (router *eventRouter) send(route *Route, ev *AMIEvent) {
+	select {
+	case route.events <- ev:
+		route.dropping = false
+	case <-route.done:
+	default:
+		if !route.dropping {
+			route.dropping = true
+			router.client.logf("gami: route full, dropping its events until read")
+		}
+	}
+}
+
+// match return the routes of the entities of ev, each one once
+func (router *eventRouter) match(ev *AMIEvent) []*Route {
+	client := router.client
+	client.mutexRouter.RLock()
+	defer client.mutexRouter.RUnlock()
+
+	var matched []*Route
+	seen := make(map[*Route]bool)
+	add := func(routes []*Route) {
+		for _, route := range routes {
+			if !seen[route] {
+				seen[route] = true
+				matched = append(matched, route)
+			}
+		}
+	}
+
+	if queue := ev.Params["Queue"]; queue != "" {
+		add(router.queues[queue])
+	}
+	for _, peer := range peersOf(ev) {
+		add(router.peers[strings.ToLower(peer)])
+	}
+	if len(router.prefixes) > 0 {
+		for _, channel := range channelsOf(ev) {
+			for prefix, routes := range router.prefixes {
+				if strings.HasPrefix(channel, prefix) {
+					add(routes)
+				}
+			}
+		}
+	}
+	return matched
+}
+
+// channelsOf the names of the channels on the params of ev
+func channelsOf(ev *AMIEvent) []string {
+	var channels []string
+	for k, v := range ev.Params {
+		key := strings.ToLower(k)
+		if v != "" && (strings.HasSuffix(key, "channel") || key == "channel1" || key == "channel2") {
+			channels = append(channels, v)
+		}
+	}
+	return channels
+}
+
+// peersOf the peers named on ev and those of its channels
+func peersOf(ev *AMIEvent) []string {
+	var peers []string
+	for _, key := range []string{"Peer", "Interface", "Endpoint", "Endpointname"} {
+		if peer := ev.Params[key]; peer != "" {
+			peers = append(peers, peer)
+		}
+	}
+	if ev.Params["Channeltype"] != "" && ev.Params["Objectname"] != "" {
+		peers = append(peers, ev.Params["Channeltype"]+"/"+ev.Params["Objectname"])
+	}
+	for _, channel := range channelsOf(ev) {
+		// SIP/100-00000001 is a channel of SIP/100
+		if i := strings.LastIndex(channel, "-"); i > strings.Index(channel, "/") && strings.Contains(channel, "/") {
+			peers = append(peers, channel[:i])
+		}
+	}
+	return peers
+}
@@ -0,0 +1,128 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRoute(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	go func() {
+		for range ami.Events {
+		}
+	}()
+
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "QueueCallerJoin", "Queue": "sales", "Channel": "SIP/100-01", "Seq": "1"},
+			{"Event": "QueueMemberStatus", "Queue": "support", "Interface": "SIP/200", "Seq": "2"},
+			{"Event": "PeerStatus", "Peer": "SIP/200", "PeerStatus": "Reachable", "Seq": "3"},
+			{"Event": "Newchannel", "Channel": "PJSIP/trunk-00000002", "Seq": "4"},
+			{"Event": "DialBegin", "Channel": "SIP/100-01", "DestChannel": "PJSIP/trunk-00000003", "Seq": "5"},
+			{"Event": "UserEvent", "UserEvent": "end", "Seq": "6"},
+		}
+	})
+
+	sales := ami.Route(QueueKey("sales"))
+	peer := ami.Route(PeerKey("sip/200"))
+	trunk := ami.Route(ChannelPrefixKey("PJSIP/trunk-"), PeerKey("SIP/100"))
+	defer sales.Stop()
+	defer peer.Stop()
+	defer trunk.Stop()
+
+	rs, _, err := ami.Action(Params{"Action": "UserEvent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-rs
+
+	expected := []struct {
+		route *Route
+		seqs  []string
+	}{
+		{sales, []string{"1"}},
+		{peer, []string{"2", "3"}},
+		// the DialBegin matches both keys and is received once
+		{trunk, []string{"1", "4", "5"}},
+	}
+	for i, test := range expected {
+		for _, seq := range test.seqs {
+			select {
+			case ev := <-test.route.Events():
+				if ev.Params["Seq"] != seq {
+					t.Fatalf("route %d: expected event %s, got %s", i, seq, ev.Params["Seq"])
+				}
+			case <-time.After(time.Second * 2):
+				t.Fatalf("route %d: event %s not routed", i, seq)
+			}
+		}
+		select {
+		case ev := <-test.route.Events():
+			t.Fatalf("route %d: unexpected event %v", i, ev.Params)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	sales.Stop()
+	peer.Stop()
+	trunk.Stop()
+	ami.mutexRouter.RLock()
+	defer ami.mutexRouter.RUnlock()
+	if ami.router != nil {
+		t.Fatal("router kept without routes")
+	}
+}
+
+func TestRouteFull(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	logger := &testLogger{}
+	ami.Configure(UseLogger(logger))
+	go func() {
+		for range ami.Events {
+		}
+	}()
+
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		frames := []map[string]string{{"Response": "Success", "ActionID": params.Get("Actionid")}}
+		for i := 0; i < routeBuffer+10; i++ {
+			frames = append(frames, map[string]string{"Event": "QueueCallerJoin", "Queue": "sales",
+				"Seq": strconv.Itoa(i)})
+		}
+		return frames
+	})
+
+	// never read
+	sales := ami.Route(QueueKey("sales"))
+	defer sales.Stop()
+
+	rs, _, err := ami.Action(Params{"Action": "UserEvent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-rs
+	waitLogged(t, logger, "route full")
+
+	// the client still reads
+	if _, err := ami.syncAction(Params{"Action": "Ping"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(sales.Events()) != routeBuffer {
+		t.Fatal("unexpected events buffered", len(sales.Events()))
+	}
+	if ev := <-sales.Events(); ev.Params["Seq"] != "0" {
+		t.Fatal("unexpected first event", ev.Params)
+	}
+}
@@ -0,0 +1,225 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errRuleWithoutName = errors.New("Rule Without Name")
+var errRuleWithoutActions = errors.New("Rule Without Actions")
+
+// webhookClient posts the events of the rules
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// Rule run its actions for every event Event whose params match Match,
+// as when Hangup with Cause 34 on PJSIP/trunk-*:
+//
+//	Rule{Name: "trunk-congestion", Event: "Hangup",
+//		Match: map[string]string{"Cause": "34", "Channel": "PJSIP/trunk-*"},
+//		Actions: []RuleAction{{Webhook: "http://alerts/congestion"}}}
+//
+// or with the same expression given to gami tail:
+//
+//	Rule{Name: "trunk-congestion",
+//		Filter: MustParseFilter("Hangup Cause=34 Channel~^PJSIP/trunk-"),
+//		Actions: []RuleAction{{Webhook: "http://alerts/congestion"}}}
+type Rule struct {
+	Name string
+	// Event id of the events, any event when empty
+	Event string
+	// Match patterns of the params, * matches any text and ? any character,
+	// the param names ignore case
+	Match map[string]string
+	// Filter the events must pass besides Event and Match, none when nil
+	Filter  *Filter
+	Actions []RuleAction
+}
+
+// RuleAction something to do when a rule matches, the fields given are done
+type RuleAction struct {
+	// Action sent, the values expand ${Param} with the params of the event
+	// and ${Event} with its id
+	Action Params
+	// Webhook url receiving a POST of the event as JSON
+	Webhook string
+	// Func called with the event
+	Func func(client *AMIClient, ev *AMIEvent) error
+}
+
+// RuleEngine evaluates the rules against the events read
+type RuleEngine struct {
+	client  *AMIClient
+	watcher *eventListener
+	mutex   *sync.RWMutex
+	once    *sync.Once
+	rules   []compiledRule
+}
+
+type compiledRule struct {
+	Rule
+	patterns map[string]*regexp.Regexp
+}
+
+// Rules start a rule engine, rules are evaluated until Stop
+func (client *AMIClient) Rules() *RuleEngine {
+	engine := &RuleEngine{
+		client:  client,
+		watcher: client.watch(),
+		mutex:   new(sync.RWMutex),
+		once:    new(sync.Once),
+	}
+
+	go func() {
+		for {
+			select {
+			case <-engine.watcher.done:
+				return
+			case ev := <-engine.watcher.events:
+				engine.evaluate(ev)
+			}
+		}
+	}()
+
+	return engine
+}
+
+// Add the rule, replacing the rule with the same name
+func (engine *RuleEngine) Add(rule Rule) error {
+	if rule.Name == "" {
+		return errRuleWithoutName
+	}
+	if len(rule.Actions) == 0 {
+		return errRuleWithoutActions
+	}
+
+	compiled := compiledRule{Rule: rule, patterns: make(map[string]*regexp.Regexp, len(rule.Match))}
+	for param, pattern := range rule.Match {
+		re, err := compileGlob(pattern)
+		if err != nil {
+			return err
+		}
+		compiled.patterns[param] = re
+	}
+
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+	for i := range engine.rules {
+		if engine.rules[i].Name == rule.Name {
+			engine.rules[i] = compiled
+			return nil
+		}
+	}
+	engine.rules = append(engine.rules, compiled)
+	return nil
+}
+
+// Remove the rule name
+func (engine *RuleEngine) Remove(name string) {
+	engine.mutex.Lock()
+	defer engine.mutex.Unlock()
+
+	for i := range engine.rules {
+		if engine.rules[i].Name == name {
+			engine.rules = append(engine.rules[:i:i], engine.rules[i+1:]...)
+			return
+		}
+	}
+}
+
+// Stop evaluating the rules
+func (engine *RuleEngine) Stop() {
+	engine.once.Do(func() {
+		engine.client.unwatch(engine.watcher)
+	})
+}
+
+// evaluate the rules with ev, the actions of the rules matched run on
+// their own goroutine to keep reading events
+func (engine *RuleEngine) evaluate(ev *AMIEvent) {
+	engine.mutex.RLock()
+	defer engine.mutex.RUnlock()
+
+	for _, rule := range engine.rules {
+		if rule.matches(ev) {
+			go engine.run(rule.Rule, ev)
+		}
+	}
+}
+
+func (rule *compiledRule) matches(ev *AMIEvent) bool {
+	if rule.Event != "" && !strings.EqualFold(rule.Event, ev.ID) {
+		return false
+	}
+	for param, re := range rule.patterns {
+		if !re.MatchString(ev.Get(param)) {
+			return false
+		}
+	}
+	return rule.Filter == nil || rule.Filter.Match(ev)
+}
+
+// run the actions of the rule, logging the errors
+func (engine *RuleEngine) run(rule Rule, ev *AMIEvent) {
+	for _, action := range rule.Actions {
+		if err := action.run(engine.client, ev); err != nil {
+			engine.client.logf("gami: rule %s: %s", rule.Name, err)
+		}
+	}
+}
+
+func (action *RuleAction) run(client *AMIClient, ev *AMIEvent) error {
+	if len(action.Action) > 0 {
+		p := make(Params, len(action.Action))
+		for k, v := range action.Action {
+			p[k] = os.Expand(v, func(param string) string {
+				if param == "Event" {
+					return ev.ID
+				}
+				return ev.Get(param)
+			})
+		}
+		if _, err := client.syncAction(p); err != nil {
+			return err
+		}
+	}
+
+	if action.Webhook != "" {
+		body, err := json.Marshal(ev)
+		if err != nil {
+			return err
+		}
+		resp, err := webhookClient.Post(action.Webhook, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("Webhook %s answered %s", action.Webhook, resp.Status)
+		}
+	}
+
+	if action.Func != nil {
+		return action.Func(client, ev)
+	}
+	return nil
+}
+
+// compileGlob compile a pattern where * matches any text and ? any character
+func compileGlob(pattern string) (*regexp.Regexp, error) {
+	quoted := regexp.QuoteMeta(pattern)
+	quoted = strings.Replace(quoted, `\*`, ".*", -1)
+	quoted = strings.Replace(quoted, `\?`, ".", -1)
+	return regexp.Compile("^" + quoted + "$")
+}
@@ -0,0 +1,141 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestRules(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		if params.Get("Userevent") != "emit" {
+			return []map[string]string{{"Response": "Success", "ActionID": params.Get("Actionid"),
+				"Userevent": params.Get("Userevent"), "Channel": params.Get("Channel")}}
+		}
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "Hangup", "Channel": "SIP/100-01", "Cause": "34"},
+			{"Event": "Hangup", "Channel": "PJSIP/trunk-00000001", "Cause": "16"},
+			{"Event": "Hangup", "Channel": "PJSIP/trunk-00000002", "Cause": "34"},
+		}
+	})
+
+	hooked := make(chan *AMIEvent, 10)
+	webhook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ev := &AMIEvent{}
+		if err := json.NewDecoder(r.Body).Decode(ev); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		hooked <- ev
+	}))
+	defer webhook.Close()
+
+	called := make(chan *AMIEvent, 10)
+	engine := ami.Rules()
+	defer engine.Stop()
+	if err := engine.Add(Rule{Name: "broken"}); err != errRuleWithoutActions {
+		t.Fatal("expected rule without actions", err)
+	}
+	err := engine.Add(Rule{
+		Name:  "congestion",
+		Event: "Hangup",
+		Match: map[string]string{"cause": "34", "Channel": "PJSIP/trunk-*"},
+		Actions: []RuleAction{
+			{Action: Params{"Action": "UserEvent", "UserEvent": "Congestion", "Channel": "${Channel}"}},
+			{Webhook: webhook.URL},
+			{Func: func(client *AMIClient, ev *AMIEvent) error {
+				called <- ev
+				return nil
+			}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, err := ami.Action(Params{"Action": "UserEvent", "UserEvent": "emit"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case ev := <-called:
+		if ev.Params["Channel"] != "PJSIP/trunk-00000002" {
+			t.Fatalf("unexpected event matched %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("rule not matched")
+	}
+	select {
+	case ev := <-hooked:
+		if ev.ID != "Hangup" || ev.Params["Cause"] != "34" {
+			t.Fatalf("unexpected webhook %+v", ev)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+	select {
+	case ev := <-called:
+		t.Fatalf("unexpected event matched %+v", ev)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	engine.Remove("congestion")
+	if _, _, err := ami.Action(Params{"Action": "UserEvent", "UserEvent": "emit"}); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-called:
+		t.Fatal("rule removed matched")
+	case <-time.After(300 * time.Millisecond):
+	}
+}
+
+func TestCompileGlob(t *testing.T) {
+	re, err := compileGlob("PJSIP/trunk-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !re.MatchString("PJSIP/trunk-00000001") || re.MatchString("SIP/trunk-1") || re.MatchString("PJSIP/trunk") {
+		t.Fatal("unexpected match of *")
+	}
+
+	re, _ = compileGlob("SIP/10?-*")
+	if !re.MatchString("SIP/100-01") || re.MatchString("SIP/1000-01") {
+		t.Fatal("unexpected match of ?")
+	}
+}
+
+func TestRuleFilter(t *testing.T) {
+	rule := compiledRule{Rule: Rule{Name: "congestion", Match: map[string]string{"Cause": "34"},
+		Filter: MustParseFilter("Hangup Channel~^PJSIP/trunk-")}}
+	rule.patterns = map[string]*regexp.Regexp{"Cause": regexp.MustCompile("^34$")}
+
+	tests := []struct {
+		ev      *AMIEvent
+		matches bool
+	}{
+		{&AMIEvent{ID: "Hangup", Params: map[string]string{"Channel": "PJSIP/trunk-01", "Cause": "34"}}, true},
+		{&AMIEvent{ID: "Hangup", Params: map[string]string{"Channel": "SIP/100-01", "Cause": "34"}}, false},
+		{&AMIEvent{ID: "Hangup", Params: map[string]string{"Channel": "PJSIP/trunk-01", "Cause": "16"}}, false},
+		{&AMIEvent{ID: "Newchannel", Params: map[string]string{"Channel": "PJSIP/trunk-01", "Cause": "34"}}, false},
+	}
+	for _, test := range tests {
+		if rule.matches(test.ev) != test.matches {
+			t.Fatalf("%s %v: expected match %v", test.ev.ID, test.ev.Params, test.matches)
+		}
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// defaultValidateTimeout wait for the Pong validating the session
+const defaultValidateTimeout = 2 * time.Second
+
+// ValidateSession ping the server before sending the actions given,
+// Originate when none, if nothing was read for idle. Without a Pong in
+// timeout, 2 seconds when 0, the client reconnects before sending the
+// action, so it's not lost on a dead socket. idle 0 disable it
+func ValidateSession(idle, timeout time.Duration, actions ...string) func(*AMIClient) {
+	return func(c *AMIClient) {
+		if len(actions) == 0 {
+			actions = []string{"Originate"}
+		}
+		if timeout <= 0 {
+			timeout = defaultValidateTimeout
+		}
+		c.validateIdle, c.validateTimeout = idle, timeout
+		c.validateActions = make(map[string]bool, len(actions))
+		for _, action := range actions {
+			c.validateActions[strings.ToLower(action)] = true
+		}
+	}
+}
+
+// Idle return the time since the last frame was read
+func (client *AMIClient) Idle() time.Duration {
+	return time.Since(time.Unix(0, atomic.LoadInt64(&client.lastRead)))
+}
+
+// touch record a frame read
+func (client *AMIClient) touch() {
+	atomic.StoreInt64(&client.lastRead, time.Now().UnixNano())
+}
+
+// validateSession check the session answers a Ping when it's idle for
+// longer than idle, reconnecting when it doesn't
+func (client *AMIClient) validateSession(idle, timeout time.Duration) error {
+	if client.Idle() < idle {
+		return nil
+	}
+	if client.pingWithin(timeout) {
+		return nil
+	}
+
+	client.logf("gami: session idle for %s without Pong, reconnecting", client.Idle())
+	return client.Reconnect()
+}
+
+// pingWithin check the server answers a Ping in timeout
+func (client *AMIClient) pingWithin(timeout time.Duration) bool {
+	response, _, err := client.Action(Params{"Action": "Ping"})
+	if err == nil {
+		err = client.Flush()
+	}
+	if err != nil {
+		return false
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-response:
+		return true
+	case <-client.closed:
+		return false
+	case <-timer.C:
+		return false
+	}
+}
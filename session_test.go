@@ -0,0 +1,72 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestValidateSession(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	go func() {
+		for range ami.Events {
+		}
+	}()
+
+	var pings int32
+	var dead int32
+	srv.Mock("Ping", func(params textproto.MIMEHeader) map[string]string {
+		atomic.AddInt32(&pings, 1)
+		if atomic.LoadInt32(&dead) == 1 {
+			// a session that doesn't answer
+			time.Sleep(time.Second)
+		}
+		return map[string]string{"Response": "Success", "Ping": "Pong", "ActionID": params.Get("Actionid")}
+	})
+	ami.Configure(ValidateSession(30*time.Second, 100*time.Millisecond))
+	// idle for a minute, whatever the HeartBeat of the server
+	idle := func() {
+		atomic.StoreInt64(&ami.lastRead, time.Now().Add(-time.Minute).UnixNano())
+	}
+
+	send := func(action string) {
+		rs, _, err := ami.Action(Params{"Action": action})
+		if err != nil {
+			t.Fatal(err)
+		}
+		select {
+		case <-rs:
+		case <-time.After(time.Second * 2):
+			t.Fatal("no response to", action)
+		}
+	}
+
+	idle()
+	send("Status")
+	if n := atomic.LoadInt32(&pings); n != 0 {
+		t.Fatal("session validated before a non critical action", n)
+	}
+
+	idle()
+	send("Originate")
+	send("Originate")
+	if n := atomic.LoadInt32(&pings); n != 1 {
+		t.Fatal("expected a single Ping while idle, got", n)
+	}
+
+	_, generation, _ := ami.connection()
+	atomic.StoreInt32(&dead, 1)
+	idle()
+	send("Originate")
+	if _, current, _ := ami.connection(); current != generation+1 {
+		t.Fatal("not reconnected after the Ping timed out")
+	}
+}
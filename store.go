@@ -0,0 +1,157 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"sync"
+)
+
+// StateStore keeps the state followed by the trackers outside of the
+// client, so many instances of an application can share one view of the
+// server and keep it across restarts. Values are grouped by kind, like
+// "channels", and identified by key inside it. It must be safe for
+// concurrent use, googolgl/gami/redisstore implements it on Redis
+type StateStore interface {
+	// Put store value under key replacing the previous one
+	Put(kind, key string, value []byte) error
+	// Delete remove key, it's not an error when missing
+	Delete(kind, key string) error
+	// List return the values of kind by key
+	List(kind string) (map[string][]byte, error)
+}
+
+// UseStateStore keep the state of the trackers, the channels of
+// ChannelTracker and the peers of QualifyScheduler, on store besides the
+// memory of the client. The changes are written in background, the reads
+// apply the changes not written yet so they match the events handled
+func UseStateStore(store StateStore) func(*AMIClient) {
+	return func(c *AMIClient) {
+		c.stateStore = store
+	}
+}
+
+// stateStoreOf return the StateStore of the client, nil when the state is
+// kept in memory
+func (client *AMIClient) stateStoreOf() StateStore {
+	client.mutexConfig.RLock()
+	defer client.mutexConfig.RUnlock()
+	return client.stateStore
+}
+
+// storeWriter write to a StateStore from its own goroutine so a slow store
+// never delays the events, the changes of a key not written yet are
+// coalesced keeping the queue bounded by the keys
+type storeWriter struct {
+	client *AMIClient
+	store  StateStore
+	kind   string
+
+	mutex *sync.Mutex
+	// pending values by key, nil to delete the key
+	pending map[string][]byte
+	// writing values taken from pending by the flush in progress
+	writing map[string][]byte
+	wake    chan struct{}
+	done    chan struct{}
+	once    *sync.Once
+}
+
+func newStoreWriter(client *AMIClient, store StateStore, kind string) *storeWriter {
+	writer := &storeWriter{
+		client:  client,
+		store:   store,
+		kind:    kind,
+		mutex:   new(sync.Mutex),
+		pending: make(map[string][]byte),
+		wake:    make(chan struct{}, 1),
+		done:    make(chan struct{}),
+		once:    new(sync.Once),
+	}
+	go writer.run()
+	return writer
+}
+
+// put queue value to be stored under key
+func (writer *storeWriter) put(key string, value []byte) {
+	writer.queue(key, value)
+}
+
+// delete queue the removal of key
+func (writer *storeWriter) delete(key string) {
+	writer.queue(key, nil)
+}
+
+func (writer *storeWriter) queue(key string, value []byte) {
+	writer.mutex.Lock()
+	writer.pending[key] = value
+	writer.mutex.Unlock()
+
+	select {
+	case writer.wake <- struct{}{}:
+	default:
+	}
+}
+
+// unwritten return the changes queued or being written, nil values are
+// deleted. Applied over the store they give the state of this client
+func (writer *storeWriter) unwritten() map[string][]byte {
+	writer.mutex.Lock()
+	defer writer.mutex.Unlock()
+
+	changes := make(map[string][]byte, len(writer.writing)+len(writer.pending))
+	for key, value := range writer.writing {
+		changes[key] = value
+	}
+	for key, value := range writer.pending {
+		changes[key] = value
+	}
+	return changes
+}
+
+// stop the writer once the changes queued are written
+func (writer *storeWriter) stop() {
+	writer.once.Do(func() {
+		close(writer.done)
+	})
+}
+
+func (writer *storeWriter) run() {
+	for {
+		select {
+		case <-writer.wake:
+			writer.flush()
+		case <-writer.done:
+			writer.flush()
+			return
+		}
+	}
+}
+
+// flush write the changes queued
+func (writer *storeWriter) flush() {
+	writer.mutex.Lock()
+	pending := writer.pending
+	writer.pending = make(map[string][]byte)
+	writer.writing = pending
+	writer.mutex.Unlock()
+
+	defer func() {
+		writer.mutex.Lock()
+		writer.writing = nil
+		writer.mutex.Unlock()
+	}()
+
+	for key, value := range pending {
+		if value == nil {
+			if err := writer.store.Delete(writer.kind, key); err != nil {
+				writer.client.logf("gami: remove %s %s: %s", writer.kind, key, err)
+			}
+			continue
+		}
+		if err := writer.store.Put(writer.kind, key, value); err != nil {
+			writer.client.logf("gami: store %s %s: %s", writer.kind, key, err)
+		}
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net/textproto"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// MalformedFrame a frame refused by StrictMode, with the bytes read
+type MalformedFrame struct {
+	Raw []byte
+	// Problems found on the frame, one per line at most
+	Problems []string
+	Received time.Time
+}
+
+// malformedMessage of the responses given to the actions answered by a
+// malformed frame
+const malformedMessage = "Malformed frame"
+
+// StrictMode validate every frame read: its lines ended by CRLF, valid
+// UTF-8 and no key repeated with another value. The malformed frames are
+// sent on Malformed instead of being parsed, they are dropped while
+// Malformed is full. A malformed response fails the action waiting for it
+// with an Error response
+func StrictMode(c *AMIClient) {
+	c.strict = true
+}
+
+// StrictModeOff parse the frames read as they come, undoing StrictMode
+func StrictModeOff(c *AMIClient) {
+	c.strict = false
+}
+
+// repeatableKeys keys whose values are all kept when repeated, any other
+// key repeated with another value is a conflict
+var repeatableKeys = map[string]bool{
+	"Variable":         true,
+	"Chanvariable":     true,
+	"Destchanvariable": true,
+	"Output":           true,
+	// PresenceState answers with its Message after the Message of the
+	// response
+	"Message": true,
+}
+
+// readStrictFrame read a frame validating it, a malformed frame is
+// returned instead of the frame
+func readStrictFrame(r *textproto.Reader) (textproto.MIMEHeader, *MalformedFrame, error) {
+	raw, err := readRawFrame(r.R)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if problems := validateFrame(raw); len(problems) > 0 {
+		return nil, &MalformedFrame{Raw: raw, Problems: problems, Received: time.Now()}, nil
+	}
+	frame, err := ReadFrame(textproto.NewReader(bufio.NewReader(bytes.NewReader(raw))))
+	return frame, nil, err
+}
+
+// readRawFrame read the bytes of a frame up to its empty line, the output
+// of a "Response: Follows" up to --END COMMAND-- included
+func readRawFrame(r *bufio.Reader) ([]byte, error) {
+	var raw []byte
+	follows := false
+	for lines := 0; lines < maxFrameLines; lines++ {
+		line, err := r.ReadBytes('\n')
+		raw = append(raw, line...)
+		if err != nil {
+			return raw, err
+		}
+
+		text := strings.TrimRight(string(line), "\r\n")
+		switch {
+		case lines == 0 && isFollows(text):
+			follows = true
+		case follows:
+			if strings.HasSuffix(text, "--END COMMAND--") {
+				follows = false
+			}
+		case text == "":
+			return raw, nil
+		}
+	}
+	return raw, errFrameTooLong
+}
+
+func isFollows(line string) bool {
+	i := strings.Index(line, ":")
+	return i > 0 && canonicalKey(strings.TrimSpace(line[:i])) == "Response" &&
+		strings.EqualFold(strings.TrimSpace(line[i+1:]), "Follows")
+}
+
+// validateFrame return the problems of the lines of raw, the keys of the
+// output of a "Response: Follows" are not checked
+func validateFrame(raw []byte) []string {
+	var problems []string
+	values := make(map[string]string)
+	follows := false
+
+	lines := bytes.SplitAfter(raw, []byte("\n"))
+	for n, line := range lines {
+		if len(line) == 0 {
+			continue
+		}
+		if !bytes.HasSuffix(line, []byte("\r\n")) {
+			problems = append(problems, fmt.Sprintf("line %d: not terminated by CRLF", n+1))
+			continue
+		}
+		if !utf8.Valid(line) {
+			problems = append(problems, fmt.Sprintf("line %d: invalid UTF-8", n+1))
+			continue
+		}
+
+		text := string(line[:len(line)-2])
+		if n == 0 && isFollows(text) {
+			follows = true
+		}
+		if follows || text == "" {
+			continue
+		}
+
+		i := strings.Index(text, ":")
+		if i <= 0 {
+			problems = append(problems, fmt.Sprintf("line %d: not a header", n+1))
+			continue
+		}
+		key := canonicalKey(strings.TrimSpace(text[:i]))
+		value := strings.TrimSpace(text[i+1:])
+		if repeatableKeys[key] || strings.HasPrefix(key, "Chanvariable(") || strings.HasPrefix(key, "Destchanvariable(") {
+			continue
+		}
+		if previous, ok := values[key]; ok && previous != value {
+			problems = append(problems, fmt.Sprintf("line %d: %s repeated with %q and %q", n+1, key, previous, value))
+			continue
+		}
+		values[key] = value
+	}
+	return problems
+}
+
+// reportMalformed fail the action answered by the frame, if any, and send
+// the frame on Malformed, dropping it when full
+func (client *AMIClient) reportMalformed(frame *MalformedFrame) {
+	if actionID, ok := malformedResponse(frame.Raw); ok {
+		if pending, ok := client.response.take(actionID); ok {
+			client.failAction(actionID, pending, malformedMessage+": "+strings.Join(frame.Problems, ", "))
+		}
+	}
+
+	select {
+	case client.Malformed <- frame:
+	default:
+		client.logf("gami: malformed frame dropped: %s", strings.Join(frame.Problems, ", "))
+	}
+}
+
+// malformedResponse return the ActionID of raw when it's a response, read
+// as far as the lines allow
+func malformedResponse(raw []byte) (string, bool) {
+	response := false
+	actionID := ""
+	for _, line := range strings.Split(string(raw), "\n") {
+		i := strings.Index(line, ":")
+		if i <= 0 {
+			continue
+		}
+		value := strings.TrimSpace(line[i+1:])
+		switch canonicalKey(strings.TrimSpace(line[:i])) {
+		case "Response":
+			response = true
+		case "Actionid":
+			if actionID == "" {
+				actionID = value
+			}
+		}
+	}
+	if !response {
+		return "", false
+	}
+	return actionID, actionID != ""
+}
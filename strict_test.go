@@ -0,0 +1,191 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"bufio"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadStrictFrame(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		malformed string
+	}{
+		{"valid", "Event: Status\r\nVariable: A=1\r\nVariable: B=2\r\nChannel: SIP/100\r\n\r\n", ""},
+		{"same value repeated", "Event: Hangup\r\nChannel: SIP/100\r\nChannel: SIP/100\r\n\r\n", ""},
+		{"follows", "Response: Follows\r\nActionID: 1\r\nChannel: a\r\n\r\nChannel: b\r\n--END COMMAND--\r\n\r\n", ""},
+		{"bare LF", "Event: Hangup\nChannel: SIP/100\r\n\r\n", "line 1: not terminated by CRLF"},
+		{"invalid UTF-8", "Event: Hangup\r\nCallerIDName: \xff\xfe\r\n\r\n", "line 2: invalid UTF-8"},
+		{"conflict", "Event: Hangup\r\nUniqueid: 1.1\r\nUniqueid: 1.2\r\n\r\n", `line 3: Uniqueid repeated with "1.1" and "1.2"`},
+		{"not a header", "Event: Hangup\r\ngarbage\r\n\r\n", "line 2: not a header"},
+	}
+
+	for _, test := range tests {
+		r := textproto.NewReader(bufio.NewReader(strings.NewReader(test.input + "Event: Next\r\n\r\n")))
+		frame, malformed, err := readStrictFrame(r)
+		if err != nil {
+			t.Fatal(test.name, err)
+		}
+		if test.malformed == "" {
+			if malformed != nil {
+				t.Fatalf("%s: unexpected problems %v", test.name, malformed.Problems)
+			}
+			if len(frame) == 0 {
+				t.Fatalf("%s: frame not parsed", test.name)
+			}
+		} else {
+			if malformed == nil || len(malformed.Problems) != 1 || malformed.Problems[0] != test.malformed {
+				t.Fatalf("%s: expected %q, got %+v", test.name, test.malformed, malformed)
+			}
+			if string(malformed.Raw) != test.input {
+				t.Fatalf("%s: unexpected raw %q", test.name, malformed.Raw)
+			}
+		}
+
+		// the next frame is read whole
+		next, _, err := readStrictFrame(r)
+		if err != nil || next.Get("Event") != "Next" {
+			t.Fatalf("%s: next frame not read: %v %v", test.name, next, err)
+		}
+	}
+}
+
+func TestStrictMode(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	ami.Configure(StrictMode)
+
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "UserEvent", "Uniqueid": "1.1\r\nUniqueid: 1.2"},
+			{"Event": "UserEvent", "Uniqueid": "2.1"},
+		}
+	})
+
+	rs, _, err := ami.Action(Params{"Action": "UserEvent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-rs
+
+	select {
+	case malformed := <-ami.Malformed:
+		if !strings.Contains(string(malformed.Raw), "Uniqueid: 1.2") || len(malformed.Problems) != 1 {
+			t.Fatalf("unexpected malformed frame %+v", malformed)
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatal("malformed frame not reported")
+	}
+
+	for {
+		select {
+		case ev := <-ami.Events:
+			if ev.ID != "UserEvent" {
+				continue
+			}
+			if ev.Params["Uniqueid"] != "2.1" {
+				t.Fatal("malformed frame parsed", ev.Params)
+			}
+			return
+		case <-time.After(time.Second * 2):
+			t.Fatal("valid event not received")
+		}
+	}
+}
+
+func TestStrictModeOff(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	ami.Configure(StrictMode)
+	ami.Configure(StrictModeOff)
+
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "UserEvent", "Uniqueid": "1.1\r\nUniqueid: 1.2"},
+		}
+	})
+
+	rs, _, err := ami.Action(Params{"Action": "UserEvent"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	<-rs
+
+	for {
+		select {
+		case malformed := <-ami.Malformed:
+			t.Fatalf("unexpected malformed frame %+v", malformed)
+		case ev := <-ami.Events:
+			if ev.ID == "UserEvent" {
+				return
+			}
+		case <-time.After(time.Second * 2):
+			t.Fatal("event not parsed")
+		}
+	}
+}
+
+func TestStrictModeMalformedResponse(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	ami.Configure(StrictMode)
+
+	srv.MockList("Getvar", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid"), "Value": "1\r\nValue: 2"},
+		}
+	})
+
+	rs, _, err := ami.Action(Params{"Action": "Getvar"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case resp := <-rs:
+		if resp.Status != "Error" || !strings.HasPrefix(resp.Params["Message"], malformedMessage) {
+			t.Fatalf("expected the action failed, got %+v", resp)
+		}
+	case <-time.After(time.Second * 2):
+		t.Fatal("action blocked by a malformed response")
+	}
+
+	select {
+	case <-ami.Malformed:
+	case <-time.After(time.Second * 2):
+		t.Fatal("malformed frame not reported")
+	}
+}
+
+func TestMalformedResponse(t *testing.T) {
+	tests := []struct {
+		raw      string
+		actionID string
+		ok       bool
+	}{
+		{"Response: Success\nActionID: 7\r\n\r\n", "7", true},
+		{"actionid: 8\r\nresponse: Error\r\n\xff\r\n\r\n", "8", true},
+		{"Event: Hangup\r\nActionID: 9\r\n\r\n", "", false},
+		{"Response: Success\r\nValue: 1\nValue: 2\r\n\r\n", "", false},
+	}
+	for _, test := range tests {
+		actionID, ok := malformedResponse([]byte(test.raw))
+		if actionID != test.actionID || ok != test.ok {
+			t.Fatalf("%q: expected %q %v, got %q %v", test.raw, test.actionID, test.ok, actionID, ok)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Timeline the events of a call in the order they were read, ready to be
+// serialized to JSON
+type Timeline struct {
+	UniqueID string
+	LinkedID string
+	Start    time.Time
+	End      time.Time
+	Events   []TimelineEvent
+}
+
+// TimelineEvent an event of a timeline
+type TimelineEvent struct {
+	Time time.Time
+	// Offset since the start of the call
+	Offset        time.Duration
+	Event         string
+	Params        map[string]string
+	ChanVariables map[string]map[string]string `json:",omitempty"`
+}
+
+// Timeline return the events traced so far as a timeline
+func (trace *CallTrace) Timeline() *Timeline {
+	events := trace.Events()
+	timeline := &Timeline{
+		UniqueID: trace.UniqueID,
+		LinkedID: trace.LinkedID(),
+		Events:   make([]TimelineEvent, 0, len(events)),
+	}
+	// the wall clock only, as the times read back from JSON
+	if len(events) > 0 {
+		timeline.Start = events[0].Time.Round(0)
+		timeline.End = events[len(events)-1].Time.Round(0)
+	}
+	for _, traced := range events {
+		timeline.Events = append(timeline.Events, TimelineEvent{
+			Time:          traced.Time.Round(0),
+			Offset:        traced.Time.Round(0).Sub(timeline.Start),
+			Event:         traced.Event.ID,
+			Params:        traced.Event.Params,
+			ChanVariables: traced.Event.ChanVariables,
+		})
+	}
+	return timeline
+}
+
+// defaultTimelineMaxAge of the calls traced when TimelineOptions.MaxAge is 0
+const defaultTimelineMaxAge = 12 * time.Hour
+
+// TimelineOptions where the timelines of the calls are exported
+type TimelineOptions struct {
+	// Dir where each timeline is written as <linkedid>.json, none when empty
+	Dir string
+	// OnTimeline called with each timeline and its JSON, one at a time
+	// from the goroutine exporting them
+	OnTimeline func(timeline *Timeline, data []byte)
+	// MaxAge of a call traced, the calls still in progress after it are
+	// discarded as their Hangup was lost. 12 hours when 0
+	MaxAge time.Duration
+}
+
+// TimelineExporter traces every call and exports its timeline when it ends
+type TimelineExporter struct {
+	client  *AMIClient
+	opts    TimelineOptions
+	watcher *eventListener
+	once    *sync.Once
+	// traces of the calls in progress by uniqueid of their first channel,
+	// and the time they started, used only by run
+	traces  map[string]*CallTrace
+	started map[string]time.Time
+	// index the uniqueids and the linkedid seen on each call by the
+	// uniqueid of its trace, so an event reaches only the traces it
+	// references, and ids the keys indexed by trace
+	index map[string]string
+	ids   map[string][]string
+	// exports timelines waiting to be exported, so the files and the
+	// callback don't delay the events
+	exports chan *Timeline
+}
+
+// ExportTimelines trace the calls starting from now, each one is
+// serialized to JSON when its last channel hangs up, written to Dir and
+// given to OnTimeline
+func (client *AMIClient) ExportTimelines(opts TimelineOptions) *TimelineExporter {
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = defaultTimelineMaxAge
+	}
+	exporter := &TimelineExporter{
+		client:  client,
+		opts:    opts,
+		watcher: client.watch(),
+		once:    new(sync.Once),
+		traces:  make(map[string]*CallTrace),
+		started: make(map[string]time.Time),
+		index:   make(map[string]string),
+		ids:     make(map[string][]string),
+		exports: make(chan *Timeline, 100),
+	}
+
+	go exporter.run()
+	go exporter.exportAll()
+	return exporter
+}
+
+// Stop exporting, the calls in progress are not exported while the
+// timelines of the calls ended are still written
+func (exporter *TimelineExporter) Stop() {
+	exporter.once.Do(func() {
+		exporter.client.unwatch(exporter.watcher)
+	})
+}
+
+// run feed the traces from a single watcher, so a trace sees every event
+// following the first channel of its call
+func (exporter *TimelineExporter) run() {
+	defer close(exporter.exports)
+
+	interval := time.Minute
+	if exporter.opts.MaxAge < interval {
+		interval = exporter.opts.MaxAge
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-exporter.watcher.done:
+			return
+		case now := <-ticker.C:
+			exporter.expire(now)
+		case ev := <-exporter.watcher.events:
+			exporter.dispatch(ev)
+		}
+	}
+}
+
+// dispatch ev to the traces of the calls it references
+func (exporter *TimelineExporter) dispatch(ev *AMIEvent) {
+	if uniqueID, ok := startsCall(ev); ok {
+		exporter.traces[uniqueID] = exporter.client.newCallTrace(uniqueID, nil)
+		exporter.started[uniqueID] = time.Now()
+		exporter.index[uniqueID] = uniqueID
+		exporter.ids[uniqueID] = []string{uniqueID}
+	}
+
+	refs := callIDs(ev)
+	handled := make(map[string]struct{})
+	for _, ref := range refs {
+		uniqueID, ok := exporter.index[ref]
+		if !ok {
+			continue
+		}
+		if _, ok := handled[uniqueID]; ok {
+			continue
+		}
+		handled[uniqueID] = struct{}{}
+
+		trace := exporter.traces[uniqueID]
+		if trace.handle(ev) {
+			exporter.remove(uniqueID)
+			exporter.queue(trace.Timeline())
+			continue
+		}
+		// the channels joining the call are known from now on
+		for _, id := range refs {
+			if _, ok := exporter.index[id]; !ok {
+				exporter.index[id] = uniqueID
+				exporter.ids[uniqueID] = append(exporter.ids[uniqueID], id)
+			}
+		}
+	}
+}
+
+// remove the trace of uniqueID and its index
+func (exporter *TimelineExporter) remove(uniqueID string) {
+	for _, id := range exporter.ids[uniqueID] {
+		delete(exporter.index, id)
+	}
+	delete(exporter.ids, uniqueID)
+	delete(exporter.traces, uniqueID)
+	delete(exporter.started, uniqueID)
+}
+
+// callIDs the uniqueids and linkedids referenced by ev
+func callIDs(ev *AMIEvent) []string {
+	var ids []string
+	for k, v := range ev.Params {
+		if v == "" {
+			continue
+		}
+		key := strings.ToLower(k)
+		if strings.HasSuffix(key, "uniqueid") || strings.HasSuffix(key, "linkedid") {
+			ids = append(ids, v)
+		}
+	}
+	return ids
+}
+
+// expire discard the traces older than MaxAge
+func (exporter *TimelineExporter) expire(now time.Time) {
+	for uniqueID, started := range exporter.started {
+		if now.Sub(started) < exporter.opts.MaxAge {
+			continue
+		}
+		exporter.traces[uniqueID].Stop()
+		exporter.remove(uniqueID)
+		exporter.client.logf("gami: timeline %s discarded without Hangup after %s", uniqueID, exporter.opts.MaxAge)
+	}
+}
+
+// queue the timeline to be exported, it's dropped when the export is
+// far behind
+func (exporter *TimelineExporter) queue(timeline *Timeline) {
+	select {
+	case exporter.exports <- timeline:
+	default:
+		exporter.client.logf("gami: timeline %s dropped, the export is behind", timeline.UniqueID)
+	}
+}
+
+// exportAll export the timelines queued until run ends
+func (exporter *TimelineExporter) exportAll() {
+	for timeline := range exporter.exports {
+		exporter.export(timeline)
+	}
+}
+
+// startsCall check if ev creates the first channel of a call, returning
+// its uniqueid
+func startsCall(ev *AMIEvent) (string, bool) {
+	uniqueID := ev.Params["Uniqueid"]
+	if ev.ID != "Newchannel" || uniqueID == "" {
+		return "", false
+	}
+	if linkedID := ev.Params["Linkedid"]; linkedID != "" && linkedID != uniqueID {
+		return "", false
+	}
+	return uniqueID, true
+}
+
+// export the timeline to the directory and the callback
+func (exporter *TimelineExporter) export(timeline *Timeline) {
+	data, err := json.MarshalIndent(timeline, "", "  ")
+	if err != nil {
+		exporter.client.logf("gami: timeline %s: %s", timeline.UniqueID, err)
+		return
+	}
+
+	if exporter.opts.Dir != "" {
+		name := timeline.LinkedID
+		if name == "" {
+			name = timeline.UniqueID
+		}
+		// a uniqueid is like 1614099385.12, keep it a plain file name
+		name = strings.NewReplacer("/", "_", string(os.PathSeparator), "_").Replace(name)
+		path := filepath.Join(exporter.opts.Dir, name+".json")
+		if err := ioutil.WriteFile(path, data, 0644); err != nil {
+			exporter.client.logf("gami: timeline %s: %s", timeline.UniqueID, err)
+		}
+	}
+
+	if exporter.opts.OnTimeline != nil {
+		exporter.opts.OnTimeline(timeline, data)
+	}
+}
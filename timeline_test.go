@@ -0,0 +1,160 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/textproto"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestExportTimelines(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	srv.MockList("Originate", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "Newchannel", "Channel": "SIP/100-01", "Uniqueid": "1.1", "Linkedid": "1.1"},
+			{"Event": "Newchannel", "Channel": "SIP/200-02", "Uniqueid": "1.2", "Linkedid": "1.1"},
+			{"Event": "Newchannel", "Channel": "SIP/300-03", "Uniqueid": "9.1", "Linkedid": "9.1"},
+			{"Event": "DialBegin", "Uniqueid": "1.1", "DestUniqueid": "1.2"},
+			{"Event": "Hangup", "Channel": "SIP/100-01", "Uniqueid": "1.1", "Linkedid": "1.1"},
+			{"Event": "Hangup", "Channel": "SIP/300-03", "Uniqueid": "9.1", "Linkedid": "9.1"},
+			{"Event": "Hangup", "Channel": "SIP/200-02", "Uniqueid": "1.2", "Linkedid": "1.1"},
+		}
+	})
+
+	dir := t.TempDir()
+	exported := make(chan *Timeline, 2)
+	exporter := ami.ExportTimelines(TimelineOptions{
+		Dir:        dir,
+		OnTimeline: func(timeline *Timeline, data []byte) { exported <- timeline },
+	})
+	defer exporter.Stop()
+
+	if _, _, err := ami.Action(Params{"Action": "Originate"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the call of 9.1 ends first
+	for _, linkedID := range []string{"9.1", "1.1"} {
+		select {
+		case timeline := <-exported:
+			if timeline.LinkedID != linkedID {
+				t.Fatal("unexpected timeline", timeline.LinkedID)
+			}
+		case <-time.After(time.Second * 5):
+			t.Fatal("timeline not exported", linkedID)
+		}
+	}
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "1.1.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var timeline Timeline
+	if err := json.Unmarshal(data, &timeline); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"Newchannel", "Newchannel", "DialBegin", "Hangup", "Hangup"}
+	if len(timeline.Events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(timeline.Events))
+	}
+	for i, ev := range timeline.Events {
+		if ev.Event != expected[i] {
+			t.Fatalf("event %d expected %s got %s", i, expected[i], ev.Event)
+		}
+		if ev.Offset != ev.Time.Sub(timeline.Start) {
+			t.Fatal("unexpected offset", ev.Offset)
+		}
+	}
+	if timeline.Events[0].Params["Channel"] != "SIP/100-01" || timeline.End.Before(timeline.Start) {
+		t.Fatalf("unexpected timeline %+v", timeline)
+	}
+}
+
+func TestExportTimelinesMaxAge(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+	logger := &testLogger{}
+	ami.Configure(UseLogger(logger))
+
+	// the Hangup is lost
+	srv.MockList("Originate", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "Newchannel", "Channel": "SIP/100-01", "Uniqueid": "1.1", "Linkedid": "1.1"},
+		}
+	})
+
+	exported := make(chan *Timeline, 1)
+	exporter := ami.ExportTimelines(TimelineOptions{
+		MaxAge:     50 * time.Millisecond,
+		OnTimeline: func(timeline *Timeline, data []byte) { exported <- timeline },
+	})
+	defer exporter.Stop()
+
+	if _, _, err := ami.Action(Params{"Action": "Originate"}); err != nil {
+		t.Fatal(err)
+	}
+	waitLogged(t, logger, "timeline 1.1 discarded")
+
+	select {
+	case timeline := <-exported:
+		t.Fatal("unexpected timeline", timeline.UniqueID)
+	default:
+	}
+}
+
+func TestExportTimelinesIndex(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	// fed by hand, without run
+	exporter := &TimelineExporter{
+		client:  ami,
+		traces:  make(map[string]*CallTrace),
+		started: make(map[string]time.Time),
+		index:   make(map[string]string),
+		ids:     make(map[string][]string),
+		exports: make(chan *Timeline, 2),
+	}
+	events := []map[string]string{
+		{"Event": "Newchannel", "Uniqueid": "1.1", "Linkedid": "1.1"},
+		{"Event": "Newchannel", "Uniqueid": "9.1", "Linkedid": "9.1"},
+		{"Event": "Newchannel", "Uniqueid": "1.2", "Linkedid": "1.1"},
+		{"Event": "DialBegin", "Uniqueid": "1.1", "DestUniqueid": "1.2"},
+		{"Event": "VarSet", "Uniqueid": "5.1", "Linkedid": "5.1"},
+		{"Event": "Hangup", "Uniqueid": "1.1", "Linkedid": "1.1"},
+		{"Event": "Hangup", "Uniqueid": "1.2", "Linkedid": "1.1"},
+	}
+	for _, params := range events {
+		exporter.dispatch(&AMIEvent{ID: params["Event"], Params: params})
+	}
+
+	if len(exporter.traces) != 1 || exporter.traces["9.1"] == nil {
+		t.Fatalf("unexpected traces %v", exporter.traces)
+	}
+	if len(exporter.index) != 1 || exporter.index["9.1"] != "9.1" {
+		t.Fatalf("unexpected index %v", exporter.index)
+	}
+	if events := exporter.traces["9.1"].Events(); len(events) != 1 {
+		t.Fatal("unexpected events on 9.1", len(events))
+	}
+
+	timeline := <-exporter.exports
+	if timeline.UniqueID != "1.1" || len(timeline.Events) != 5 {
+		t.Fatalf("unexpected timeline %+v", timeline)
+	}
+}
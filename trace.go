@@ -0,0 +1,190 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// TracedEvent an event of a call trace with the time it was read
+type TracedEvent struct {
+	Time  time.Time
+	Event *AMIEvent
+}
+
+// CallTrace collects every event referencing a call, the channel with the
+// Uniqueid traced and the channels sharing its Linkedid
+type CallTrace struct {
+	UniqueID string
+
+	client  *AMIClient
+	watcher *eventListener
+	mutex   *sync.Mutex
+	once    *sync.Once
+
+	linkedID string
+	// channels uniqueid of the channels alive on the call
+	channels map[string]struct{}
+	events   []TracedEvent
+	stream   chan TracedEvent
+	done     chan struct{}
+}
+
+// TraceCall start tracing the events referencing the call of uniqueID, the
+// trace ends when every channel of the call hangs up or on Stop
+func (client *AMIClient) TraceCall(uniqueID string) *CallTrace {
+	trace := client.newCallTrace(uniqueID, client.watch())
+	go trace.run()
+	return trace
+}
+
+// newCallTrace a trace of the call of uniqueID fed by watcher, watcher is
+// nil when the owner feeds the events to handle
+func (client *AMIClient) newCallTrace(uniqueID string, watcher *eventListener) *CallTrace {
+	return &CallTrace{
+		UniqueID: uniqueID,
+		client:   client,
+		watcher:  watcher,
+		mutex:    new(sync.Mutex),
+		once:     new(sync.Once),
+		channels: map[string]struct{}{uniqueID: {}},
+		stream:   make(chan TracedEvent, 100),
+		done:     make(chan struct{}),
+	}
+}
+
+// Events return the events traced in the order they were read
+func (trace *CallTrace) Events() []TracedEvent {
+	trace.mutex.Lock()
+	defer trace.mutex.Unlock()
+
+	events := make([]TracedEvent, len(trace.events))
+	copy(events, trace.events)
+	return events
+}
+
+// LinkedID return the Linkedid of the call, empty until an event reports it
+func (trace *CallTrace) LinkedID() string {
+	trace.mutex.Lock()
+	defer trace.mutex.Unlock()
+	return trace.linkedID
+}
+
+// Stream return the events as they are traced, the chan is closed when the
+// trace ends, events are not sent while the buffer is full but they are
+// kept on Events
+func (trace *CallTrace) Stream() <-chan TracedEvent {
+	return trace.stream
+}
+
+// Done closed when the trace ends
+func (trace *CallTrace) Done() <-chan struct{} {
+	return trace.done
+}
+
+// Stop tracing the call
+func (trace *CallTrace) Stop() {
+	trace.once.Do(func() {
+		if trace.watcher != nil {
+			trace.client.unwatch(trace.watcher)
+		}
+		close(trace.done)
+	})
+}
+
+func (trace *CallTrace) run() {
+	defer close(trace.stream)
+
+	for {
+		select {
+		case <-trace.done:
+			return
+		case ev := <-trace.watcher.events:
+			if trace.handle(ev) {
+				return
+			}
+		}
+	}
+}
+
+// handle trace ev if it references the call, true when the call ended
+// and the trace is stopped
+func (trace *CallTrace) handle(ev *AMIEvent) bool {
+	if !trace.references(ev) {
+		return false
+	}
+
+	// the time it was read, not the time it reached the trace
+	received := ev.Received
+	if received.IsZero() {
+		received = time.Now()
+	}
+	traced := TracedEvent{Time: received, Event: ev}
+	trace.mutex.Lock()
+	trace.events = append(trace.events, traced)
+	trace.mutex.Unlock()
+
+	select {
+	case trace.stream <- traced:
+	default:
+	}
+
+	if trace.ended(ev) {
+		trace.Stop()
+		return true
+	}
+	return false
+}
+
+// references check if ev references a channel of the call, learning the
+// Linkedid and the channels of the call
+func (trace *CallTrace) references(ev *AMIEvent) bool {
+	trace.mutex.Lock()
+	defer trace.mutex.Unlock()
+
+	if ev.Params["Uniqueid"] == trace.UniqueID && ev.Params["Linkedid"] != "" {
+		trace.linkedID = ev.Params["Linkedid"]
+	}
+
+	referenced := false
+	for k, v := range ev.Params {
+		if v == "" {
+			continue
+		}
+		key := strings.ToLower(k)
+		switch {
+		case strings.HasSuffix(key, "uniqueid"):
+			if _, ok := trace.channels[v]; ok {
+				referenced = true
+			}
+		case strings.HasSuffix(key, "linkedid"):
+			if v == trace.linkedID {
+				referenced = true
+			}
+		}
+	}
+
+	if referenced && trace.linkedID != "" && ev.Params["Linkedid"] == trace.linkedID &&
+		ev.Params["Uniqueid"] != "" && ev.ID != "Hangup" {
+		trace.channels[ev.Params["Uniqueid"]] = struct{}{}
+	}
+
+	return referenced
+}
+
+// ended check if ev hangs up the last channel of the call
+func (trace *CallTrace) ended(ev *AMIEvent) bool {
+	if ev.ID != "Hangup" {
+		return false
+	}
+
+	trace.mutex.Lock()
+	defer trace.mutex.Unlock()
+
+	delete(trace.channels, ev.Params["Uniqueid"])
+	return len(trace.channels) == 0
+}
@@ -0,0 +1,72 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestTraceCall(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.MockList("Originate", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "Newchannel", "Channel": "SIP/100-01", "Uniqueid": "1.1", "Linkedid": "1.1"},
+			{"Event": "Newchannel", "Channel": "SIP/200-02", "Uniqueid": "1.2", "Linkedid": "1.1"},
+			{"Event": "Newchannel", "Channel": "SIP/300-03", "Uniqueid": "9.1", "Linkedid": "9.1"},
+			{"Event": "DialBegin", "Uniqueid": "1.1", "DestUniqueid": "1.2"},
+			{"Event": "Hangup", "Channel": "SIP/100-01", "Uniqueid": "1.1", "Linkedid": "1.1"},
+			{"Event": "Hangup", "Channel": "SIP/300-03", "Uniqueid": "9.1", "Linkedid": "9.1"},
+			{"Event": "Hangup", "Channel": "SIP/200-02", "Uniqueid": "1.2", "Linkedid": "1.1"},
+		}
+	})
+
+	trace := ami.TraceCall("1.1")
+	if _, _, err := ami.Action(Params{"Action": "Originate"}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-trace.Done():
+	case <-time.After(time.Second * 5):
+		t.Fatal("trace not ended")
+	}
+
+	events := trace.Events()
+	expected := []string{"Newchannel", "Newchannel", "DialBegin", "Hangup", "Hangup"}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+	for i, traced := range events {
+		if traced.Event.ID != expected[i] {
+			t.Fatalf("event %d expected %s got %s", i, expected[i], traced.Event.ID)
+		}
+		if traced.Event.Params["Uniqueid"] == "9.1" {
+			t.Fatal("traced event of another call")
+		}
+		if i > 0 && traced.Time.Before(events[i-1].Time) {
+			t.Fatal("events not ordered")
+		}
+		if !traced.Time.Equal(traced.Event.Received) {
+			t.Fatal("expected the time the event was read, got", traced.Time)
+		}
+	}
+	if trace.LinkedID() != "1.1" {
+		t.Fatal("unexpected linkedid", trace.LinkedID())
+	}
+
+	streamed := 0
+	for range trace.Stream() {
+		streamed++
+	}
+	if streamed != len(expected) {
+		t.Fatalf("expected %d streamed events, got %d", len(expected), streamed)
+	}
+}
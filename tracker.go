@@ -0,0 +1,363 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+)
+
+// channelsKind kind of the channels on a StateStore
+const channelsKind = "channels"
+
+// Channel state of a channel followed by a ChannelTracker
+type Channel struct {
+	Name         string
+	UniqueID     string
+	LinkedID     string
+	State        string
+	CallerIDNum  string
+	CallerIDName string
+	Context      string
+	Exten        string
+	// BridgeID bridge where the channel is, empty if not bridged
+	BridgeID string
+	Created  time.Time
+}
+
+// Call channels sharing a Linkedid, what Asterisk shows as a call
+type Call struct {
+	LinkedID string
+	// Legs channels of the call ordered by creation
+	Legs []Channel
+	// Bridges where the legs are
+	Bridges []string
+	// State Bridged when two legs share a bridge, otherwise the most
+	// advanced state of the legs
+	State   string
+	Created time.Time
+}
+
+// callStateRank order of the channel states to aggregate the call state
+var callStateRank = map[string]int{
+	"Down":            0,
+	"Rsrvd":           1,
+	"OffHook":         2,
+	"Dialing":         3,
+	"Pre-ring":        4,
+	"Ring":            5,
+	"Ringing":         6,
+	"Busy":            7,
+	"Dialing Offhook": 8,
+	"Up":              9,
+}
+
+// ChannelTracker follows the channels of the server from the events read
+type ChannelTracker struct {
+	client   *AMIClient
+	watcher  *eventListener
+	mutex    *sync.RWMutex
+	once     *sync.Once
+	channels map[string]*Channel
+	// store shared with other trackers, nil keep the channels in memory
+	store StateStore
+	// writer of the changes to store
+	writer *storeWriter
+}
+
+// TrackChannels start tracking the channels created from now, use Sync to
+// load the channels already on the server, it's synced again when Asterisk
+// boots after a Reconnect. With UseStateStore the channels on the store are
+// loaded and every change is written to it
+func (client *AMIClient) TrackChannels() *ChannelTracker {
+	tracker := &ChannelTracker{
+		client:   client,
+		watcher:  client.watch(),
+		mutex:    new(sync.RWMutex),
+		once:     new(sync.Once),
+		channels: make(map[string]*Channel),
+		store:    client.stateStoreOf(),
+	}
+	if tracker.store != nil {
+		if channels, err := tracker.stored(nil); err != nil {
+			client.logf("gami: load channels: %s", err)
+		} else {
+			tracker.channels = channels
+		}
+		tracker.writer = newStoreWriter(client, tracker.store, channelsKind)
+	}
+
+	client.syncOnBoot(tracker.watcher, tracker.Sync)
+
+	go func() {
+		for {
+			select {
+			case <-tracker.watcher.done:
+				return
+			case ev := <-tracker.watcher.events:
+				tracker.handle(ev)
+			}
+		}
+	}()
+
+	return tracker
+}
+
+// Sync load the channels on the server with the action CoreShowChannels,
+// the channels known before that are no longer on the server are removed
+func (tracker *ChannelTracker) Sync() error {
+	start := time.Now()
+	events, err := tracker.client.listAction(Params{"Action": "CoreShowChannels"}, "CoreShowChannelsComplete")
+	if err != nil {
+		return err
+	}
+
+	listed := make(map[string]bool)
+	for _, ev := range events {
+		if ev.ID == "CoreShowChannel" {
+			listed[ev.Params["Uniqueid"]] = true
+			tracker.handle(ev)
+		}
+	}
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	for uniqueID, channel := range tracker.channels {
+		if !listed[uniqueID] && channel.Created.Before(start) {
+			delete(tracker.channels, uniqueID)
+			tracker.remove(uniqueID)
+		}
+	}
+	return nil
+}
+
+// Stop tracking channels
+func (tracker *ChannelTracker) Stop() {
+	tracker.once.Do(func() {
+		tracker.client.unwatch(tracker.watcher)
+		if tracker.writer != nil {
+			tracker.writer.stop()
+		}
+	})
+}
+
+// Channels return the channels alive ordered by creation, read from the
+// StateStore when there is one. The changes of this tracker not written
+// yet are applied over the store, so the channels are never behind the
+// events handled
+func (tracker *ChannelTracker) Channels() []Channel {
+	var channels []Channel
+	if tracker.store != nil {
+		// taken before the store is read, a change written meanwhile is
+		// applied twice instead of lost
+		var unwritten map[string][]byte
+		if tracker.writer != nil {
+			unwritten = tracker.writer.unwritten()
+		}
+		// the store is read without holding the tracker
+		stored, err := tracker.stored(unwritten)
+		if err != nil {
+			tracker.client.logf("gami: load channels: %s", err)
+		} else {
+			channels = make([]Channel, 0, len(stored))
+			for _, channel := range stored {
+				channels = append(channels, *channel)
+			}
+		}
+	}
+
+	if channels == nil {
+		tracker.mutex.RLock()
+		channels = make([]Channel, 0, len(tracker.channels))
+		for _, channel := range tracker.channels {
+			channels = append(channels, *channel)
+		}
+		tracker.mutex.RUnlock()
+	}
+	sortChannels(channels)
+	return channels
+}
+
+// CallsSnapshot return the calls alive grouping the channels by Linkedid,
+// ordered by creation
+func (tracker *ChannelTracker) CallsSnapshot() []Call {
+	byLinkedID := make(map[string]*Call)
+	var calls []*Call
+	for _, channel := range tracker.Channels() {
+		call, ok := byLinkedID[channel.LinkedID]
+		if !ok {
+			call = &Call{LinkedID: channel.LinkedID, Created: channel.Created}
+			byLinkedID[channel.LinkedID] = call
+			calls = append(calls, call)
+		}
+		call.Legs = append(call.Legs, channel)
+	}
+
+	snapshot := make([]Call, 0, len(calls))
+	for _, call := range calls {
+		bridged := make(map[string]int)
+		for _, leg := range call.Legs {
+			if leg.BridgeID == "" {
+				continue
+			}
+			if bridged[leg.BridgeID] == 0 {
+				call.Bridges = append(call.Bridges, leg.BridgeID)
+			}
+			bridged[leg.BridgeID]++
+			if bridged[leg.BridgeID] > 1 {
+				call.State = "Bridged"
+			}
+		}
+
+		if call.State == "" {
+			for _, leg := range call.Legs {
+				if call.State == "" || callStateRank[leg.State] > callStateRank[call.State] {
+					call.State = leg.State
+				}
+			}
+		}
+		snapshot = append(snapshot, *call)
+	}
+
+	return snapshot
+}
+
+// handle update the channels from the event
+func (tracker *ChannelTracker) handle(ev *AMIEvent) {
+	uniqueID := ev.Params["Uniqueid"]
+	if uniqueID == "" {
+		return
+	}
+
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+
+	switch ev.ID {
+	case "Newchannel", "CoreShowChannel":
+		channel := &Channel{
+			Name:     ev.Params["Channel"],
+			UniqueID: uniqueID,
+			LinkedID: ev.Params["Linkedid"],
+			BridgeID: ev.Params["Bridgeid"],
+			Created:  time.Now(),
+		}
+		if channel.LinkedID == "" {
+			channel.LinkedID = uniqueID
+		}
+		if known, ok := tracker.channels[uniqueID]; ok {
+			channel.Created = known.Created
+		}
+		updateChannel(channel, ev)
+		tracker.channels[uniqueID] = channel
+		tracker.save(channel)
+		return
+	case "Hangup":
+		delete(tracker.channels, uniqueID)
+		tracker.remove(uniqueID)
+		return
+	}
+
+	channel, ok := tracker.channels[uniqueID]
+	if !ok {
+		return
+	}
+
+	switch ev.ID {
+	case "Newstate", "Newexten", "NewCallerid", "NewConnectedLine":
+		updateChannel(channel, ev)
+	case "Rename":
+		if ev.Params["Newname"] != "" {
+			channel.Name = ev.Params["Newname"]
+		} else if ev.Params["Channel"] != "" {
+			channel.Name = ev.Params["Channel"]
+		}
+	case "BridgeEnter":
+		channel.BridgeID = ev.Params["Bridgeuniqueid"]
+	case "BridgeLeave":
+		channel.BridgeID = ""
+	default:
+		return
+	}
+	tracker.save(channel)
+}
+
+// stored read the channels of the StateStore with the changes unwritten
+// applied
+func (tracker *ChannelTracker) stored(unwritten map[string][]byte) (map[string]*Channel, error) {
+	values, err := tracker.store.List(channelsKind)
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = make(map[string][]byte)
+	}
+	for uniqueID, value := range unwritten {
+		if value == nil {
+			delete(values, uniqueID)
+		} else {
+			values[uniqueID] = value
+		}
+	}
+
+	channels := make(map[string]*Channel, len(values))
+	for uniqueID, value := range values {
+		channel := new(Channel)
+		if err := json.Unmarshal(value, channel); err != nil {
+			tracker.client.logf("gami: load channel %s: %s", uniqueID, err)
+			continue
+		}
+		channels[uniqueID] = channel
+	}
+	return channels, nil
+}
+
+// save queue the write of the channel to the StateStore, if any
+func (tracker *ChannelTracker) save(channel *Channel) {
+	if tracker.writer == nil {
+		return
+	}
+	value, err := json.Marshal(channel)
+	if err != nil {
+		tracker.client.logf("gami: store channel %s: %s", channel.UniqueID, err)
+		return
+	}
+	tracker.writer.put(channel.UniqueID, value)
+}
+
+// remove queue the delete of the channel from the StateStore, if any
+func (tracker *ChannelTracker) remove(uniqueID string) {
+	if tracker.writer != nil {
+		tracker.writer.delete(uniqueID)
+	}
+}
+
+// updateChannel copy the fields present on the event to channel
+func updateChannel(channel *Channel, ev *AMIEvent) {
+	fields := map[string]*string{
+		"Channelstatedesc": &channel.State,
+		"Calleridnum":      &channel.CallerIDNum,
+		"Calleridname":     &channel.CallerIDName,
+		"Context":          &channel.Context,
+		"Exten":            &channel.Exten,
+		"Extension":        &channel.Exten,
+	}
+	for k, field := range fields {
+		if v, ok := ev.Params[k]; ok && v != "" {
+			*field = v
+		}
+	}
+}
+
+// sortChannels order the channels by creation and uniqueid
+func sortChannels(channels []Channel) {
+	sort.Slice(channels, func(i, j int) bool {
+		if !channels[i].Created.Equal(channels[j].Created) {
+			return channels[i].Created.Before(channels[j].Created)
+		}
+		return channels[i].UniqueID < channels[j].UniqueID
+	})
+}
@@ -0,0 +1,245 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChannelTrackerCalls(t *testing.T) {
+	tracker := &ChannelTracker{channels: make(map[string]*Channel), mutex: new(sync.RWMutex)}
+
+	events := []*AMIEvent{
+		{ID: "Newchannel", Params: map[string]string{"Channel": "SIP/100-01", "Uniqueid": "1.1", "Linkedid": "1.1", "Channelstatedesc": "Ring"}},
+		{ID: "Newchannel", Params: map[string]string{"Channel": "SIP/200-02", "Uniqueid": "1.2", "Linkedid": "1.1", "Channelstatedesc": "Down"}},
+		{ID: "Newchannel", Params: map[string]string{"Channel": "SIP/300-03", "Uniqueid": "2.1", "Linkedid": "2.1", "Channelstatedesc": "Ring"}},
+		{ID: "Newstate", Params: map[string]string{"Uniqueid": "1.2", "Channelstatedesc": "Ringing"}},
+		{ID: "Newstate", Params: map[string]string{"Uniqueid": "1.2", "Channelstatedesc": "Up"}},
+		{ID: "BridgeEnter", Params: map[string]string{"Uniqueid": "1.1", "Bridgeuniqueid": "b1"}},
+		{ID: "BridgeEnter", Params: map[string]string{"Uniqueid": "1.2", "Bridgeuniqueid": "b1"}},
+		{ID: "Newchannel", Params: map[string]string{"Channel": "SIP/400-04", "Uniqueid": "3.1", "Linkedid": "3.1"}},
+		{ID: "Hangup", Params: map[string]string{"Channel": "SIP/400-04", "Uniqueid": "3.1"}},
+	}
+	for _, ev := range events {
+		tracker.handle(ev)
+	}
+
+	calls := tracker.CallsSnapshot()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 calls, got %d", len(calls))
+	}
+
+	byLinkedID := make(map[string]Call)
+	for _, call := range calls {
+		byLinkedID[call.LinkedID] = call
+	}
+
+	call := byLinkedID["1.1"]
+	if len(call.Legs) != 2 || call.State != "Bridged" {
+		t.Fatalf("unexpected call %+v", call)
+	}
+	if len(call.Bridges) != 1 || call.Bridges[0] != "b1" {
+		t.Fatalf("unexpected bridges %v", call.Bridges)
+	}
+
+	call = byLinkedID["2.1"]
+	if len(call.Legs) != 1 || call.State != "Ring" || call.Legs[0].Name != "SIP/300-03" {
+		t.Fatalf("unexpected call %+v", call)
+	}
+
+	tracker.handle(&AMIEvent{ID: "BridgeLeave", Params: map[string]string{"Uniqueid": "1.2", "Bridgeuniqueid": "b1"}})
+	for _, call := range tracker.CallsSnapshot() {
+		if call.LinkedID == "1.1" && call.State != "Up" {
+			t.Fatalf("expected call Up after leaving the bridge, got %s", call.State)
+		}
+	}
+}
+
+func TestChannelTrackerSync(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.MockList("CoreShowChannels", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "CoreShowChannel", "ActionID": id, "Channel": "SIP/100-01", "Uniqueid": "1.1",
+				"Linkedid": "1.1", "ChannelStateDesc": "Up", "BridgeId": "b1"},
+			{"Event": "CoreShowChannel", "ActionID": id, "Channel": "SIP/200-02", "Uniqueid": "1.2",
+				"Linkedid": "1.1", "ChannelStateDesc": "Up", "BridgeId": "b1"},
+			{"Event": "CoreShowChannelsComplete", "ActionID": id, "EventList": "Complete", "ListItems": "2"},
+		}
+	})
+
+	tracker := ami.TrackChannels()
+	defer tracker.Stop()
+	if err := tracker.Sync(); err != nil {
+		t.Fatal(err)
+	}
+
+	if channels := tracker.Channels(); len(channels) != 2 {
+		t.Fatalf("expected 2 channels, got %d", len(channels))
+	}
+	calls := tracker.CallsSnapshot()
+	if len(calls) != 1 || calls[0].State != "Bridged" {
+		t.Fatalf("unexpected calls %+v", calls)
+	}
+}
+
+// memoryStore a StateStore on a map
+type memoryStore struct {
+	mutex  sync.Mutex
+	values map[string]map[string][]byte
+}
+
+func (store *memoryStore) Put(kind, key string, value []byte) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	if store.values[kind] == nil {
+		store.values[kind] = make(map[string][]byte)
+	}
+	store.values[kind][key] = value
+	return nil
+}
+
+func (store *memoryStore) Delete(kind, key string) error {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	delete(store.values[kind], key)
+	return nil
+}
+
+func (store *memoryStore) List(kind string) (map[string][]byte, error) {
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	values := make(map[string][]byte)
+	for k, v := range store.values[kind] {
+		values[k] = v
+	}
+	return values, nil
+}
+
+func TestChannelTrackerStateStore(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	store := &memoryStore{values: map[string]map[string][]byte{"channels": {
+		"1.1": []byte(`{"Name":"SIP/100-01","UniqueID":"1.1","LinkedID":"1.1","State":"Up"}`),
+		"9.9": []byte(`{"Name":"SIP/900-09","UniqueID":"9.9","LinkedID":"9.9","State":"Up"}`),
+	}}}
+	ami.Configure(UseStateStore(store))
+
+	srv.MockList("CoreShowChannels", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "CoreShowChannel", "ActionID": id, "Channel": "SIP/100-01", "Uniqueid": "1.1",
+				"Linkedid": "1.1", "ChannelStateDesc": "Up"},
+			{"Event": "CoreShowChannelsComplete", "ActionID": id, "EventList": "Complete", "ListItems": "1"},
+		}
+	})
+
+	tracker := ami.TrackChannels()
+	defer tracker.Stop()
+	if channels := tracker.Channels(); len(channels) != 2 {
+		t.Fatalf("expected the stored channels, got %+v", channels)
+	}
+	if err := tracker.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	// the store is written in background
+	waitChannels(t, tracker, func(channels []Channel) bool {
+		return len(channels) == 1 && channels[0].UniqueID == "1.1"
+	})
+
+	// read after the listed channels, handled in order by the tracker
+	srv.MockList("UserEvent", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "Newchannel", "Channel": "SIP/200-02", "Uniqueid": "1.2", "Linkedid": "1.1",
+				"ChannelStateDesc": "Ring"},
+			{"Event": "Newstate", "Uniqueid": "1.2", "ChannelStateDesc": "Up"},
+			{"Event": "Hangup", "Uniqueid": "1.1"},
+		}
+	})
+	if _, err := ami.syncAction(Params{"Action": "UserEvent"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// a tracker of another instance sees the same channels
+	other := &ChannelTracker{channels: make(map[string]*Channel), mutex: new(sync.RWMutex), store: store}
+	waitChannels(t, other, func(channels []Channel) bool {
+		return len(channels) == 1 && channels[0].UniqueID == "1.2" && channels[0].State == "Up"
+	})
+}
+
+// waitChannels wait until the channels of the tracker pass check
+func waitChannels(t *testing.T, tracker *ChannelTracker, check func([]Channel) bool) {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		channels := tracker.Channels()
+		if check(channels) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("unexpected channels %+v", channels)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// blockingStore a StateStore hung until release is closed
+type blockingStore struct {
+	memoryStore
+	release chan struct{}
+}
+
+func (store *blockingStore) Put(kind, key string, value []byte) error {
+	<-store.release
+	return store.memoryStore.Put(kind, key, value)
+}
+
+func TestChannelTrackerSlowStore(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	store := &blockingStore{memoryStore: memoryStore{values: make(map[string]map[string][]byte)},
+		release: make(chan struct{})}
+	ami.Configure(UseStateStore(store))
+	tracker := ami.TrackChannels()
+	defer tracker.Stop()
+
+	handled := make(chan struct{})
+	go func() {
+		for i := 0; i < 100; i++ {
+			tracker.handle(&AMIEvent{ID: "Newchannel", Params: map[string]string{"Channel": "SIP/100-01",
+				"Uniqueid": "1.1", "Channelstatedesc": strconv.Itoa(i)}})
+		}
+		close(handled)
+	}()
+	select {
+	case <-handled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("events blocked by the store")
+	}
+
+	// read before the store is written
+	if channels := tracker.Channels(); len(channels) != 1 || channels[0].State != "99" {
+		t.Fatalf("unexpected channels %+v", channels)
+	}
+
+	close(store.release)
+	waitChannels(t, tracker, func(channels []Channel) bool {
+		return len(channels) == 1 && channels[0].State == "99"
+	})
+}
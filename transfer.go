@@ -0,0 +1,115 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"time"
+)
+
+// transferTimeout to wait for the outcome of a transfer, an attended
+// transfer lasts while the transferer consults the target
+var transferTimeout = 10 * time.Minute
+
+// Transfer outcome of a transfer reported by Asterisk >= 12
+type Transfer struct {
+	// Attended true for attended transfers, false for blind transfers
+	Attended bool
+	// Result Success, Fail, Invalid or Not Permitted
+	Result string
+	// Transferer channel that executed the transfer
+	Transferer string
+	// Transferee channel that was transferred
+	Transferee string
+	// Destination exten@context of a blind transfer, the target channel
+	// of an attended transfer
+	Destination string
+}
+
+// Succeeded check if the transfer was completed
+func (t *Transfer) Succeeded() bool {
+	return t.Result == "Success"
+}
+
+// NewTransfer build the transfer from an event BlindTransfer or
+// AttendedTransfer, ok is false for other events
+func NewTransfer(ev *AMIEvent) (transfer *Transfer, ok bool) {
+	switch ev.ID {
+	case "BlindTransfer":
+		return &Transfer{
+			Result:      ev.Params["Result"],
+			Transferer:  ev.Params["Transfererchannel"],
+			Transferee:  ev.Params["Transfereechannel"],
+			Destination: ev.Params["Extension"] + "@" + ev.Params["Context"],
+		}, true
+	case "AttendedTransfer":
+		return &Transfer{
+			Attended:    true,
+			Result:      ev.Params["Result"],
+			Transferer:  ev.Params["Origtransfererchannel"],
+			Transferee:  ev.Params["Transfereechannel"],
+			Destination: ev.Params["Transfertargetchannel"],
+		}, true
+	}
+	return nil, false
+}
+
+// BlindTransfer transfer the call of channel to exten@context, the outcome is
+// sent on the returned chan when Asterisk reports it, the chan is closed
+// without outcome if the channel hangs up before, the connection is
+// replaced or the outcome doesn't come within 10 minutes
+func (client *AMIClient) BlindTransfer(channel, context, exten string) (<-chan *Transfer, error) {
+	return client.transfer(Params{"Action": "BlindTransfer", "Channel": channel, "Context": context, "Exten": exten}, channel)
+}
+
+// Atxfer start an attended transfer of the call of channel to exten@context,
+// the outcome is sent on the returned chan when the transfer finishes, the
+// chan is closed without outcome as with BlindTransfer
+func (client *AMIClient) Atxfer(channel, context, exten string) (<-chan *Transfer, error) {
+	return client.transfer(Params{"Action": "Atxfer", "Channel": channel, "Context": context, "Exten": exten}, channel)
+}
+
+// transfer send the transfer action and wait for the outcome involving channel
+func (client *AMIClient) transfer(p Params, channel string) (<-chan *Transfer, error) {
+	watcher := client.watch()
+	if _, err := client.syncAction(p); err != nil {
+		client.unwatch(watcher)
+		return nil, err
+	}
+
+	_, _, changed := client.connection()
+	timer := time.NewTimer(transferTimeout)
+
+	outcome := make(chan *Transfer, 1)
+	go func() {
+		defer close(outcome)
+		defer client.unwatch(watcher)
+		defer timer.Stop()
+
+		for {
+			var ev *AMIEvent
+			select {
+			case ev = <-watcher.events:
+			case <-timer.C:
+				return
+			case <-changed:
+				return
+			case <-client.closed:
+				return
+			}
+
+			if transfer, ok := NewTransfer(ev); ok {
+				if transfer.Transferer == channel || transfer.Transferee == channel {
+					outcome <- transfer
+					return
+				}
+			}
+			if ev.ID == "Hangup" && ev.Params["Channel"] == channel {
+				return
+			}
+		}
+	}()
+
+	return outcome, nil
+}
@@ -0,0 +1,115 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestBlindTransfer(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.MockList("BlindTransfer", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "BlindTransfer", "Result": "Success",
+				"TransfererChannel": params.Get("Channel"), "TransfereeChannel": "SIP/200-00000002",
+				"Context": params.Get("Context"), "Extension": params.Get("Exten")},
+		}
+	})
+
+	outcome, err := ami.BlindTransfer("SIP/100-00000001", "default", "300")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case transfer, ok := <-outcome:
+		if !ok {
+			t.Fatal("transfer without outcome")
+		}
+		if !transfer.Succeeded() || transfer.Attended {
+			t.Fatalf("unexpected transfer %+v", transfer)
+		}
+		if transfer.Transferee != "SIP/200-00000002" || transfer.Destination != "300@default" {
+			t.Fatalf("unexpected transfer %+v", transfer)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("transfer outcome not received")
+	}
+}
+
+func TestAtxferHangup(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	srv.MockList("Atxfer", func(params textproto.MIMEHeader) []map[string]string {
+		return []map[string]string{
+			{"Response": "Success", "ActionID": params.Get("Actionid")},
+			{"Event": "Hangup", "Channel": params.Get("Channel"), "Cause": "16"},
+		}
+	})
+
+	outcome, err := ami.Atxfer("SIP/100-00000001", "default", "300")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case transfer, ok := <-outcome:
+		if ok {
+			t.Fatalf("unexpected transfer %+v", transfer)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("transfer not closed on hangup")
+	}
+}
+
+func TestBlindTransferTimeout(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+
+	defer func(timeout time.Duration) { transferTimeout = timeout }(transferTimeout)
+	transferTimeout = 200 * time.Millisecond
+
+	outcome, err := ami.BlindTransfer("SIP/100-00000001", "default", "300")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case transfer, ok := <-outcome:
+		if ok {
+			t.Fatalf("unexpected transfer %+v", transfer)
+		}
+	case <-time.After(time.Second * 5):
+		t.Fatal("transfer not closed after the timeout")
+	}
+}
+
+func TestNewTransferAttended(t *testing.T) {
+	transfer, ok := NewTransfer(&AMIEvent{ID: "AttendedTransfer", Params: map[string]string{
+		"Result":                "Fail",
+		"Origtransfererchannel": "SIP/100-00000001",
+		"Transfereechannel":     "SIP/200-00000002",
+		"Transfertargetchannel": "SIP/300-00000003",
+	}})
+	if !ok {
+		t.Fatal("AttendedTransfer not recognized")
+	}
+	if !transfer.Attended || transfer.Succeeded() || transfer.Destination != "SIP/300-00000003" {
+		t.Fatalf("unexpected transfer %+v", transfer)
+	}
+
+	if _, ok := NewTransfer(&AMIEvent{ID: "Hangup"}); ok {
+		t.Fatal("Hangup recognized as transfer")
+	}
+}
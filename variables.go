@@ -0,0 +1,88 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"strings"
+	"time"
+)
+
+// VariableSnapshot variables of a channel read at once
+type VariableSnapshot struct {
+	Channel string
+	// Values of the variables read, empty for the variables not set
+	Values map[string]string
+	// Errors of the variables that couldn't be read
+	Errors map[string]error
+}
+
+// ChannelVariables read the variables of channel with a single Status,
+// the variables it doesn't return, as on servers ignoring its Variables,
+// are read with GetVar one by one. The error of each variable is on
+// Errors, an error is returned only when none could be read
+func (client *AMIClient) ChannelVariables(channel string, variables ...string) (*VariableSnapshot, error) {
+	snapshot := &VariableSnapshot{
+		Channel: channel,
+		Values:  make(map[string]string, len(variables)),
+		Errors:  make(map[string]error),
+	}
+	if len(variables) == 0 {
+		return snapshot, nil
+	}
+
+	events, err := client.listAction(Params{"Action": "Status", "Channel": channel,
+		"Variables": strings.Join(variables, ",")}, "StatusComplete")
+	if err == nil {
+		for _, ev := range events {
+			if ev.ID != "Status" {
+				continue
+			}
+			for name, value := range ev.ChanVariables[ev.Params["Channel"]] {
+				snapshot.Values[name] = value
+			}
+		}
+	}
+
+	var lastErr error
+	for _, variable := range variables {
+		if _, ok := snapshot.Values[variable]; ok {
+			continue
+		}
+		value, err := client.GetVar(channel, variable)
+		if err != nil {
+			snapshot.Errors[variable] = err
+			lastErr = err
+			continue
+		}
+		snapshot.Values[variable] = value
+	}
+
+	if len(snapshot.Errors) == len(variables) {
+		return snapshot, lastErr
+	}
+	return snapshot, nil
+}
+
+// Get return the value of the variable, empty when not read
+func (snapshot *VariableSnapshot) Get(variable string) string {
+	return snapshot.Values[variable]
+}
+
+// GetInt return the value of the variable as int, 0 when not read or not
+// a number
+func (snapshot *VariableSnapshot) GetInt(variable string) int {
+	return parseInt(snapshot.Values[variable])
+}
+
+// GetBool return the value of the variable as bool, see AMIEvent.GetBool
+func (snapshot *VariableSnapshot) GetBool(variable string) bool {
+	return parseBool(snapshot.Values[variable])
+}
+
+// GetDuration return the value of the variable as duration, see
+// AMIEvent.GetDuration
+func (snapshot *VariableSnapshot) GetDuration(variable string) time.Duration {
+	return parseDuration(snapshot.Values[variable])
+}
@@ -0,0 +1,82 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"bufio"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatusVariables(t *testing.T) {
+	r := textproto.NewReader(bufio.NewReader(strings.NewReader(
+		"Event: Status\r\nChannel: SIP/100-01\r\nVariable: ACCOUNT=1000\r\nVariable: TRIES=3\r\n" +
+			"Variable: EMPTY=\r\n\r\n")))
+
+	data, err := ReadFrame(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ev, err := newEvent(&data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	variables := ev.ChanVariables["SIP/100-01"]
+	if len(variables) != 3 || variables["ACCOUNT"] != "1000" || variables["TRIES"] != "3" {
+		t.Fatalf("unexpected variables %v", ev.ChanVariables)
+	}
+	if _, ok := variables["EMPTY"]; !ok {
+		t.Fatal("expected empty variable")
+	}
+}
+
+func TestChannelVariables(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	srv.maxDelay = 0
+
+	srv.MockList("Status", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		if v := params.Get("Variables"); v != "ACCOUNT,TIMEOUT,RECORD,BROKEN" && v != "BROKEN" {
+			t.Errorf("unexpected Variables %q", v)
+		}
+		// a server returning some of the variables only
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "Status", "ActionID": id, "Channel": "SIP/100-01", "Variable": "ACCOUNT=1000"},
+			{"Event": "StatusComplete", "ActionID": id, "EventList": "Complete", "Items": "1"},
+		}
+	})
+	srv.Mock("GetVar", func(params textproto.MIMEHeader) map[string]string {
+		id := params.Get("Actionid")
+		switch params.Get("Variable") {
+		case "TIMEOUT":
+			return map[string]string{"Response": "Success", "ActionID": id, "Variable": "TIMEOUT", "Value": "30"}
+		case "RECORD":
+			return map[string]string{"Response": "Success", "ActionID": id, "Variable": "RECORD", "Value": "yes"}
+		}
+		return map[string]string{"Response": "Error", "ActionID": id, "Message": "Invalid variable"}
+	})
+
+	snapshot, err := ami.ChannelVariables("SIP/100-01", "ACCOUNT", "TIMEOUT", "RECORD", "BROKEN")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if snapshot.Get("ACCOUNT") != "1000" || snapshot.GetDuration("TIMEOUT") != 30*time.Second ||
+		!snapshot.GetBool("RECORD") || snapshot.GetInt("ACCOUNT") != 1000 {
+		t.Fatalf("unexpected values %v", snapshot.Values)
+	}
+	if len(snapshot.Errors) != 1 || snapshot.Errors["BROKEN"] == nil {
+		t.Fatalf("unexpected errors %v", snapshot.Errors)
+	}
+
+	if _, err := ami.ChannelVariables("SIP/100-01", "BROKEN"); err == nil {
+		t.Fatal("expected error when no variable is read")
+	}
+}
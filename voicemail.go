@@ -0,0 +1,79 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"strconv"
+)
+
+// VoicemailUser a mailbox configured on app_voicemail
+type VoicemailUser struct {
+	Mailbox     string
+	Context     string
+	FullName    string
+	Email       string
+	NewMessages int
+	OldMessages int
+}
+
+// MailboxCount number of messages on a mailbox
+type MailboxCount struct {
+	Mailbox        string
+	UrgentMessages int
+	NewMessages    int
+	OldMessages    int
+}
+
+// VoicemailUsersList return the mailboxes configured on the server
+func (client *AMIClient) VoicemailUsersList() ([]VoicemailUser, error) {
+	events, err := client.listAction(Params{"Action": "VoicemailUsersList"}, "VoicemailUserEntryComplete")
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]VoicemailUser, 0, len(events))
+	for _, ev := range events {
+		if ev.ID != "VoicemailUserEntry" {
+			continue
+		}
+		newMessages, _ := strconv.Atoi(ev.Params["Newmessagecount"])
+		oldMessages, _ := strconv.Atoi(ev.Params["Oldmessagecount"])
+		users = append(users, VoicemailUser{
+			Mailbox:     ev.Params["Voicemailbox"],
+			Context:     ev.Params["Vmcontext"],
+			FullName:    ev.Params["Fullname"],
+			Email:       ev.Params["Email"],
+			NewMessages: newMessages,
+			OldMessages: oldMessages,
+		})
+	}
+
+	return users, nil
+}
+
+// MailboxStatus check if the mailbox (mailbox@context) has messages waiting
+func (client *AMIClient) MailboxStatus(mailbox string) (bool, error) {
+	resp, err := client.syncAction(Params{"Action": "MailboxStatus", "Mailbox": mailbox})
+	if err != nil {
+		return false, err
+	}
+
+	waiting, _ := strconv.Atoi(resp.Params["Waiting"])
+	return waiting > 0, nil
+}
+
+// MailboxCount return the number of messages on the mailbox (mailbox@context)
+func (client *AMIClient) MailboxCount(mailbox string) (*MailboxCount, error) {
+	resp, err := client.syncAction(Params{"Action": "MailboxCount", "Mailbox": mailbox})
+	if err != nil {
+		return nil, err
+	}
+
+	count := &MailboxCount{Mailbox: resp.Params["Mailbox"]}
+	count.UrgentMessages, _ = strconv.Atoi(resp.Params["Urgmessages"])
+	count.NewMessages, _ = strconv.Atoi(resp.Params["Newmessages"])
+	count.OldMessages, _ = strconv.Atoi(resp.Params["Oldmessages"])
+	return count, nil
+}
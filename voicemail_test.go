@@ -0,0 +1,149 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"net/textproto"
+	"testing"
+	"time"
+)
+
+func TestVoicemailUsersList(t *testing.T) {
+	srv := newAmiServer()
+	defer srv.Close()
+	ami, err := Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	srv.MockList("VoicemailUsersList", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "VoicemailUserEntry", "ActionID": id, "VMContext": "default",
+				"VoiceMailbox": "1000", "Fullname": "Alice", "NewMessageCount": "2", "OldMessageCount": "5"},
+			{"Event": "VoicemailUserEntry", "ActionID": id, "VMContext": "sales",
+				"VoiceMailbox": "2000", "Fullname": "Bob", "NewMessageCount": "0", "OldMessageCount": "1"},
+			{"Event": "VoicemailUserEntryComplete", "ActionID": id, "EventList": "Complete", "ListItems": "2"},
+		}
+	})
+
+	users, err := ami.VoicemailUsersList()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 2 {
+		t.Fatalf("expected 2 mailboxes, got %d", len(users))
+	}
+	byMailbox := make(map[string]VoicemailUser)
+	for _, user := range users {
+		byMailbox[user.Mailbox] = user
+	}
+	alice := byMailbox["1000"]
+	if alice.Context != "default" || alice.FullName != "Alice" || alice.NewMessages != 2 || alice.OldMessages != 5 {
+		t.Fatalf("unexpected mailbox %+v", alice)
+	}
+	if byMailbox["2000"].Context != "sales" {
+		t.Fatalf("unexpected mailbox %+v", byMailbox["2000"])
+	}
+}
+
+func TestMailboxCount(t *testing.T) {
+	srv := newAmiServer()
+	defer srv.Close()
+	ami, err := Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	srv.Mock("MailboxCount", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{
+			"Response":    "Success",
+			"ActionID":    params.Get("Actionid"),
+			"Mailbox":     params.Get("Mailbox"),
+			"UrgMessages": "1",
+			"NewMessages": "3",
+			"OldMessages": "4",
+		}
+	})
+	srv.Mock("MailboxStatus", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{
+			"Response": "Success",
+			"ActionID": params.Get("Actionid"),
+			"Mailbox":  params.Get("Mailbox"),
+			"Waiting":  "1",
+		}
+	})
+
+	count, err := ami.MailboxCount("1000@default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count.Mailbox != "1000@default" || count.UrgentMessages != 1 || count.NewMessages != 3 || count.OldMessages != 4 {
+		t.Fatalf("unexpected count %+v", count)
+	}
+
+	waiting, err := ami.MailboxStatus("1000@default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !waiting {
+		t.Fatal("expected messages waiting")
+	}
+}
+
+func TestVoicemailUsersListError(t *testing.T) {
+	srv := newAmiServer()
+	defer srv.Close()
+	ami, err := Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	srv.Mock("VoicemailUsersList", func(params textproto.MIMEHeader) map[string]string {
+		return map[string]string{
+			"Response": "Error",
+			"ActionID": params.Get("Actionid"),
+			"Message":  "Permission denied",
+		}
+	})
+
+	if _, err := ami.VoicemailUsersList(); err == nil || err.Error() != "Permission denied" {
+		t.Fatal("expected permission error, got", err)
+	}
+}
+
+func TestVoicemailUsersListIncomplete(t *testing.T) {
+	srv := newAmiServer()
+	defer srv.Close()
+	ami, err := Dial(srv.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go ami.Run()
+	defer ami.Close()
+
+	defer func(timeout time.Duration) { listTimeout = timeout }(listTimeout)
+	listTimeout = 200 * time.Millisecond
+
+	// the complete event never comes
+	srv.MockList("VoicemailUsersList", func(params textproto.MIMEHeader) []map[string]string {
+		id := params.Get("Actionid")
+		return []map[string]string{
+			{"Response": "Success", "ActionID": id, "EventList": "start"},
+			{"Event": "VoicemailUserEntry", "ActionID": id, "VMContext": "default", "VoiceMailbox": "1000"},
+		}
+	})
+
+	if _, err := ami.VoicemailUsersList(); err != errListTimeout {
+		t.Fatal("expected errListTimeout, got", err)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"errors"
+	"sort"
+	"strings"
+)
+
+var errHeaderInjection = errors.New("Header Injection")
+
+// DryRun return the bytes Action would write for p, once normalised, with
+// its ActionID and defaults and checked by the policies of the client,
+// without sending it. p is not modified
+func (client *AMIClient) DryRun(p Params) (string, error) {
+	if p == nil {
+		return "", errInvalidParams
+	}
+
+	copied := make(Params, len(p))
+	for k, v := range p {
+		copied[k] = v
+	}
+	prepared, err := client.prepare(copied)
+	if err != nil {
+		return "", err
+	}
+	return encodeAction(prepared), nil
+}
+
+// prepare normalise p, giving it an ActionID and the defaults, and check
+// it can be sent by the client
+func (client *AMIClient) prepare(p Params) (Params, error) {
+	client.mutexConfig.RLock()
+	readOnly := client.readOnly
+	allow, deny := client.allowActions, client.denyActions
+	defaults, actionDefaults := client.defaultParams, client.actionDefaults
+	client.mutexConfig.RUnlock()
+
+	client.normaliser(&p)
+
+	if _, ok := p["Action"]; !ok {
+		return nil, errInvalidParams
+	}
+	applyDefaults(p, defaults, actionDefaults)
+
+	if !allowedAction(p["Action"], allow, deny) {
+		return nil, ErrActionDenied
+	}
+
+	if readOnly {
+		if !allowedReadOnly(p["Action"]) {
+			return nil, ErrReadOnlyAction
+		}
+		if _, ok := p["Events"]; !ok && strings.EqualFold(p["Action"], "Login") {
+			p["Events"] = "on"
+		}
+	}
+
+	// a line break would inject headers or end the action early, a colon
+	// on a key would give another one
+	for k, v := range p {
+		if strings.ContainsAny(k, "\r\n:") || strings.ContainsAny(v, "\r\n") {
+			return nil, errHeaderInjection
+		}
+	}
+	return p, nil
+}
+
+// encodeAction the wire form of p, Action first and the other params
+// ordered by name
+func encodeAction(p Params) string {
+	keys := make([]string, 0, len(p))
+	for k := range p {
+		if k != "Action" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var output strings.Builder
+	for _, k := range append([]string{"Action"}, keys...) {
+		output.WriteString(k)
+		output.WriteString(": ")
+		output.WriteString(p[k])
+		output.WriteString("\r\n")
+	}
+	output.WriteString("\r\n")
+	return output.String()
+}
@@ -0,0 +1,56 @@
+// Copyright 2014 Jovany Leandro G.C <bit4bit@riseup.net>. All rights reserved.
+// Use of this source code is governed by a MIT-style
+// license that can be found in the LICENSE file
+
+package gami
+
+import (
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	srv, ami := newTestClient(t)
+	defer srv.Close()
+	defer ami.Close()
+	ami.Configure(DefaultParams(Params{"Account": "acme"}))
+
+	p := Params{"channel": " SIP/100 ", "ACTION": "Hangup", "ActionID": "42"}
+	wire, err := ami.DryRun(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "Action: Hangup\r\nAccount: acme\r\nActionid: 42\r\nChannel: SIP/100\r\n\r\n"
+	if wire != expected {
+		t.Fatalf("unexpected wire form %q", wire)
+	}
+	if p["channel"] != " SIP/100 " || len(p) != 3 {
+		t.Fatal("params modified", p)
+	}
+
+	wire, err = ami.DryRun(Params{"Action": "Ping"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(wire) <= len("Action: Ping\r\nActionid: \r\n\r\n") {
+		t.Fatal("ActionID not assigned", wire)
+	}
+
+	injections := []Params{
+		{"Action": "Hangup", "Channel": "SIP/100\r\nAction: Originate"},
+		{"Action": "Hangup", "Channel\nExten": "100"},
+		{"Action": "Hangup", "Channel: SIP/100": ""},
+	}
+	for _, p := range injections {
+		if _, err := ami.DryRun(p); err != errHeaderInjection {
+			t.Fatalf("expected errHeaderInjection for %q, got %v", p, err)
+		}
+		if _, _, err := ami.Action(p); err != errHeaderInjection {
+			t.Fatalf("injection sent %q: %v", p, err)
+		}
+	}
+
+	ami.Configure(DenyActions("Hangup"))
+	if _, err := ami.DryRun(Params{"Action": "Hangup"}); err != ErrActionDenied {
+		t.Fatal("expected ErrActionDenied, got", err)
+	}
+}